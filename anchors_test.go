@@ -0,0 +1,50 @@
+package dnsclient
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRootTrustAnchors(t *testing.T) {
+	anchors, err := RootTrustAnchors()
+	if err != nil || len(anchors) == 0 {
+		t.Fatalf("RootTrustAnchors() = %v, %v", anchors, err)
+	}
+	// KSK-2017, the root key since 2018
+	found := false
+	for _, ds := range anchors {
+		if ds.KeyTag == 20326 && ds.Algorithm == 8 && ds.DigestType == 2 && len(ds.Digest) == 32 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("RootTrustAnchors() = %v, missing KSK-2017", anchors)
+	}
+}
+
+func TestParseRootAnchors(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"bundled", bundledRootAnchors, true},
+		{"not the root", bytes.Replace(bundledRootAnchors, []byte("<Zone>.</Zone>"), []byte("<Zone>com.</Zone>"), 1), false},
+		{"garbage", []byte("<html>"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			anchors, err := ParseRootAnchors(test.data, now)
+			if (err == nil) != test.ok {
+				t.Errorf("ParseRootAnchors() = %v, %v, want ok: %t", anchors, err, test.ok)
+			}
+		})
+	}
+
+	// Nothing is valid before the first key was published
+	if anchors, err := ParseRootAnchors(bundledRootAnchors, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Errorf("ParseRootAnchors() in 2000 = %v, want an error", anchors)
+	}
+}
@@ -0,0 +1,39 @@
+package dnsclient
+
+// ReadCharacterStrings splits rdata into its <character-string>s (RFC 1035 3.3).
+func ReadCharacterStrings(data []byte) []string {
+	var strs []string
+	for len(data) > 0 {
+		n := int(data[0])
+		data = data[1:]
+		if n > len(data) {
+			n = len(data)
+		}
+		strs = append(strs, string(data[:n]))
+		data = data[n:]
+	}
+	return strs
+}
+
+// IsRFC8482 reports whether response is the minimal HINFO answer servers send
+// when they refuse to answer ANY queries (RFC 8482 section 4.2).
+func IsRFC8482(response DnsResponse) bool {
+	for _, q := range response.Questions {
+		if q.QType != ANY {
+			return false
+		}
+	}
+	if len(response.Answers) == 0 {
+		return false
+	}
+	for _, a := range response.Answers {
+		if a.Type != HINFO {
+			return false
+		}
+		strs := ReadCharacterStrings(a.RData)
+		if len(strs) == 0 || strs[0] != "RFC8482" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const APL = 42
+
+// APLItem is one address prefix of an APL record (RFC 3123).
+type APLItem struct {
+	Negation bool
+	Family   uint16 // 1 for IPv4, 2 for IPv6
+	Prefix   uint8
+	Address  net.IP
+}
+
+func (a APLItem) String() string {
+	neg := ""
+	if a.Negation {
+		neg = "!"
+	}
+	return fmt.Sprintf("%s%d:%s/%d", neg, a.Family, a.Address, a.Prefix)
+}
+
+// Network returns the prefix as a *net.IPNet.
+func (a APLItem) Network() *net.IPNet {
+	bits := 8 * len(a.Address)
+	return &net.IPNet{IP: a.Address.Mask(net.CIDRMask(int(a.Prefix), bits)), Mask: net.CIDRMask(int(a.Prefix), bits)}
+}
+
+// ParseAPL decodes APL rdata. Addresses have their trailing zero bytes left
+// out on the wire, they are padded back to full length here.
+func ParseAPL(rdata []byte) ([]APLItem, error) {
+	var items []APLItem
+	for len(rdata) > 0 {
+		if len(rdata) < 4 {
+			return nil, errors.New("apl item truncated")
+		}
+		var a APLItem
+		a.Family = binary.BigEndian.Uint16(rdata)
+		a.Prefix = rdata[2]
+		a.Negation = rdata[3]&0x80 != 0
+		n := int(rdata[3] & 0x7f)
+		rdata = rdata[4:]
+		if n > len(rdata) {
+			return nil, errors.New("apl address truncated")
+		}
+
+		size := 0
+		switch a.Family {
+		case 1:
+			size = net.IPv4len
+		case 2:
+			size = net.IPv6len
+		default:
+			return nil, fmt.Errorf("unknown apl address family %d", a.Family)
+		}
+		if n > size || int(a.Prefix) > 8*size {
+			return nil, errors.New("invalid apl item")
+		}
+		a.Address = make(net.IP, size)
+		copy(a.Address, rdata[:n])
+		rdata = rdata[n:]
+		items = append(items, a)
+	}
+	return items, nil
+}
+
+func init() {
+	RegisterType(APL, RRType{
+		Format: func(rdata []byte) string {
+			items, err := ParseAPL(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			strs := make([]string, len(items))
+			for i, a := range items {
+				strs[i] = a.String()
+			}
+			return strings.Join(strs, " ")
+		},
+	})
+}
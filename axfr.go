@@ -0,0 +1,116 @@
+package dnsclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ZoneTransfer reads the records of an AXFR (RFC 5936) as they arrive, one
+// message at a time, so large zones don't have to fit in memory.
+type ZoneTransfer struct {
+	conn    *StreamConn
+	request DnsRequest
+	msg     *MessageReader
+	count   int
+	done    bool
+}
+
+// StartTransfer asks server for a transfer of zone. Read the records with
+// Next and Close the transfer when done.
+func StartTransfer(server string, zone string) (*ZoneTransfer, error) {
+	conn, err := DialStream(server)
+	if err != nil {
+		return nil, err
+	}
+	request := NewQuery(zone, AXFR).SetRD(false)
+	err = conn.writeMessage(SerializeRequest(*request))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &ZoneTransfer{conn: conn, request: *request}, nil
+}
+
+// Next returns the next record of the zone, starting with the SOA. The SOA
+// the transfer ends with is not repeated, io.EOF is returned instead.
+func (t *ZoneTransfer) Next() (DnsResourceRecord, error) {
+	for !t.done {
+		if t.msg == nil {
+			if err := t.nextMessage(); err != nil {
+				return DnsResourceRecord{}, err
+			}
+		}
+		lazy, section, err := t.msg.NextLazy()
+		if err == io.EOF || err == nil && section != SectionAnswer {
+			t.msg = nil
+			continue
+		}
+		if err != nil {
+			return DnsResourceRecord{}, err
+		}
+		r, err := lazy.Decode()
+		if err != nil {
+			return r, err
+		}
+		if t.count == 0 && r.Type != SOA {
+			return r, errors.New("zone transfer does not start with an SOA record")
+		}
+		if t.count > 0 && r.Type == SOA {
+			t.done = true
+			break
+		}
+		t.count++
+		return r, nil
+	}
+	return DnsResourceRecord{}, io.EOF
+}
+
+func (t *ZoneTransfer) nextMessage() error {
+	msg, err := t.conn.readMessage()
+	if err != nil {
+		return err
+	}
+	m, err := NewMessageReader(msg)
+	if err != nil {
+		return err
+	}
+	if m.Header.Id != t.request.Header.Id {
+		return fmt.Errorf("response id %d does not match request id %d", m.Header.Id, t.request.Header.Id)
+	}
+	if rcode := m.Header.Flags.RCode(); rcode != 0 {
+		return fmt.Errorf("zone transfer refused with rcode %s", RCodeToString(rcode))
+	}
+	if m.Header.AnCount == 0 {
+		return errors.New("zone transfer ended early")
+	}
+	t.msg = m
+	return nil
+}
+
+func (t *ZoneTransfer) Close() error {
+	return t.conn.Close()
+}
+
+// Transfer fetches the whole of zone from server with AXFR and returns its
+// records, starting with the SOA. The SOA the transfer ends with is not
+// repeated.
+func Transfer(server string, zone string) ([]DnsResourceRecord, error) {
+	t, err := StartTransfer(server, zone)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	var records []DnsResourceRecord
+	for {
+		r, err := t.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
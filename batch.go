@@ -0,0 +1,91 @@
+package dnsclient
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type BatchResult struct {
+	Question DnsQuestion
+	Response *DnsResponse
+	Err      error
+}
+
+func (r BatchResult) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s, Error: %v", r.Question, r.Err)
+	}
+	var aStr []string
+	for _, a := range r.Response.Answers {
+		aStr = append(aStr, fmt.Sprintf("{ %s }", a))
+	}
+	return fmt.Sprintf("%s, RCode: %s, Answers: [%s]", r.Question, RCodeToString(r.Response.Header.Flags.RCode()), strings.Join(aStr, ", "))
+}
+
+// ParseBatchLine parses a "name" or "name/type" line. Blank lines and lines
+// starting with # give ok == false.
+func ParseBatchLine(line string, defaultType uint16) (q DnsQuestion, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return q, false, nil
+	}
+	q = DnsQuestion{QName: line, QType: defaultType, QClass: IN}
+	if i := strings.LastIndex(line, "/"); i >= 0 {
+		t, err := StringToType(line[i+1:])
+		if err != nil {
+			return q, false, fmt.Errorf("invalid type in %q", line)
+		}
+		q.QName, q.QType = line[:i], t
+	}
+	q.QName = ToASCII(q.QName)
+	return q, true, nil
+}
+
+// ResolveBatch reads one name[/type] per line from r and resolves them with
+// the given number of concurrent workers. Results are sent as they complete,
+// so they are not in input order. The channel is closed when r is exhausted
+// and every query has finished.
+func ResolveBatch(ctx context.Context, c *Client, r io.Reader, defaultType uint16, workers int) <-chan BatchResult {
+	questions := make(chan DnsQuestion)
+	results := make(chan BatchResult)
+
+	go func() {
+		defer close(questions)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			q, ok, err := ParseBatchLine(scanner.Text(), defaultType)
+			if err != nil {
+				results <- BatchResult{Question: DnsQuestion{QName: scanner.Text()}, Err: err}
+				continue
+			}
+			if ok {
+				questions <- q
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			results <- BatchResult{Err: err}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for q := range questions {
+				request := NewQuery(q.QName, q.QType).SetClass(q.QClass).SetEDNS(c.UDPSize)
+				response, err := c.Exchange(ctx, request)
+				results <- BatchResult{Question: q, Response: response, Err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,188 @@
+package dnsclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// How blocked names are answered
+const (
+	BlockNXDomain = "nxdomain"
+	BlockNull     = "null" // 0.0.0.0 and ::, NODATA for other types
+)
+
+// TTL of the answers to blocked names
+const blockedTTL = 60
+
+// Blocklist holds names to refuse to resolve, Pi-hole style, and counts the
+// queries it blocks. A listed name blocks the names below it too.
+type Blocklist struct {
+	// BlockNXDomain (the default) or BlockNull
+	Answer string
+
+	mu      sync.Mutex
+	names   map[string]bool
+	blocked uint64
+	counts  map[string]uint64
+}
+
+func NewBlocklist() *Blocklist {
+	return &Blocklist{names: make(map[string]bool), counts: make(map[string]uint64)}
+}
+
+// Load adds the names in r, which may be a hosts file ("0.0.0.0 ads.example")
+// or a plain list of domains, one per line. Comments start with #.
+func (b *Blocklist) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 0 && net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+		for _, f := range fields {
+			name := string(Name(ToASCII(f)).Canonical())
+			// Hosts files map these to themselves, they are not meant to be blocked
+			if name == "localhost" || name == "localhost.localdomain" || name == "broadcasthost" || name == "" {
+				continue
+			}
+			b.names[name] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// LoadFile loads a blocklist from a file, or over HTTP if path is a URL.
+func (b *Blocklist) LoadFile(path string) error {
+	var r io.ReadCloser
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		resp, err := http.Get(path)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("%s: http status %s", path, resp.Status)
+		}
+		r = resp.Body
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		r = f
+	}
+	defer r.Close()
+	err := b.Load(r)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Blocked reports whether name or a name above it is on the list.
+func (b *Blocklist) Blocked(name string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.match(name)
+	return ok
+}
+
+func (b *Blocklist) match(name string) (string, bool) {
+	for n := Name(name).Canonical(); !n.IsRoot(); n = n.Parent() {
+		if b.names[string(n)] {
+			return string(n), true
+		}
+	}
+	return "", false
+}
+
+// Block returns the reply to request if its name is blocked, counting it.
+func (b *Blocklist) Block(request DnsRequest) ([]byte, bool) {
+	if len(request.Questions) != 1 {
+		return nil, false
+	}
+	q := request.Questions[0]
+	b.mu.Lock()
+	listed, ok := b.match(q.QName)
+	if ok {
+		b.blocked++
+		b.counts[listed]++
+	}
+	b.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	if b.Answer != BlockNull {
+		return emptyReply(request, 3), true
+	}
+	response := DnsResponse{Header: request.Header, Questions: request.Questions}
+	response.Header.Flags = request.Header.Flags&0x7910 | 0x8080
+	switch q.QType {
+	case A:
+		response.Answers = []DnsResourceRecord{{Name: q.QName, Type: A, Class: q.QClass, TTL: blockedTTL, RDLength: 4, RData: net.IPv4zero.To4()}}
+	case AAAA:
+		response.Answers = []DnsResourceRecord{{Name: q.QName, Type: AAAA, Class: q.QClass, TTL: blockedTTL, RDLength: 16, RData: net.IPv6zero}}
+	}
+	return SerializeResponse(response), true
+}
+
+// BlocklistStats are the counters of a blocklist, with the most blocked
+// names first in Top.
+type BlocklistStats struct {
+	Names   int
+	Blocked uint64
+	Top     []BlockedName
+}
+
+type BlockedName struct {
+	Name  string
+	Count uint64
+}
+
+// Stats returns the counters, with up to top of the most blocked names.
+func (b *Blocklist) Stats(top int) BlocklistStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BlocklistStats{Names: len(b.names), Blocked: b.blocked}
+	for name, n := range b.counts {
+		s.Top = append(s.Top, BlockedName{name, n})
+	}
+	sort.Slice(s.Top, func(i, j int) bool {
+		if s.Top[i].Count != s.Top[j].Count {
+			return s.Top[i].Count > s.Top[j].Count
+		}
+		return s.Top[i].Name < s.Top[j].Name
+	})
+	if len(s.Top) > top {
+		s.Top = s.Top[:top]
+	}
+	return s
+}
+
+// BlocklistHandler serves the blocklist counters as plain text on
+// GET /blocklist/stats.
+func BlocklistHandler(b *Blocklist) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blocklist/stats", func(w http.ResponseWriter, r *http.Request) {
+		s := b.Stats(10)
+		fmt.Fprintf(w, "names: %d\nblocked: %d\n", s.Names, s.Blocked)
+		for _, t := range s.Top {
+			fmt.Fprintf(w, "  %d %s\n", t.Count, t.Name)
+		}
+	})
+	return mux
+}
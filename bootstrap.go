@@ -0,0 +1,89 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+type bootstrapEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// dial connects to addr. Hostnames are looked up through the bootstrap
+// settings if the client has any, so DoT and DoH servers can be given by name
+// without depending on the system resolver, which may be the very thing
+// being replaced.
+func (c *Client) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: c.Timeouts.Dial}
+	host, port, err := net.SplitHostPort(addr)
+	if network == "unix" || err != nil || net.ParseIP(host) != nil || (len(c.BootstrapHosts) == 0 && len(c.BootstrapServers) == 0) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := c.bootstrap(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// bootstrap returns the addresses of host from BootstrapHosts, or looks them
+// up with BootstrapServers and caches them for as long as their TTL.
+func (c *Client) bootstrap(ctx context.Context, host string) ([]net.IP, error) {
+	if ips, ok := c.BootstrapHosts[host]; ok {
+		return ips, nil
+	}
+
+	c.mu.Lock()
+	entry, ok := c.bootstrapCache[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, nil
+	}
+	if len(c.BootstrapServers) == 0 {
+		return nil, fmt.Errorf("no bootstrap address for %s", host)
+	}
+
+	resolver := &Client{Servers: c.BootstrapServers, Timeouts: c.Timeouts, Attempts: c.Attempts}
+	var ips []net.IP
+	ttl := int32(-1)
+	var err error
+	for _, qtype := range []uint16{A, AAAA} {
+		records, lerr := resolver.lookup(ctx, host, qtype)
+		if lerr != nil {
+			err = lerr
+			continue
+		}
+		for _, r := range records {
+			ips = append(ips, net.IP(r.RData))
+			if ttl < 0 || r.TTL < ttl {
+				ttl = r.TTL
+			}
+		}
+	}
+	if len(ips) == 0 {
+		if err == nil {
+			err = errors.New("no addresses")
+		}
+		return nil, fmt.Errorf("bootstrapping %s: %v", host, err)
+	}
+
+	c.mu.Lock()
+	if c.bootstrapCache == nil {
+		c.bootstrapCache = make(map[string]bootstrapEntry)
+	}
+	c.bootstrapCache[host] = bootstrapEntry{ips, time.Now().Add(time.Duration(ttl) * time.Second)}
+	c.mu.Unlock()
+	return ips, nil
+}
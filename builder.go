@@ -0,0 +1,93 @@
+package dnsclient
+
+import "crypto/rand"
+
+// Header flag bits. AD and CD are the DNSSEC bits that RFC 4035 took from the
+// old Z field.
+const (
+	FlagQR = 0x8000
+	FlagAA = 0x0400
+	FlagTC = 0x0200
+	FlagRD = 0x0100
+	FlagRA = 0x0080
+	FlagAD = 0x0020
+	FlagCD = 0x0010
+)
+
+// Opcodes
+const (
+	OpQuery  = 0
+	OpStatus = 2
+	OpNotify = 4
+	OpUpdate = 5
+)
+
+// RandomID returns a message id from crypto/rand, so that nobody who can't
+// see the query can guess its id to spoof the answer (RFC 5452).
+func RandomID() uint16 {
+	var b [2]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("crypto/rand: " + err.Error())
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// NewQuery returns a recursive query with a single IN question for name and
+// a random id. Setters can be chained to change it, and keep the header
+// counts in step with the sections:
+//
+//	request := NewQuery("example.com", MX).SetCD(true).SetEDNS(DefaultUDPSize).SetDO(true)
+func NewQuery(name string, qtype uint16) *DnsRequest {
+	request := &DnsRequest{Header: DnsHeader{Id: RandomID(), Flags: FlagRD}}
+	return request.AddQuestion(name, qtype, IN)
+}
+
+// AddQuestion appends a question for name, which may be an IDN.
+func (r *DnsRequest) AddQuestion(name string, qtype uint16, qclass uint16) *DnsRequest {
+	r.Questions = append(r.Questions, DnsQuestion{QName: ToASCII(name), QType: qtype, QClass: qclass})
+	r.Header.QdCount = uint16(len(r.Questions))
+	return r
+}
+
+// SetClass changes the class of every question.
+func (r *DnsRequest) SetClass(qclass uint16) *DnsRequest {
+	for i := range r.Questions {
+		r.Questions[i].QClass = qclass
+	}
+	return r
+}
+
+func (r *DnsRequest) SetId(id uint16) *DnsRequest {
+	r.Header.Id = id
+	return r
+}
+
+func (r *DnsRequest) SetOpCode(opcode uint16) *DnsRequest {
+	r.Header.Flags = r.Header.Flags&^(0b1111<<11) | DnsFlags(opcode&0b1111)<<11
+	return r
+}
+
+// SetRD sets or clears Recursion Desired.
+func (r *DnsRequest) SetRD(rd bool) *DnsRequest {
+	return r.setFlag(FlagRD, rd)
+}
+
+// SetAD asks for the AD bit in the response (RFC 6840 section 5.7).
+func (r *DnsRequest) SetAD(ad bool) *DnsRequest {
+	return r.setFlag(FlagAD, ad)
+}
+
+// SetCD sets Checking Disabled, asking a validating resolver for answers
+// even if they fail validation.
+func (r *DnsRequest) SetCD(cd bool) *DnsRequest {
+	return r.setFlag(FlagCD, cd)
+}
+
+func (r *DnsRequest) setFlag(flag DnsFlags, on bool) *DnsRequest {
+	if on {
+		r.Header.Flags |= flag
+	} else {
+		r.Header.Flags &^= flag
+	}
+	return r
+}
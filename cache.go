@@ -0,0 +1,346 @@
+package dnsclient
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores responses for Client and Forwarder. Implementations decide
+// how long to keep them with CacheTTL and count down the TTLs of what Get
+// returns. Errors are misses, a broken cache must not break resolution.
+type Cache interface {
+	Get(request DnsRequest) (*DnsResponse, bool)
+	Put(request DnsRequest, response DnsResponse)
+}
+
+// MemoryCache keeps responses in memory for as long as their records may be
+// kept, the lowest TTL among them. NXDOMAIN and NODATA answers are kept for
+// the negative TTL from the SOA in them (RFC 2308 section 5), failures and
+// truncated responses not at all. It is safe to share between clients.
+//
+// When the cache holds more than MaxEntries responses, or their records take
+// more than MaxBytes, the least recently used ones are dropped. Zero means no
+// limit.
+type MemoryCache struct {
+	MaxEntries int
+	MaxBytes   int
+
+	// Entries served with less than PrefetchFraction of their TTL, or less
+	// than PrefetchBelow, left are refreshed in the background so popular
+	// names don't expire. NewCache sets PrefetchFraction to 10%.
+	PrefetchFraction float64
+	PrefetchBelow    time.Duration
+
+	mu      sync.Mutex
+	entries map[cacheKey]*cacheEntry
+	lru     *list.List // of cacheKey, most recently used first
+	bytes   int
+	stats   CacheStats
+}
+
+// CacheStats counts what happened to lookups in a cache. Expired lookups
+// found an entry that was too old and count as misses too.
+type CacheStats struct {
+	Hits      int
+	Misses    int
+	Expired   int
+	Evictions int
+	Entries   int
+	Bytes     int
+}
+
+// DNSSEC records, checking disabled and non-recursive answers differ from
+// plain ones, so the DO, CD and RD bits are part of the key.
+type cacheKey struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+	do     bool
+	cd     bool
+	rd     bool
+}
+
+type cacheEntry struct {
+	response   DnsResponse
+	stored     time.Time
+	ttl        time.Duration
+	refreshing bool
+	elem       *list.Element
+	size       int
+}
+
+// DefaultCacheEntries is how many responses NewCache keeps at most.
+const DefaultCacheEntries = 10000
+
+// NewCache returns a MemoryCache with the default limits.
+func NewCache() *MemoryCache {
+	return &MemoryCache{MaxEntries: DefaultCacheEntries, PrefetchFraction: 0.1}
+}
+
+func newCacheKey(request DnsRequest) cacheKey {
+	q := request.Questions[0]
+	return cacheKey{
+		name:   strings.ToLower(strings.TrimSuffix(q.QName, ".")),
+		qtype:  q.QType,
+		qclass: q.QClass,
+		do:     requestDO(request),
+		cd:     request.Header.Flags&FlagCD != 0,
+		rd:     request.Header.Flags&FlagRD != 0,
+	}
+}
+
+func requestDO(request DnsRequest) bool {
+	for _, a := range request.Additionals {
+		if a.Type == OPT {
+			return a.TTL&EDNSFlagDO != 0
+		}
+	}
+	return false
+}
+
+// CacheTTL returns how long response may be cached, and false if it should
+// not be.
+func CacheTTL(response DnsResponse) (time.Duration, bool) {
+	if response.Header.Flags.TC() != 0 {
+		return 0, false
+	}
+	var records []DnsResourceRecord
+	switch rcode := response.Header.Flags.RCode(); {
+	case rcode == 0 && len(response.Answers) > 0:
+		records = append(append(records, response.Answers...), response.Authorities...)
+	case rcode == 0 || rcode == 3:
+		// Negative answers are cached for the lower of the SOA's TTL and
+		// its minimum field
+		for _, a := range response.Authorities {
+			if a.Type != SOA {
+				continue
+			}
+			soa, err := ParseSOA(a.RData)
+			if err != nil {
+				return 0, false
+			}
+			ttl := a.TTL
+			if int32(soa.Minimum) < ttl {
+				ttl = int32(soa.Minimum)
+			}
+			return time.Duration(ttl) * time.Second, ttl > 0
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+	ttl := int32(-1)
+	for _, r := range records {
+		if r.Type != OPT && (ttl < 0 || r.TTL < ttl) {
+			ttl = r.TTL
+		}
+	}
+	return time.Duration(ttl) * time.Second, ttl > 0
+}
+
+// Put stores the response to request if it can be cached.
+func (c *MemoryCache) Put(request DnsRequest, response DnsResponse) {
+	ttl, ok := CacheTTL(response)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[cacheKey]*cacheEntry)
+		c.lru = list.New()
+	}
+	key := newCacheKey(request)
+	if old := c.entries[key]; old != nil {
+		c.remove(key, old)
+	}
+	// Answers from the cache weren't received as such
+	response.Raw = nil
+	e := &cacheEntry{response: response, stored: time.Now(), ttl: ttl, size: responseSize(response)}
+	e.elem = c.lru.PushFront(key)
+	c.entries[key] = e
+	c.bytes += e.size
+
+	for c.lru.Len() > 0 && (c.MaxEntries > 0 && c.lru.Len() > c.MaxEntries || c.MaxBytes > 0 && c.bytes > c.MaxBytes) {
+		oldest := c.lru.Back().Value.(cacheKey)
+		c.remove(oldest, c.entries[oldest])
+		c.stats.Evictions++
+	}
+}
+
+func (c *MemoryCache) remove(key cacheKey, e *cacheEntry) {
+	c.lru.Remove(e.elem)
+	delete(c.entries, key)
+	c.bytes -= e.size
+}
+
+// responseSize roughly estimates the memory a response takes: its names and
+// rdata plus the fixed fields of each record.
+func responseSize(response DnsResponse) int {
+	size := 0
+	for _, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, response.Additionals} {
+		for _, r := range section {
+			size += len(r.Name) + len(r.RData) + 64
+		}
+	}
+	for _, q := range response.Questions {
+		size += len(q.QName) + 32
+	}
+	return size + 128
+}
+
+// Get returns the cached response to request with its TTLs counted down by
+// the time it spent in the cache.
+func (c *MemoryCache) Get(request DnsRequest) (*DnsResponse, bool) {
+	return c.get(request, nil)
+}
+
+// get is Get, calling refresh in the background when the entry is due for
+// prefetching.
+func (c *MemoryCache) get(request DnsRequest, refresh func(DnsRequest)) (*DnsResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := newCacheKey(request)
+	e := c.entries[key]
+	if e == nil {
+		c.stats.Misses++
+		return nil, false
+	}
+	age := time.Since(e.stored)
+	left := e.ttl - age
+	if left <= 0 {
+		c.remove(key, e)
+		c.stats.Misses++
+		c.stats.Expired++
+		return nil, false
+	}
+	c.stats.Hits++
+	c.lru.MoveToFront(e.elem)
+	if refresh != nil && !e.refreshing && c.due(e.ttl, left) {
+		e.refreshing = true
+		go refresh(request)
+	}
+
+	response := e.aged(age)
+	response.Header.Id = request.Header.Id
+	return &response, true
+}
+
+func (e *cacheEntry) aged(age time.Duration) DnsResponse {
+	response := e.response
+	response.RTT = 0
+	response.Answers = agedRecords(response.Answers, age)
+	response.Authorities = agedRecords(response.Authorities, age)
+	response.Additionals = agedRecords(response.Additionals, age)
+	return response
+}
+
+func (c *MemoryCache) due(ttl, left time.Duration) bool {
+	return left < c.PrefetchBelow || float64(left) < c.PrefetchFraction*float64(ttl)
+}
+
+func agedRecords(records []DnsResourceRecord, age time.Duration) []DnsResourceRecord {
+	aged := make([]DnsResourceRecord, len(records))
+	copy(aged, records)
+	for i := range aged {
+		if aged[i].Type == OPT {
+			continue
+		}
+		aged[i].TTL -= int32(age / time.Second)
+		if aged[i].TTL < 0 {
+			aged[i].TTL = 0
+		}
+	}
+	return aged
+}
+
+// prefetch refreshes the cached answer to request. If the refresh fails the
+// old entry stays until it expires, and the next hit tries again.
+func (c *Client) prefetch(request DnsRequest) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	response, err := c.exchangeServers(ctx, &request)
+	if err == nil {
+		if _, ok := CacheTTL(*response); ok {
+			c.Cache.Put(request, *response)
+			return
+		}
+	}
+	if mc, ok := c.Cache.(*MemoryCache); ok {
+		mc.mu.Lock()
+		if e := mc.entries[newCacheKey(request)]; e != nil {
+			e.refreshing = false
+		}
+		mc.mu.Unlock()
+	}
+}
+
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// CacheEntry is a cached response as returned by Dump.
+type CacheEntry struct {
+	Question DnsQuestion
+	DO, CD   bool
+	TTL      time.Duration // time left
+	Response DnsResponse
+}
+
+// Dump returns the unexpired entries, most recently used first.
+func (c *MemoryCache) Dump() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var entries []CacheEntry
+	if c.lru == nil {
+		return entries
+	}
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(cacheKey)
+		e := c.entries[key]
+		age := time.Since(e.stored)
+		if age >= e.ttl {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Question: DnsQuestion{QName: key.name, QType: key.qtype, QClass: key.qclass},
+			DO:       key.do,
+			CD:       key.cd,
+			TTL:      e.ttl - age,
+			Response: e.aged(age),
+		})
+	}
+	return entries
+}
+
+// Flush removes the entries for name and returns how many there were.
+func (c *MemoryCache) Flush(name string) int {
+	return c.flush(func(key cacheKey) bool { return Name(key.name).Equal(Name(name)) })
+}
+
+// FlushZone removes the entries for zone and every name below it, "." empties
+// the cache.
+func (c *MemoryCache) FlushZone(zone string) int {
+	return c.flush(func(key cacheKey) bool { return Name(key.name).IsSubdomainOf(Name(zone)) })
+}
+
+func (c *MemoryCache) flush(match func(cacheKey) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, e := range c.entries {
+		if match(key) {
+			c.remove(key, e)
+			n++
+		}
+	}
+	return n
+}
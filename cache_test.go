@@ -0,0 +1,142 @@
+package dnsclient
+
+import (
+	"testing"
+	"time"
+)
+
+func answerA(name string, ttl int32) DnsResponse {
+	return DnsResponse{
+		Header:    DnsHeader{Flags: 0x8180, QdCount: 1, AnCount: 1},
+		Questions: []DnsQuestion{{QName: name, QType: A, QClass: IN}},
+		Answers:   []DnsResourceRecord{{Name: name, Type: A, Class: IN, TTL: ttl, RDLength: 4, RData: []byte{192, 0, 2, 1}}},
+	}
+}
+
+// age makes the entry for request look stored d ago.
+func (c *MemoryCache) age(request DnsRequest, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[newCacheKey(request)].stored = time.Now().Add(-d)
+}
+
+func TestCacheTTL(t *testing.T) {
+	soa := DnsResourceRecord{Name: "example.com", Type: SOA, Class: IN, TTL: 3600,
+		RData: SerializeSOA(SOARecord{MName: "ns.example.com", RName: "hostmaster.example.com", Minimum: 300})}
+	truncated := answerA("example.com", 60)
+	truncated.Header.Flags |= FlagTC
+	servfail := answerA("example.com", 60)
+	servfail.Header.Flags |= 2
+	lowest := answerA("example.com", 600)
+	lowest.Authorities = []DnsResourceRecord{{Name: "example.com", Type: NS, Class: IN, TTL: 30}}
+
+	tests := []struct {
+		name     string
+		response DnsResponse
+		ttl      time.Duration
+		ok       bool
+	}{
+		{"answer", answerA("example.com", 60), time.Minute, true},
+		{"lowest ttl", lowest, 30 * time.Second, true},
+		{"zero ttl", answerA("example.com", 0), 0, false},
+		{"nxdomain", negative("example.com", A, 3, soa), 5 * time.Minute, true},
+		{"nodata", negative("example.com", MX, 0, soa), 5 * time.Minute, true},
+		{"nxdomain without soa", negative("example.com", A, 3), 0, false},
+		{"truncated", truncated, 0, false},
+		{"servfail", servfail, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ttl, ok := CacheTTL(test.response)
+			if ok != test.ok || ok && ttl != test.ttl {
+				t.Errorf("CacheTTL() = %v, %t, want %v, %t", ttl, ok, test.ttl, test.ok)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheAging(t *testing.T) {
+	c := NewCache()
+	query := NewQuery("example.com", A)
+	c.Put(*query, answerA("example.com", 60))
+
+	// Same question under another id and case
+	again := NewQuery("Example.COM.", A)
+	response, ok := c.Get(*again)
+	if !ok {
+		t.Fatal("miss right after Put")
+	}
+	if response.Header.Id != again.Header.Id || response.Answers[0].TTL != 60 {
+		t.Errorf("got id %#x ttl %d, want %#x ttl 60", response.Header.Id, response.Answers[0].TTL, again.Header.Id)
+	}
+
+	c.age(*query, 25*time.Second)
+	if response, ok = c.Get(*query); !ok || response.Answers[0].TTL != 35 {
+		t.Fatalf("after 25s got %v, %t, want ttl 35", response, ok)
+	}
+	c.age(*query, time.Minute)
+	if _, ok = c.Get(*query); ok {
+		t.Fatal("hit after the ttl ran out")
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 || stats.Expired != 1 || stats.Entries != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	// The DO bit is part of the key
+	c.Put(*query, answerA("example.com", 60))
+	if _, ok = c.Get(*NewQuery("example.com", A).SetEDNS(DefaultUDPSize).SetDO(true)); ok {
+		t.Error("DO query answered from a plain entry")
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	c := &MemoryCache{MaxEntries: 2}
+	a, b, d := NewQuery("a.example", A), NewQuery("b.example", A), NewQuery("d.example", A)
+	c.Put(*a, answerA("a.example", 60))
+	c.Put(*b, answerA("b.example", 60))
+	// a is now more recently used than b, so b goes
+	c.Get(*a)
+	c.Put(*d, answerA("d.example", 60))
+
+	for _, test := range []struct {
+		query *DnsRequest
+		want  bool
+	}{{a, true}, {b, false}, {d, true}} {
+		if _, ok := c.Get(*test.query); ok != test.want {
+			t.Errorf("%s cached: %t, want %t", test.query.Questions[0].QName, ok, test.want)
+		}
+	}
+	if stats := c.Stats(); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("stats = %+v, want 1 eviction and 2 entries", stats)
+	}
+
+	// A byte limit that fits one response
+	size := responseSize(answerA("a.example", 60))
+	c = &MemoryCache{MaxBytes: size + size/2}
+	c.Put(*a, answerA("a.example", 60))
+	c.Put(*b, answerA("b.example", 60))
+	if _, ok := c.Get(*a); ok {
+		t.Error("a.example still cached past MaxBytes")
+	}
+	if stats := c.Stats(); stats.Entries != 1 || stats.Bytes > c.MaxBytes {
+		t.Errorf("stats = %+v, want 1 entry within %d bytes", stats, c.MaxBytes)
+	}
+}
+
+func TestMemoryCacheFlush(t *testing.T) {
+	c := NewCache()
+	for _, name := range []string{"example.com", "www.example.com", "example.org"} {
+		c.Put(*NewQuery(name, A), answerA(name, 60))
+	}
+	if n := c.Flush("www.example.com."); n != 1 {
+		t.Errorf("Flush() = %d, want 1", n)
+	}
+	if n := c.FlushZone("com"); n != 1 {
+		t.Errorf("FlushZone(com) = %d, want 1", n)
+	}
+	dump := c.Dump()
+	if len(dump) != 1 || dump[0].Question.QName != "example.org" {
+		t.Errorf("Dump() = %+v, want only example.org", dump)
+	}
+}
@@ -0,0 +1,54 @@
+package dnsclient
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultControlAddr is where the cache subcommand looks for a forwarder's
+// control API unless told otherwise.
+const DefaultControlAddr = "127.0.0.1:5380"
+
+// CacheHandler serves a plain text API for inspecting and flushing cache:
+//
+//	GET  /cache/stats          hit, miss and eviction counters
+//	GET  /cache                every entry with its records
+//	POST /cache/flush?name=n   drop the entries for n
+//	POST /cache/flush?zone=z   drop the entries for z and the names below it
+func CacheHandler(cache *MemoryCache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cache/stats", func(w http.ResponseWriter, r *http.Request) {
+		s := cache.Stats()
+		fmt.Fprintf(w, "entries: %d\nbytes: %d\nhits: %d\nmisses: %d\nexpired: %d\nevictions: %d\n",
+			s.Entries, s.Bytes, s.Hits, s.Misses, s.Expired, s.Evictions)
+	})
+	mux.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {
+		for _, e := range cache.Dump() {
+			fmt.Fprintf(w, "%s %s %s rcode=%s ttl=%s do=%t cd=%t\n", FormatName(e.Question.QName), TypeToString(e.Question.QType),
+				ClassToString(e.Question.QClass), RCodeToString(e.Response.Header.Flags.RCode()), e.TTL.Truncate(1e9), e.DO, e.CD)
+			for _, section := range [][]DnsResourceRecord{e.Response.Answers, e.Response.Authorities} {
+				for _, a := range section {
+					fmt.Fprintf(w, "  %s\n", a)
+				}
+			}
+		}
+	})
+	mux.HandleFunc("/cache/flush", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "flush needs POST", http.StatusMethodNotAllowed)
+			return
+		}
+		var n int
+		switch q := r.URL.Query(); {
+		case q.Get("name") != "":
+			n = cache.Flush(ToASCII(q.Get("name")))
+		case q.Get("zone") != "":
+			n = cache.FlushZone(ToASCII(q.Get("zone")))
+		default:
+			http.Error(w, "flush needs name or zone", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, "flushed %d entries\n", n)
+	})
+	return mux
+}
@@ -0,0 +1,104 @@
+package dnsclient
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// nameLabels splits a name into lowercase labels, dropping the root.
+func nameLabels(name string) []string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
+
+// CompareNames orders names in canonical DNS order (RFC 4034 section 6.1):
+// label by label starting from the root, comparing lowercased labels as
+// bytes, where a name sorts before the names below it.
+func CompareNames(a, b string) int {
+	la, lb := nameLabels(a), nameLabels(b)
+	for i, j := len(la)-1, len(lb)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if c := bytes.Compare([]byte(la[i]), []byte(lb[j])); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(la) < len(lb):
+		return -1
+	case len(la) > len(lb):
+		return 1
+	}
+	return 0
+}
+
+// CanonicalRecord returns rr in canonical form (RFC 4034 section 6.2): owner
+// name and the names inside the rdata of the types listed there lowercased.
+// The rdata stays in the form ReadResourceRecord stores it in.
+func CanonicalRecord(rr DnsResourceRecord) DnsResourceRecord {
+	rr.Name = strings.ToLower(rr.Name)
+	switch rr.Type {
+	case NS, CNAME, PTR, DNAME:
+		rr.RData = []byte(strings.ToLower(string(rr.RData)))
+	case MX, SRV, MINFO, RP, SOA:
+		rr.RData = canonicalRData(rr.Type, rr.RData)
+	}
+	return rr
+}
+
+// SerializeCanonical returns the wire form of rr in canonical form, without
+// name compression.
+func SerializeCanonical(rr DnsResourceRecord) []byte {
+	var buf bytes.Buffer
+	SerializeResourceRecord(&buf, CanonicalRecord(rr))
+	return buf.Bytes()
+}
+
+// CompareRecords orders records canonically: by owner name (see
+// CompareNames), then class and type, and within an RRset by their canonical
+// rdata as left-justified unsigned octet sequences (RFC 4034 section 6.3).
+func CompareRecords(a, b DnsResourceRecord) int {
+	if c := CompareNames(a.Name, b.Name); c != 0 {
+		return c
+	}
+	switch {
+	case a.Class != b.Class:
+		return compareUint16(a.Class, b.Class)
+	case a.Type != b.Type:
+		return compareUint16(a.Type, b.Type)
+	}
+	return bytes.Compare(canonicalRData(a.Type, a.RData), canonicalRData(b.Type, b.RData))
+}
+
+func compareUint16(a, b uint16) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+// SortCanonical sorts records in canonical order in place.
+func SortCanonical(records []DnsResourceRecord) {
+	sort.SliceStable(records, func(i, j int) bool { return CompareRecords(records[i], records[j]) < 0 })
+}
+
+// CanonicalRRset returns a copy of records in canonical form and order with
+// duplicates removed, as DNSSEC signs them. Records only differing in TTL are
+// duplicates too, the first one is kept.
+func CanonicalRRset(records []DnsResourceRecord) []DnsResourceRecord {
+	sorted := make([]DnsResourceRecord, len(records))
+	for i, rr := range records {
+		sorted[i] = CanonicalRecord(rr)
+	}
+	SortCanonical(sorted)
+	var result []DnsResourceRecord
+	for _, rr := range sorted {
+		if n := len(result); n > 0 && CompareRecords(result[n-1], rr) == 0 {
+			continue
+		}
+		result = append(result, rr)
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package dnsclient
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCompareNames(t *testing.T) {
+	// RFC 4034 section 6.1, in order
+	ordered := []string{
+		"example",
+		"a.example",
+		"yljkjljk.a.example",
+		"Z.a.example",
+		"zABC.a.EXAMPLE",
+		"z.example",
+		"\x01.z.example",
+		"*.z.example",
+		"\x80.z.example",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := CompareNames(ordered[i], ordered[j]); got != want {
+				t.Errorf("CompareNames(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	shuffled := append([]string{}, ordered...)
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	sort.Slice(shuffled, func(i, j int) bool { return CompareNames(shuffled[i], shuffled[j]) < 0 })
+	if !reflect.DeepEqual(shuffled, ordered) {
+		t.Errorf("sorted %q, want %q", shuffled, ordered)
+	}
+
+	for _, equal := range [][2]string{{"Example.COM", "example.com."}, {"", "."}} {
+		if got := CompareNames(equal[0], equal[1]); got != 0 {
+			t.Errorf("CompareNames(%q, %q) = %d, want 0", equal[0], equal[1], got)
+		}
+	}
+}
+
+func TestSortCanonical(t *testing.T) {
+	a := func(name string, ip ...byte) DnsResourceRecord {
+		return DnsResourceRecord{Name: name, Type: A, Class: IN, TTL: 300, RData: ip}
+	}
+	records := []DnsResourceRecord{
+		a("b.example", 192, 0, 2, 1),
+		{Name: "a.example", Type: MX, Class: IN, RData: []byte{0, 10, 1, 'm', 0}},
+		a("a.example", 192, 0, 2, 10),
+		a("a.example", 192, 0, 2, 9),
+		{Name: "a.example", Type: A, Class: CH, RData: []byte{1, 1, 1, 1}},
+		a("example", 192, 0, 2, 1),
+	}
+	SortCanonical(records)
+	var got [][2]interface{}
+	for _, r := range records {
+		got = append(got, [2]interface{}{r.Name, r.Type})
+	}
+	want := [][2]interface{}{
+		{"example", uint16(A)},
+		{"a.example", uint16(A)},
+		{"a.example", uint16(A)},
+		{"a.example", uint16(MX)},
+		{"a.example", uint16(A)},
+		{"b.example", uint16(A)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorted %v, want %v", got, want)
+	}
+	if records[1].RData[3] != 9 || records[2].RData[3] != 10 || records[4].Class != CH {
+		t.Errorf("RRset not in rdata order, or classes out of order: %v", records)
+	}
+}
+
+func TestCanonicalRRset(t *testing.T) {
+	records := []DnsResourceRecord{
+		{Name: "Example.COM", Type: NS, Class: IN, TTL: 300, RData: []byte("NS2.Example.com")},
+		{Name: "example.com", Type: NS, Class: IN, TTL: 300, RData: []byte("ns1.example.com")},
+		{Name: "example.com", Type: NS, Class: IN, TTL: 60, RData: []byte("NS1.example.COM")},
+	}
+	got := CanonicalRRset(records)
+	if len(got) != 2 {
+		t.Fatalf("CanonicalRRset() = %v, want 2 records", got)
+	}
+	if string(got[0].RData) != "ns1.example.com" || string(got[1].RData) != "ns2.example.com" || got[1].Name != "example.com" {
+		t.Errorf("CanonicalRRset() = %v", got)
+	}
+	if got[0].TTL != 300 {
+		t.Errorf("kept the duplicate with TTL %d, want the first one", got[0].TTL)
+	}
+}
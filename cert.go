@@ -0,0 +1,72 @@
+package dnsclient
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const CERT = 37
+
+// Certificate type mnemonics (RFC 4398 section 2.1)
+var certTypeNames = map[uint16]string{
+	1:   "PKIX",
+	2:   "SPKI",
+	3:   "PGP",
+	4:   "IPKIX",
+	5:   "ISPKI",
+	6:   "IPGP",
+	7:   "ACPKIX",
+	8:   "IACPKIX",
+	253: "URI",
+	254: "OID",
+}
+
+// CERTRecord is the RData of a CERT record (RFC 4398).
+type CERTRecord struct {
+	Type        uint16
+	KeyTag      uint16
+	Algorithm   uint8
+	Certificate []byte
+}
+
+func (c CERTRecord) String() string {
+	t, ok := certTypeNames[c.Type]
+	if !ok {
+		t = fmt.Sprint(c.Type)
+	}
+	return fmt.Sprintf("%s %d %d %s", t, c.KeyTag, c.Algorithm, base64.StdEncoding.EncodeToString(c.Certificate))
+}
+
+func ParseCERT(rdata []byte) (CERTRecord, error) {
+	var c CERTRecord
+	if len(rdata) < 5 {
+		return c, errors.New("cert rdata too short")
+	}
+	c.Type = binary.BigEndian.Uint16(rdata)
+	c.KeyTag = binary.BigEndian.Uint16(rdata[2:])
+	c.Algorithm = rdata[4]
+	c.Certificate = rdata[5:]
+	return c, nil
+}
+
+func SerializeCERT(c CERTRecord) []byte {
+	rdata := make([]byte, 5, 5+len(c.Certificate))
+	binary.BigEndian.PutUint16(rdata, c.Type)
+	binary.BigEndian.PutUint16(rdata[2:], c.KeyTag)
+	rdata[4] = c.Algorithm
+	return append(rdata, c.Certificate...)
+}
+
+func init() {
+	RegisterType(CERT, RRType{
+		Format: func(rdata []byte) string {
+			c, err := ParseCERT(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return c.String()
+		},
+	})
+}
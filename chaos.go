@@ -0,0 +1,32 @@
+package dnsclient
+
+import (
+	"context"
+	"fmt"
+)
+
+// Well known CH TXT names that identify the server software and instance.
+var ChaosNames = []string{"version.bind", "hostname.bind", "id.server"}
+
+// ChaosQuery sends a CH TXT query for name to server with a client made with
+// opts and returns the strings from the answers.
+func ChaosQuery(ctx context.Context, server string, name string, opts ...Option) ([]string, error) {
+	request := NewQuery(name, TXT).SetClass(CH)
+
+	client := NewClient(withServers(opts, server)...)
+	response, err := client.Exchange(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if response.Header.Flags.RCode() != 0 {
+		return nil, fmt.Errorf("rcode %s", RCodeToString(response.Header.Flags.RCode()))
+	}
+
+	var strs []string
+	for _, a := range response.Answers {
+		if a.Type == TXT {
+			strs = append(strs, ReadCharacterStrings(a.RData)...)
+		}
+	}
+	return strs, nil
+}
@@ -0,0 +1,29 @@
+package dnsclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+func TestChaosQuery(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Handle("version.bind", TXT, dnstest.Response{Answers: []dnstest.Record{
+		{Name: "version.bind", Type: TXT, Class: CH, RData: []byte("\x069.18.0")},
+	}})
+	strs, err := ChaosQuery(context.Background(), server.Addr(), "version.bind", WithTimeout(time.Second))
+	if err != nil || len(strs) != 1 || strs[0] != "9.18.0" {
+		t.Errorf("ChaosQuery() = %q, %v, want [9.18.0]", strs, err)
+	}
+
+	givesUp(t, func(ctx context.Context, server string, opts ...Option) error {
+		_, err := ChaosQuery(ctx, server, "version.bind", opts...)
+		return err
+	})
+}
@@ -0,0 +1,259 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type taggedKey struct {
+	tag uint16
+	DNSKEYRecord
+}
+
+func (k taggedKey) String() string {
+	kind := "ZSK"
+	if k.Flags&DNSKEYFlagSEP != 0 {
+		kind = "KSK"
+	}
+	return fmt.Sprintf("%d (%s, alg %d)", k.tag, kind, k.Algorithm)
+}
+
+// rrsetAndSigs splits the records owned by name into the RRset of type t and
+// the RRSIGs covering it.
+func rrsetAndSigs(records []DnsResourceRecord, name string, t uint16) ([]DnsResourceRecord, []RRSIGRecord) {
+	var rrset []DnsResourceRecord
+	var sigs []RRSIGRecord
+	for _, r := range records {
+		if CompareNames(r.Name, name) != 0 {
+			continue
+		}
+		switch r.Type {
+		case t:
+			rrset = append(rrset, r)
+		case RRSIG:
+			sig, err := ParseRRSIG(r.RData)
+			if err == nil && sig.TypeCovered == t {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	return rrset, sigs
+}
+
+// verifyRRset tries every signature against the trusted keys and reports the
+// first one that verifies, or why none did.
+func verifyRRset(rrset []DnsResourceRecord, sigs []RRSIGRecord, keys []taggedKey, now time.Time) (uint16, error) {
+	if len(rrset) == 0 {
+		return 0, fmt.Errorf("no records")
+	}
+	if len(sigs) == 0 {
+		return 0, fmt.Errorf("no RRSIG")
+	}
+	err := fmt.Errorf("no trusted key matches the RRSIG key tags")
+	for _, sig := range sigs {
+		for _, key := range keys {
+			if key.tag != sig.KeyTag || key.Algorithm != sig.Algorithm {
+				continue
+			}
+			err = VerifyRRSIG(sig, key.DNSKEYRecord, rrset, now)
+			if err == nil {
+				return key.tag, nil
+			}
+		}
+	}
+	return 0, err
+}
+
+// verifyDenialSigs checks the signatures over the NSEC and NSEC3 RRsets in
+// the authority section, which a denial proof is only as good as.
+func verifyDenialSigs(authorities []DnsResourceRecord, keys []taggedKey, now time.Time) error {
+	found := false
+	for _, rrset := range splitRRsets(authorities) {
+		t := rrset[0].Type
+		if t != NSEC && t != NSEC3 {
+			continue
+		}
+		found = true
+		_, sigs := rrsetAndSigs(authorities, rrset[0].Name, t)
+		if _, err := verifyRRset(rrset, sigs, keys, now); err != nil {
+			return fmt.Errorf("RRSIG %s %s: not valid: %v", TypeToString(t), FormatName(rrset[0].Name), err)
+		}
+	}
+	if !found {
+		return fmt.Errorf("no NSEC or NSEC3 records")
+	}
+	return nil
+}
+
+// ChaseChain walks the DNSSEC chain of trust for name from the root down,
+// printing the DS, DNSKEY and RRSIG records at each zone cut and whether each
+// link validates, and finally the signature on the name's own qtype records.
+// It returns true if every link validated. CNAME and DNAME aliases are chased
+// to their targets.
+func ChaseChain(ctx context.Context, c *Client, name string, qtype uint16, anchors []DSRecord, w io.Writer) (bool, error) {
+	return chaseChain(ctx, c, name, qtype, anchors, w, 0)
+}
+
+func chaseChain(ctx context.Context, c *Client, name string, qtype uint16, anchors []DSRecord, w io.Writer, aliases int) (bool, error) {
+	now := time.Now()
+	name = strings.TrimSuffix(ToASCII(name), ".")
+	labels := nameLabels(name)
+
+	type zone struct {
+		name     string
+		response *DnsResponse
+	}
+	var zones []zone
+	for i := len(labels); i >= 0; i-- {
+		candidate := strings.Join(labels[i:], ".")
+		response, err := c.exchange(ctx, candidate, DNSKEY)
+		if err != nil {
+			return false, err
+		}
+		if keys, _ := rrsetAndSigs(response.Answers, candidate, DNSKEY); len(keys) > 0 {
+			zones = append(zones, zone{candidate, response})
+		}
+	}
+	if len(zones) == 0 || zones[0].name != "" {
+		return false, fmt.Errorf("the root zone has no DNSKEY records, is the server returning DNSSEC data?")
+	}
+
+	secure := true
+	var parentKeys []taggedKey
+	for _, z := range zones {
+		fmt.Fprintf(w, "Zone: %s.\n", z.name)
+
+		// The DS set, from the trust anchors for the root and signed by the
+		// parent's keys everywhere else
+		dsSet := anchors
+		if z.name == "" {
+			fmt.Fprintf(w, "  DS: from trust anchors\n")
+		} else {
+			response, err := c.exchange(ctx, z.name, DS)
+			if err != nil {
+				return false, err
+			}
+			rrset, sigs := rrsetAndSigs(response.Answers, z.name, DS)
+			dsSet = nil
+			for _, r := range rrset {
+				if ds, err := ParseDS(r.RData); err == nil {
+					dsSet = append(dsSet, ds)
+				}
+			}
+			tag, err := verifyRRset(rrset, sigs, parentKeys, now)
+			if err != nil {
+				secure = false
+				fmt.Fprintf(w, "  RRSIG DS: not valid: %v\n", err)
+			} else {
+				fmt.Fprintf(w, "  RRSIG DS by %d: valid\n", tag)
+			}
+		}
+
+		rrset, sigs := rrsetAndSigs(z.response.Answers, z.name, DNSKEY)
+		var keys []taggedKey
+		for _, r := range rrset {
+			if key, err := ParseDNSKEY(r.RData); err == nil {
+				keys = append(keys, taggedKey{KeyTag(key), key})
+			}
+		}
+		var keyStrs []string
+		for _, k := range keys {
+			keyStrs = append(keyStrs, k.String())
+		}
+		fmt.Fprintf(w, "  DNSKEY: %s\n", strings.Join(keyStrs, ", "))
+
+		// Keys that a DS record points at are the secure entry points
+		var entry []taggedKey
+		for _, ds := range dsSet {
+			status := "no matching DNSKEY"
+			for _, k := range keys {
+				if k.tag != ds.KeyTag || k.Algorithm != ds.Algorithm {
+					continue
+				}
+				computed, err := NewDS(z.name, k.DNSKEYRecord, ds.DigestType)
+				if err != nil {
+					status = err.Error()
+					continue
+				}
+				if bytes.Equal(computed.Digest, ds.Digest) {
+					status = fmt.Sprintf("matches DNSKEY %d", k.tag)
+					entry = append(entry, k)
+					break
+				}
+				status = fmt.Sprintf("digest does not match DNSKEY %d", k.tag)
+			}
+			fmt.Fprintf(w, "  DS %s: %s\n", ds, status)
+		}
+		if len(entry) == 0 {
+			secure = false
+		}
+
+		tag, err := verifyRRset(rrset, sigs, entry, now)
+		if err != nil {
+			secure = false
+			fmt.Fprintf(w, "  RRSIG DNSKEY: not valid: %v\n", err)
+			// Keep walking with the unverified keys so later links are still reported
+			parentKeys = keys
+			continue
+		}
+		fmt.Fprintf(w, "  RRSIG DNSKEY by %d: valid\n", tag)
+		parentKeys = keys
+	}
+
+	response, err := c.exchange(ctx, name, qtype)
+	if err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "Answer: %s. %s\n", name, TypeToString(qtype))
+	rrset, _ := rrsetAndSigs(response.Answers, name, qtype)
+	if _, chain := FollowAliases(response.Answers, name); len(rrset) == 0 && len(chain) > 0 {
+		// Only the first alias is in this zone, the rest of the chain gets
+		// its own walk from the root
+		alias := chain[0]
+		fmt.Fprintf(w, "  %s %s: %s\n", TypeToString(alias.Type), alias.Name, alias.RDataString())
+		aliasSet, sigs := rrsetAndSigs(response.Answers, alias.Name, alias.Type)
+		tag, err := verifyRRset(aliasSet, sigs, parentKeys, now)
+		if err != nil {
+			secure = false
+			fmt.Fprintf(w, "  RRSIG %s: not valid: %v\n", TypeToString(alias.Type), err)
+		} else {
+			fmt.Fprintf(w, "  RRSIG %s by %d: valid\n", TypeToString(alias.Type), tag)
+		}
+		if aliases+1 >= maxAliasChain {
+			return false, fmt.Errorf("too many aliases chasing %s", name)
+		}
+		target, _ := FollowAliases(chain[:1], name)
+		fmt.Fprintln(w)
+		targetSecure, err := chaseChain(ctx, c, target, qtype, anchors, w, aliases+1)
+		return secure && targetSecure, err
+	}
+	if len(rrset) == 0 {
+		result, err := VerifyDenial(*response)
+		if err == nil {
+			err = verifyDenialSigs(response.Authorities, parentKeys, now)
+		}
+		if err != nil {
+			secure = false
+			fmt.Fprintf(w, "  no records, denial not proven: %v\n", err)
+		} else {
+			fmt.Fprintf(w, "  no records, %s\n", result)
+		}
+		return secure, nil
+	}
+	for _, r := range rrset {
+		fmt.Fprintf(w, "  %s\n", r.RDataString())
+	}
+	_, sigs := rrsetAndSigs(response.Answers, rrset[0].Name, qtype)
+	tag, err := verifyRRset(rrset, sigs, parentKeys, now)
+	if err != nil {
+		secure = false
+		fmt.Fprintf(w, "  RRSIG %s: not valid: %v\n", TypeToString(qtype), err)
+	} else {
+		fmt.Fprintf(w, "  RRSIG %s by %d: valid\n", TypeToString(qtype), tag)
+	}
+	return secure, nil
+}
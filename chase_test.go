@@ -0,0 +1,43 @@
+package dnsclient
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyDenialSigs(t *testing.T) {
+	zone := newTestSigner(t, "example.com")
+	other := newTestSigner(t, "example.com")
+	nsec := DnsResourceRecord{Name: "a.example.com", Type: NSEC, Class: IN, TTL: 300, RData: nsecRData("c.example.com", A, RRSIG, NSEC)}
+	soa := DnsResourceRecord{Name: "example.com", Type: SOA, Class: IN, TTL: 300}
+	tests := []struct {
+		name        string
+		authorities []DnsResourceRecord
+		err         string
+	}{
+		{"signed", []DnsResourceRecord{soa, nsec, zone.sign([]DnsResourceRecord{nsec})}, ""},
+		{"unsigned", []DnsResourceRecord{soa, nsec}, "no RRSIG"},
+		{"signed by another key", []DnsResourceRecord{soa, nsec, other.sign([]DnsResourceRecord{nsec})}, "not valid"},
+		{"no NSEC", []DnsResourceRecord{soa}, "no NSEC"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifyDenialSigs(test.authorities, []taggedKey{zone.tagged()}, time.Now())
+			if test.err == "" && err != nil {
+				t.Fatalf("verifyDenialSigs() = %v", err)
+			}
+			if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+				t.Fatalf("verifyDenialSigs() = %v, want %q", err, test.err)
+			}
+		})
+	}
+
+	// A forged NSEC under a valid signature of another one
+	forged := nsec
+	forged.RData = nsecRData("z.example.com", A, RRSIG, NSEC)
+	sig := zone.sign([]DnsResourceRecord{nsec})
+	if err := verifyDenialSigs([]DnsResourceRecord{forged, sig}, []taggedKey{zone.tagged()}, time.Now()); err == nil {
+		t.Error("verifyDenialSigs() accepted a forged NSEC")
+	}
+}
@@ -0,0 +1,698 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ParseServer turns "ip" or "ip:port" into a socket address, a
+// *syscall.SockaddrInet4 or *syscall.SockaddrInet6. IPv6 addresses with a
+// port go in brackets, "[2001:db8::1]:53". Port defaults to 53.
+func ParseServer(server string) (syscall.Sockaddr, error) {
+	host, port, err := net.SplitHostPort(ServerAddr(server))
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid server address %q", server)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server port %q", port)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		addr := &syscall.SockaddrInet4{Port: int(p)}
+		copy(addr.Addr[:], ip4)
+		return addr, nil
+	}
+	addr := &syscall.SockaddrInet6{Port: int(p)}
+	copy(addr.Addr[:], ip)
+	return addr, nil
+}
+
+// SerializeRequest encodes request, with the section counts in the header
+// taken from the sections themselves.
+func SerializeRequest(request DnsRequest) []byte {
+	var buf bytes.Buffer
+	header := request.Header
+	header.QdCount = uint16(len(request.Questions))
+	header.AnCount, header.NsCount = 0, 0
+	header.ArCount = uint16(len(request.Additionals))
+	binary.Write(&buf, binary.BigEndian, header)
+	for _, q := range request.Questions {
+		SerializeQuestion(&buf, q)
+	}
+	for _, a := range request.Additionals {
+		SerializeResourceRecord(&buf, a)
+	}
+	return buf.Bytes()
+}
+
+// SerializeResponse encodes response without name compression, with the
+// section counts in the header taken from the sections.
+func SerializeResponse(response DnsResponse) []byte {
+	var buf bytes.Buffer
+	header := response.Header
+	header.QdCount = uint16(len(response.Questions))
+	header.AnCount = uint16(len(response.Answers))
+	header.NsCount = uint16(len(response.Authorities))
+	header.ArCount = uint16(len(response.Additionals))
+	binary.Write(&buf, binary.BigEndian, header)
+	for _, q := range response.Questions {
+		SerializeQuestion(&buf, q)
+	}
+	for _, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, response.Additionals} {
+		for _, r := range section {
+			SerializeResourceRecord(&buf, r)
+		}
+	}
+	return buf.Bytes()
+}
+
+func ReadRequest(msg []byte) (DnsRequest, error) {
+	var request DnsRequest
+	r := bytes.NewReader(msg)
+	err := binary.Read(r, binary.BigEndian, &request.Header)
+	if err != nil {
+		return request, err
+	}
+
+	for i := 0; i < int(request.Header.QdCount); i++ {
+		question, err := ReadQuestion(r)
+		if err != nil {
+			return request, err
+		}
+		request.Questions = append(request.Questions, question)
+	}
+
+	// Queries have no answers or authorities, skip them if someone sent them anyway
+	for i := 0; i < int(request.Header.AnCount)+int(request.Header.NsCount); i++ {
+		_, err := ReadResourceRecord(r)
+		if err != nil {
+			return request, err
+		}
+	}
+
+	for i := 0; i < int(request.Header.ArCount); i++ {
+		additional, err := ReadResourceRecord(r)
+		if err != nil {
+			return request, err
+		}
+		request.Additionals = append(request.Additionals, additional)
+	}
+	return request, nil
+}
+
+func ReadResponse(msg []byte) (DnsResponse, error) {
+	response := DnsResponse{Size: len(msg), Raw: msg}
+	r := bytes.NewReader(msg)
+	err := binary.Read(r, binary.BigEndian, &response.Header)
+	if err != nil {
+		return response, err
+	}
+
+	for i := 0; i < int(response.Header.QdCount); i++ {
+		question, err := ReadQuestion(r)
+		if err != nil {
+			return response, err
+		}
+		response.Questions = append(response.Questions, question)
+	}
+
+	for i := 0; i < int(response.Header.AnCount); i++ {
+		answer, err := ReadResourceRecord(r)
+		if err != nil {
+			return response, err
+		}
+		response.Answers = append(response.Answers, answer)
+	}
+
+	for i := 0; i < int(response.Header.NsCount); i++ {
+		authority, err := ReadResourceRecord(r)
+		if err != nil {
+			return response, err
+		}
+		response.Authorities = append(response.Authorities, authority)
+	}
+
+	for i := 0; i < int(response.Header.ArCount); i++ {
+		additional, err := ReadResourceRecord(r)
+		if err != nil {
+			return response, err
+		}
+		response.Additionals = append(response.Additionals, additional)
+	}
+	return response, nil
+}
+
+// SendMessage sends an already serialized message to server over UDP and
+// returns the raw reply, which may be up to bufSize bytes.
+func SendMessage(server string, msg []byte, bufSize int) ([]byte, error) {
+	return sendMessage(context.Background(), server, msg, bufSize, Timeouts{}, nil)
+}
+
+// udpAddr is ParseServer for the net package.
+func udpAddr(server string) (*net.UDPAddr, error) {
+	sockaddr, err := ParseServer(server)
+	if err != nil {
+		return nil, err
+	}
+	switch sa := sockaddr.(type) {
+	case *syscall.SockaddrInet4:
+		return &net.UDPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}, nil
+	case *syscall.SockaddrInet6:
+		return &net.UDPAddr{IP: net.IP(sa.Addr[:]), Port: sa.Port}, nil
+	}
+	return nil, fmt.Errorf("invalid server address %q", server)
+}
+
+// sendMessage waits for a reply that accept is happy with, dropping any
+// others, if accept is not nil. It gives up when ctx is done.
+func sendMessage(ctx context.Context, server string, msg []byte, bufSize int, timeouts Timeouts, accept func([]byte) bool) ([]byte, error) {
+	addr, err := udpAddr(server)
+	if err != nil {
+		return nil, err
+	}
+	network := "udp6"
+	if addr.IP.To4() != nil {
+		network = "udp4"
+	}
+	conn, err := net.ListenUDP(network, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	conn.SetWriteDeadline(deadline(timeouts.Write))
+	_, err = conn.WriteToUDP(msg, addr)
+	if err != nil {
+		return nil, contextError(ctx, timeoutError(err, "write", server, timeouts.Write))
+	}
+
+	// Anyone can send a datagram to our port, only the server's count. The
+	// read timeout covers all of them, not each one.
+	conn.SetReadDeadline(deadline(timeouts.Read))
+	resBuf := make([]byte, bufSize)
+	for {
+		n, from, err := conn.ReadFromUDP(resBuf)
+		if err != nil {
+			return nil, contextError(ctx, timeoutError(err, "read", server, timeouts.Read))
+		}
+		if from.IP.Equal(addr.IP) && from.Port == addr.Port && (accept == nil || accept(resBuf[:n])) {
+			return resBuf[:n], nil
+		}
+	}
+}
+
+// SendRequest sends the request to server over UDP and returns the parsed response.
+// Datagrams with the wrong id or question are dropped while waiting for the
+// real answer, see ValidateResponseHeader for checking the rest.
+func SendRequest(server string, request DnsRequest) (DnsResponse, error) {
+	return sendRequest(context.Background(), server, request, Timeouts{})
+}
+
+func sendRequest(ctx context.Context, server string, request DnsRequest, timeouts Timeouts) (DnsResponse, error) {
+	var response DnsResponse
+	start := time.Now()
+	_, err := sendMessage(ctx, server, SerializeRequest(request), int(request.UDPSize()), timeouts, func(msg []byte) bool {
+		r, err := ReadResponse(msg)
+		if err != nil || !MatchesRequest(r, request) {
+			return false
+		}
+		response = r
+		return true
+	})
+	if err != nil {
+		return response, err
+	}
+	response.Server = server
+	response.RTT = time.Since(start)
+	response.Transport = "udp"
+	return response, nil
+}
+
+// MatchesRequest reports whether response answers request: same id and same
+// questions. Error responses may leave out the question section, as servers
+// that can't parse a query often do.
+func MatchesRequest(response DnsResponse, request DnsRequest) bool {
+	if response.Header.Id != request.Header.Id {
+		return false
+	}
+	if len(response.Questions) == 0 && response.Header.Flags.RCode() != 0 {
+		return true
+	}
+	if len(response.Questions) != len(request.Questions) {
+		return false
+	}
+	for i, q := range request.Questions {
+		r := response.Questions[i]
+		if r.QType != q.QType || r.QClass != q.QClass || CompareNames(r.QName, q.QName) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ErrQuestionCount is returned for queries without exactly one question. The
+// protocol allows more, but no server answers them (RFC 9619).
+var ErrQuestionCount = errors.New("queries must have exactly one question, send one query per name")
+
+// Client sends queries to a list of servers, trying them in order. Servers are
+// "ip" or "ip:port" for UDP (or TCP), "tls:host[:port]" for DNS over TLS,
+// an https:// URL for DNS over HTTPS, "unix:path" for a unix stream socket
+// and "unixgram:path" for a unix datagram socket.
+type Client struct {
+	Servers []string
+
+	// EDNS UDP payload size advertised in queries built by the client, queries
+	// are sent without EDNS if 0
+	UDPSize uint16
+
+	// Send queries over TCP, keeping connections open as long as the servers
+	// allow through edns-tcp-keepalive
+	TCP bool
+
+	// Set the DO bit on queries built by the client to get DNSSEC records
+	DNSSEC bool
+
+	// Limits for connecting to, sending to and waiting for each server
+	Timeouts Timeouts
+
+	// Base64 SHA-256 SPKI pins (see SPKIPin) for "tls:" servers. If set, the
+	// server must present a certificate matching one of them and the system
+	// CA store is not used.
+	SPKIPins []string
+
+	// Base TLS settings for DoT and DoH servers, e.g. minimum and maximum
+	// versions, client certificates, RootCAs or a ServerName to check the
+	// certificates against instead of the host in the server address
+	TLSConfig *tls.Config
+
+	// How to find the addresses of DoT and DoH servers given by hostname:
+	// fixed addresses, or plain DNS servers to look them up with. The system
+	// resolver is used if both are empty.
+	BootstrapHosts   map[string][]net.IP
+	BootstrapServers []string
+
+	// Where TLS session tickets are kept for resuming sessions with DoT and
+	// DoH servers. A cache for this client is created if nil.
+	TLSSessionCache tls.ClientSessionCache
+
+	// How many times a query is sent to each server, going round the servers
+	// in turn. 0 sends it once.
+	Attempts int
+
+	// The longest wait before sending a query again, DefaultRetryInterval if 0
+	RetryInterval time.Duration
+
+	// How long Exchange may take in all, across retries and servers, however
+	// the Timeouts and Attempts add up. 0 leaves it to them.
+	Budget time.Duration
+
+	// Send the query to all servers at once and take the first real answer.
+	// Failures such as SERVFAIL only win if nothing better comes back. The
+	// other attempts are cancelled, closing their sockets.
+	Race bool
+
+	// HTTP version for DoH servers: HTTPPreferH2 (the default) falls back to
+	// HTTP/1.1 if the server can't do HTTP/2, HTTPOnlyH2 and HTTPOnly1 insist
+	// on one of them. HTTP/3 is not supported.
+	HTTPVersion string
+
+	// Send DoH queries with GET rather than POST. DoH servers can be URI
+	// templates such as https://dns.example/dns-query{?dns}.
+	DoHGet bool
+
+	// Try the servers fastest first rather than in the order given, based
+	// on the smoothed RTT and failures of earlier queries (see ServerStats)
+	SelectByRTT bool
+
+	// Answers are kept here for their TTL if set, see NewCache
+	Cache Cache
+
+	// Limits the queries sent to each server, attempts wait for their turn
+	RateLimit *RateLimiter
+
+	// PrivacyStrict only sends queries to DoT and DoH servers that can be
+	// authenticated. PrivacyOpportunistic settles for unauthenticated TLS, or
+	// plain DNS on the same host, if that is all that works (RFC 8310).
+	Privacy string
+
+	// Called with every query sent and every response received, as the raw
+	// message and parsed, from the goroutines sending them. Changes OnSend
+	// makes to the request are sent, and changes OnReceive makes to the
+	// response are what the client sees.
+	OnSend    func(server string, msg []byte, request *DnsRequest)
+	OnReceive func(server string, msg []byte, response *DnsResponse)
+
+	// Wrapped around Exchange, the first one outermost, see WithMiddleware
+	Middleware []Middleware
+
+	// Plain UDP queries share its sockets rather than opening one each
+	UDPMux *UDPMux
+
+	// Queries to these servers go through their Transport rather than the
+	// client's own, keeping the retries and server selection
+	Transports map[string]Transport
+
+	// EDNSOptions are added to every query sent with EDNS
+	EDNSOptions []EDNSOption
+
+	mu      sync.Mutex
+	conns   map[string]*StreamConn
+	lastRTT time.Duration
+	stats   map[string]*ServerStats
+	edns    map[string]ServerEDNS
+	// Servers whose timeouts are known not to be about EDNS
+	ednsChecked map[string]bool
+
+	httpClients    map[string]*http.Client
+	bootstrapCache map[string]bootstrapEntry
+}
+
+// DefaultRetryInterval is how long the client waits for an answer before
+// sending the query again when it has no better idea.
+const DefaultRetryInterval = time.Second
+
+// Exchange sends request to the servers and returns the complete response
+// from the first one that answers, see Race for sending to all of them. If
+// no answer arrives within the retry interval the query is sent again to the
+// next server, while the earlier attempts keep waiting, and the interval
+// doubles with some jitter each time. An attempt that fails outright moves
+// on to the next server straight away. Attempts still waiting when Exchange
+// returns are cancelled. With a Cache, answers are served from it while they
+// are fresh. All of this runs inside the client's Middleware.
+func (c *Client) Exchange(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+	if len(c.Servers) == 0 {
+		return nil, errors.New("no servers configured")
+	}
+	if len(request.Questions) != 1 {
+		return nil, ErrQuestionCount
+	}
+	if len(c.EDNSOptions) > 0 && request.HasEDNS() {
+		request = withEDNSOptions(*request, c.EDNSOptions)
+	}
+	if len(c.Middleware) > 0 {
+		return chain(c.exchangeCached, c.Middleware)(ctx, request)
+	}
+	return c.exchangeCached(ctx, request)
+}
+
+func (c *Client) exchangeCached(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+	if c.Cache == nil {
+		return stamp(c.exchangeBudget(ctx, request))
+	}
+	var response *DnsResponse
+	var ok bool
+	if mc, isMemory := c.Cache.(*MemoryCache); isMemory {
+		response, ok = mc.get(*request, c.prefetch)
+	} else {
+		response, ok = c.Cache.Get(*request)
+	}
+	if ok {
+		response.Cached = true
+		return stamp(response, nil)
+	}
+	response, err := c.exchangeBudget(ctx, request)
+	if err == nil {
+		c.Cache.Put(*request, *response)
+	}
+	return stamp(response, err)
+}
+
+// exchangeBudget is exchangeServers within the client's Budget. Attempts
+// still in flight when it runs out are abandoned.
+func (c *Client) exchangeBudget(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+	if c.Budget == 0 {
+		return c.exchangeServers(ctx, request)
+	}
+	budgetCtx, cancel := context.WithTimeout(ctx, c.Budget)
+	defer cancel()
+	response, err := c.exchangeServers(budgetCtx, request)
+	if err != nil && ctx.Err() == nil && budgetCtx.Err() != nil {
+		return nil, fmt.Errorf("no answer within %s: %w", c.Budget, err)
+	}
+	return response, err
+}
+
+func stamp(response *DnsResponse, err error) (*DnsResponse, error) {
+	if response != nil {
+		response.Time = time.Now()
+	}
+	return response, err
+}
+
+func (c *Client) exchangeServers(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+	// Whatever is still in flight when an answer wins is given up on
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	attempts := len(c.Servers)
+	if c.Attempts > 1 {
+		attempts *= c.Attempts
+	}
+
+	type result struct {
+		response DnsResponse
+		err      error
+	}
+	servers := c.Servers
+	if c.SelectByRTT {
+		servers = c.rankServers()
+	}
+	done := make(chan result, attempts)
+	sent, pending := 0, 0
+	launch := func() {
+		server := servers[sent%len(servers)]
+		sent++
+		pending++
+		go func() {
+			if c.RateLimit != nil {
+				if err := c.RateLimit.Wait(ctx, server); err != nil {
+					done <- result{err: err}
+					return
+				}
+			}
+			start := time.Now()
+			response, err := c.send(ctx, server, *request)
+			if err == nil || ctx.Err() == nil {
+				// Attempts cut short say nothing about the server
+				c.record(server, time.Since(start), err)
+			}
+			done <- result{response, err}
+		}()
+	}
+
+	interval := c.retryInterval()
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	retransmit := func() {
+		launch()
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(interval)
+		interval = jitter(2 * interval)
+	}
+
+	launch()
+	if c.Race {
+		for sent < len(c.Servers) {
+			launch()
+		}
+	}
+	interval = jitter(2 * interval)
+
+	var err error
+	var fallback *DnsResponse
+	for {
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return nil, fmt.Errorf("%w, last error: %v", ctx.Err(), err)
+			}
+			return nil, ctx.Err()
+		case <-timer.C:
+			if sent < attempts {
+				retransmit()
+			}
+		case res := <-done:
+			pending--
+			if res.err == nil && c.Race && !usableRCode(res.response) && (sent < attempts || pending > 0) {
+				// Another server may still have a real answer
+				fallback = &res.response
+				continue
+			}
+			if res.err == nil {
+				c.mu.Lock()
+				c.lastRTT = res.response.RTT
+				c.mu.Unlock()
+				return &res.response, nil
+			}
+			err = res.err
+			if sent < attempts {
+				retransmit()
+			} else if pending == 0 {
+				if fallback != nil {
+					return fallback, nil
+				}
+				return nil, err
+			}
+		}
+	}
+}
+
+// usableRCode reports whether the server gave an actual answer rather than
+// failing or refusing to resolve the query.
+func usableRCode(response DnsResponse) bool {
+	switch response.Header.Flags.RCode() {
+	case 2, 4, 5: // SERVFAIL, NOTIMP, REFUSED
+		return false
+	}
+	return true
+}
+
+// send makes a single attempt at sending request to server, or a few if
+// the server turns out not to support EDNS or the EDNS version of the
+// request.
+func (c *Client) send(ctx context.Context, server string, request DnsRequest) (DnsResponse, error) {
+	request = c.downgradeEDNS(server, request)
+	for {
+		response, err := c.sendOnce(ctx, server, request)
+		if err == nil {
+			c.answeredEDNS(server, request, response)
+		}
+		if c.dropsEDNS(server, request, err) {
+			// EDNS is only given up on if the query gets through without it
+			plain, plainErr := c.sendOnce(ctx, server, withoutEDNS(request))
+			if plainErr != nil {
+				c.checkedEDNS(server)
+				return response, err
+			}
+			c.learnEDNS(server, ServerEDNS{Disabled: true})
+			return plain, nil
+		}
+		if err != nil || !c.negotiateEDNS(server, request, response) {
+			return response, err
+		}
+		request = c.downgradeEDNS(server, request)
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, server string, request DnsRequest) (DnsResponse, error) {
+	if c.OnSend != nil {
+		// Changes are for this attempt only
+		request.Questions = append([]DnsQuestion{}, request.Questions...)
+		request.Additionals = append([]DnsResourceRecord{}, request.Additionals...)
+		c.OnSend(server, SerializeRequest(request), &request)
+	}
+	var response DnsResponse
+	var err error
+	if t, ok := c.Transports[server]; ok {
+		response, err = exchangeTransport(ctx, t, server, request)
+	} else {
+		response, err = c.sendBuiltin(ctx, server, request)
+	}
+	if err == nil && c.OnReceive != nil {
+		c.OnReceive(server, response.Raw, &response)
+	}
+	return response, err
+}
+
+func (c *Client) sendBuiltin(ctx context.Context, server string, request DnsRequest) (DnsResponse, error) {
+	encrypted := strings.HasPrefix(server, "tls:") || strings.HasPrefix(server, "https://")
+	switch {
+	case encrypted && c.Privacy == PrivacyStrict && c.TLSConfig != nil && c.TLSConfig.InsecureSkipVerify && len(c.SPKIPins) == 0:
+		return DnsResponse{}, fmt.Errorf("strict privacy: %s can't be authenticated with certificate checks disabled", server)
+	case encrypted && c.Privacy == PrivacyOpportunistic:
+		return c.sendOpportunistic(ctx, server, request)
+	case encrypted:
+		return c.sendEncrypted(ctx, server, request, true)
+	case c.Privacy == PrivacyStrict:
+		return DnsResponse{}, fmt.Errorf("strict privacy: %s is not an encrypted server", server)
+	case strings.HasPrefix(server, "unixgram:"):
+		return sendUnixgram(ctx, strings.TrimPrefix(server, "unixgram:"), request, c.Timeouts)
+	case c.TCP || strings.HasPrefix(server, "unix:"):
+		return c.sendStream(ctx, server, request, true)
+	}
+	var response DnsResponse
+	var err error
+	if c.UDPMux != nil {
+		response, err = c.sendMux(ctx, server, request)
+	} else {
+		response, err = sendRequest(ctx, server, request, c.Timeouts)
+	}
+	if err == nil && response.Header.Flags.TC() != 0 {
+		// The full answer didn't fit in a datagram, ask again over TCP
+		return c.sendStream(ctx, server, request, true)
+	}
+	return response, err
+}
+
+func (c *Client) sendEncrypted(ctx context.Context, server string, request DnsRequest, authenticate bool) (DnsResponse, error) {
+	if strings.HasPrefix(server, "https://") {
+		return c.sendDoH(ctx, server, request, authenticate)
+	}
+	return c.sendStream(ctx, server, request, authenticate)
+}
+
+// retryInterval adapts the first retransmission to how fast answers have
+// been coming: twice the last round trip time, but never more than the
+// configured interval.
+func (c *Client) retryInterval() time.Duration {
+	interval := c.RetryInterval
+	if interval <= 0 {
+		interval = DefaultRetryInterval
+	}
+	c.mu.Lock()
+	rtt := c.lastRTT
+	c.mu.Unlock()
+	if rtt > 0 && 2*rtt < interval {
+		interval = 2 * rtt
+		if interval < 50*time.Millisecond {
+			interval = 50 * time.Millisecond
+		}
+	}
+	return interval
+}
+
+// jitter spreads d randomly by up to 25% either way, so clients that lost
+// packets at the same time don't all retry in step.
+func jitter(d time.Duration) time.Duration {
+	if d < 4 {
+		return d
+	}
+	return d - d/4 + time.Duration(rand.Int63n(int64(d/2)))
+}
+
+// exchange sends a single question for name.
+func (c *Client) exchange(ctx context.Context, name string, qtype uint16) (*DnsResponse, error) {
+	request := NewQuery(name, qtype).SetEDNS(c.UDPSize)
+	if c.DNSSEC {
+		request.SetDO(true)
+	}
+	return c.Exchange(ctx, request)
+}
+
+// Query sends a single question for name with any type number, including
+// private use and experimental types nothing is registered for. Their RData
+// is kept as is and formatted in the RFC 3597 \# notation.
+func (c *Client) Query(ctx context.Context, name string, qtype uint16) (*DnsResponse, error) {
+	return c.exchange(ctx, name, qtype)
+}
@@ -0,0 +1,334 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+func TestClientExchange(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.HandleA("www.example.com", net.IPv4(192, 0, 2, 1), net.ParseIP("2001:db8::1"))
+
+	tests := []struct {
+		name      string
+		qtype     uint16
+		transport string
+		rcode     uint16
+		answers   int
+	}{
+		{"www.example.com", A, TransportUDP, 0, 1},
+		{"WWW.Example.com.", AAAA, TransportUDP, 0, 1},
+		{"www.example.com", A, TransportTCP, 0, 1},
+		{"www.example.com", MX, TransportUDP, 0, 0},
+		{"nx.example.com", A, TransportUDP, 3, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name+" "+TypeToString(test.qtype)+" "+test.transport, func(t *testing.T) {
+			client := NewClient(WithServers(server.Addr()), WithTransport(test.transport), WithTimeout(time.Second))
+			defer client.Close()
+			response, err := client.Query(context.Background(), test.name, test.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Header.Flags.RCode() != test.rcode || len(response.Answers) != test.answers {
+				t.Errorf("got rcode %d with %d answers, want %d with %d", response.Header.Flags.RCode(), len(response.Answers), test.rcode, test.answers)
+			}
+		})
+	}
+}
+
+func TestClientPipe(t *testing.T) {
+	errRefused := errors.New("connection refused")
+	tests := []struct {
+		name string
+		// Steps for the first and the second server
+		first, second []dnstest.Step
+		attempts      int
+		// Messages each server should see
+		firstSeen, secondSeen int
+		err                   bool
+	}{
+		{"answered", nil, nil, 1, 1, 0, false},
+		{"dropped then answered", []dnstest.Step{{Drop: true}}, nil, 2, 2, 0, false},
+		// A server that drops a query gets it once more without EDNS
+		{"dropped, retried without EDNS", []dnstest.Step{{Drop: true}}, nil, 1, 2, 0, false},
+		{"dropped twice, next server", []dnstest.Step{{Drop: true}, {Drop: true}}, nil, 1, 2, 1, false},
+		{"error, next server", []dnstest.Step{{Err: errRefused}}, nil, 1, 1, 1, false},
+		{"slow", []dnstest.Step{{Delay: 5 * time.Millisecond}}, nil, 1, 1, 0, false},
+		{"both fail", []dnstest.Step{{Err: errRefused}}, []dnstest.Step{{Err: errRefused}}, 1, 1, 1, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first, second := dnstest.NewPipe(), dnstest.NewPipe()
+			for _, pipe := range []*dnstest.Pipe{first, second} {
+				pipe.Timeout = 20 * time.Millisecond
+				pipe.HandleA("example.com", net.IPv4(192, 0, 2, 1))
+			}
+			first.Script(test.first...)
+			second.Script(test.second...)
+			client := NewClient(
+				WithServers("first", "second"),
+				WithServerTransport("first", first),
+				WithServerTransport("second", second),
+				WithAttempts(test.attempts),
+			)
+			response, err := client.Query(context.Background(), "example.com", A)
+			if test.err {
+				if err == nil {
+					t.Fatalf("Query() = %v, want an error", response)
+				}
+			} else if err != nil || len(response.Answers) != 1 {
+				t.Fatalf("Query() = %v, %v", response, err)
+			}
+			if len(first.Messages()) != test.firstSeen || len(second.Messages()) != test.secondSeen {
+				t.Errorf("servers saw %d and %d messages, want %d and %d",
+					len(first.Messages()), len(second.Messages()), test.firstSeen, test.secondSeen)
+			}
+		})
+	}
+}
+
+// givesUp checks that query, run against a server that never answers with
+// timeouts too long to matter, fails once ctx is done.
+func givesUp(t *testing.T, query func(ctx context.Context, server string, opts ...Option) error) {
+	t.Helper()
+	dead := dnstest.DeadServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := query(ctx, dead, WithTimeout(time.Hour)); err == nil {
+		t.Error("query to a server that never answers didn't fail")
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("query took %s, past its deadline", d)
+	}
+}
+
+func TestClientTimesOut(t *testing.T) {
+	pipe := dnstest.NewPipe()
+	pipe.Timeout = time.Hour
+	pipe.Script(dnstest.Step{Drop: true})
+	client := NewClient(WithServers("pipe"), WithServerTransport("pipe", pipe), WithAttempts(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Query(ctx, "example.com", A); err == nil {
+		t.Fatal("Query() of a dropped message didn't fail")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Query() took %s, past its deadline", d)
+	}
+}
+
+func TestParseServer(t *testing.T) {
+	tests := []struct {
+		server string
+		want   syscall.Sockaddr
+	}{
+		{"192.0.2.1", &syscall.SockaddrInet4{Port: 53, Addr: [4]byte{192, 0, 2, 1}}},
+		{"192.0.2.1:5353", &syscall.SockaddrInet4{Port: 5353, Addr: [4]byte{192, 0, 2, 1}}},
+		{"2001:db8::1", &syscall.SockaddrInet6{Port: 53, Addr: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}},
+		{"[2001:db8::1]", &syscall.SockaddrInet6{Port: 53, Addr: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}}},
+		{"[::1]:5353", &syscall.SockaddrInet6{Port: 5353, Addr: [16]byte{15: 1}}},
+		{"dns.example", nil},
+		{"192.0.2.1:dns", nil},
+		{"192.0.2.1:70000", nil},
+	}
+	for _, test := range tests {
+		got, err := ParseServer(test.server)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("ParseServer(%q) = %v, want an error", test.server, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseServer(%q) = %v, %v, want %v", test.server, got, err, test.want)
+		}
+	}
+}
+
+func TestClientIPv6(t *testing.T) {
+	pc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skip("no IPv6 loopback:", err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := ReadRequest(buf[:n])
+			if err != nil {
+				continue
+			}
+			pc.WriteTo(SerializeResponse(DnsResponse{Header: DnsHeader{Id: request.Header.Id, Flags: 0x8180}, Questions: request.Questions}), addr)
+		}
+	}()
+
+	mux, err := NewUDPMux(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+	for _, opts := range [][]Option{nil, {WithUDPMux(mux)}} {
+		client := NewClient(append(opts, WithServers(pc.LocalAddr().String()), WithTimeout(time.Second))...)
+		if _, err := client.Query(context.Background(), "example.com", A); err != nil {
+			t.Errorf("Query() to %s: %v", pc.LocalAddr(), err)
+		}
+	}
+}
+
+func TestClientTruncated(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.Handle("big.example.com", TXT, dnstest.Response{Truncated: true, Answers: []dnstest.Record{
+		{Name: "big.example.com", Type: TXT, TTL: 300, RData: []byte("\x05hello")},
+	}})
+	client := NewClient(WithServers(server.Addr()), WithTimeout(time.Second))
+	defer client.Close()
+	response, err := client.Query(context.Background(), "big.example.com", TXT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Header.Flags.TC() != 0 || len(response.Answers) != 1 || response.Transport != TransportTCP {
+		t.Errorf("got tc %d with %d answers over %s, want the full answer over TCP", response.Header.Flags.TC(), len(response.Answers), response.Transport)
+	}
+	if n := server.Queries(); n != 2 {
+		t.Errorf("server got %d queries, want 2", n)
+	}
+}
+
+func TestClientHTTP3(t *testing.T) {
+	client := NewClient(WithServers("https://dns.example/dns-query"), WithHTTPVersion("h3"), WithAttempts(1))
+	if _, err := client.Query(context.Background(), "example.com", A); !errors.Is(err, ErrHTTP3) {
+		t.Errorf("Query() over h3 error = %v, want %v", err, ErrHTTP3)
+	}
+}
+
+func TestClientNoEDNSOverTCP(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.RejectEDNS()
+	server.HandleA("www.example.com", net.IPv4(192, 0, 2, 1))
+	server.Handle("big.example.com", TXT, dnstest.Response{Truncated: true, Answers: []dnstest.Record{
+		{Name: "big.example.com", Type: TXT, TTL: 300, RData: []byte("\x05hello")},
+	}})
+
+	tests := []struct {
+		name      string
+		qtype     uint16
+		transport string
+		bufsize   uint16
+	}{
+		// Downgraded over UDP, then truncated and asked again over TCP
+		{"big.example.com", TXT, TransportUDP, DefaultUDPSize},
+		{"www.example.com", A, TransportTCP, DefaultUDPSize},
+		{"www.example.com", A, TransportTCP, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name+" "+test.transport, func(t *testing.T) {
+			client := NewClient(WithServers(server.Addr()), WithTransport(test.transport), WithEDNS(test.bufsize), WithTimeout(time.Second))
+			defer client.Close()
+			response, err := client.Query(context.Background(), test.name, test.qtype)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The server answers FORMERR to anything with an OPT record
+			if response.Header.Flags.RCode() != 0 || len(response.Answers) != 1 || response.Transport != TransportTCP {
+				t.Errorf("got rcode %s with %d answers over %s, want an answer over TCP without EDNS",
+					RCodeToString(response.Header.Flags.RCode()), len(response.Answers), response.Transport)
+			}
+		})
+	}
+}
+
+// waitGoroutines waits a little for the number of goroutines to fall back
+// to n, as the ones of abandoned attempts wind down.
+func waitGoroutines(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < 100 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > n {
+		buf := make([]byte, 1<<16)
+		t.Fatalf("%d goroutines left, want %d:\n%s", got, n, buf[:runtime.Stack(buf, true)])
+	}
+}
+
+func TestClientRaceCancels(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.HandleA("www.example.com", net.IPv4(192, 0, 2, 1))
+	dead := dnstest.DeadServer(t)
+	mux, err := NewUDPMux(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"udp", nil},
+		{"tcp", []Option{WithTransport(TransportTCP)}},
+		{"mux", []Option{WithUDPMux(mux)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+			// No timeouts at all, only the cancellation ends the dead attempt
+			client := NewClient(append(test.opts, WithServers(dead, server.Addr()), WithRace())...)
+			response, err := client.Query(context.Background(), "www.example.com", A)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if response.Server != server.Addr() {
+				t.Errorf("answer from %s, want %s", response.Server, server.Addr())
+			}
+			client.Close()
+			waitGoroutines(t, before)
+		})
+	}
+}
+
+func TestClientBudgetCancels(t *testing.T) {
+	dead := dnstest.DeadServer(t)
+	before := runtime.NumGoroutine()
+	// Retries every 10ms, each waiting forever but for the budget
+	client := NewClient(WithServers(dead), WithAttempts(5), WithBudget(100*time.Millisecond))
+	client.RetryInterval = 10 * time.Millisecond
+	start := time.Now()
+	if _, err := client.Query(context.Background(), "www.example.com", A); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Query() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Query() took %s with a budget of 100ms", d)
+	}
+	waitGoroutines(t, before)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// cacheMain implements the "cache" subcommand, which talks to the control API
+// of "serve -cache -control addr".
+func cacheMain(args []string) {
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	control := flags.String("control", dnsclient.DefaultControlAddr, "address of the forwarder's control API")
+	flags.Parse(args)
+	usage := func() {
+		fmt.Fprintln(os.Stderr, "usage: dns-client cache [-control addr] stats | dump | flush name | flush-zone zone")
+		os.Exit(dnsclient.ExitUsage)
+	}
+
+	base := "http://" + *control + "/cache"
+	var resp *http.Response
+	var err error
+	switch flags.Arg(0) {
+	case "stats":
+		resp, err = http.Get(base + "/stats")
+	case "dump":
+		resp, err = http.Get(base)
+	case "flush", "flush-zone":
+		if flags.NArg() != 2 {
+			usage()
+		}
+		param := "name"
+		if flags.Arg(0) == "flush-zone" {
+			param = "zone"
+		}
+		resp, err = http.PostForm(base+"/flush?"+param+"="+url.QueryEscape(flags.Arg(1)), nil)
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitFailure)
+	}
+	defer resp.Body.Close()
+	io.Copy(os.Stdout, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		os.Exit(dnsclient.ExitFailure)
+	}
+}
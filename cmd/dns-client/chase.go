@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// chaseMain implements the "chase" subcommand.
+func chaseMain(config dnsclient.Config, args []string) {
+	flags := flag.NewFlagSet("chase", flag.ExitOnError)
+	server := flags.String("server", strings.Join(config.Servers, ","), "comma separated list of servers to query")
+	typeName := flags.String("type", "A", "type of the records to validate at the end of the chain")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dns-client chase [-server addr] [-type A] name")
+		os.Exit(dnsclient.ExitUsage)
+	}
+	qtype, err := dnsclient.StringToType(*typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+
+	anchors, err := dnsclient.RootTrustAnchors()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	client := dnsclient.NewClient(dnsclient.WithServers(strings.Split(*server, ",")...), dnsclient.WithDNSSEC())
+	secure, err := dnsclient.ChaseChain(context.Background(), client, flags.Arg(0), qtype, anchors, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitFailure)
+	}
+	if !secure {
+		fmt.Println("Chain of trust: broken")
+		os.Exit(dnsclient.ExitFailure)
+	}
+	fmt.Println("Chain of trust: secure")
+}
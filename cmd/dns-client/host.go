@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// hostMain behaves like host(1): "host [-t type] [-W wait] [-R retries] [-T]
+// [-r] name [server]" prints one line per record, for the A, AAAA and MX
+// records of name unless -t asks for another type.
+func hostMain(config dnsclient.Config, args []string) {
+	flags := flag.NewFlagSet("host", flag.ContinueOnError)
+	typeName := flags.String("t", "", "query type")
+	wait := flags.Int("W", 5, "seconds to wait for an answer")
+	retries := flags.Int("R", 1, "how many times to retry")
+	tcp := flags.Bool("T", false, "query over TCP")
+	norecurse := flags.Bool("r", false, "non-recursive query")
+	if flags.Parse(args) != nil || flags.NArg() < 1 || flags.NArg() > 2 {
+		fmt.Fprintln(os.Stderr, "usage: host [-t type] [-W wait] [-R retries] [-T] [-r] name [server]")
+		os.Exit(1)
+	}
+
+	qtypes := []uint16{dnsclient.A, dnsclient.AAAA, dnsclient.MX}
+	if *typeName != "" {
+		t, err := dnsclient.StringToType(*typeName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "host: %v\n", err)
+			os.Exit(1)
+		}
+		qtypes = []uint16{t}
+	}
+	name := flags.Arg(0)
+	if net.ParseIP(name) != nil {
+		name, _ = dnsclient.ReverseName(name)
+		qtypes = []uint16{dnsclient.PTR}
+	}
+
+	servers := config.Servers
+	if flags.NArg() == 2 {
+		server := flags.Arg(1)
+		servers = []string{server}
+		fmt.Printf("Using domain server:\nName: %s\nAddress: %s\nAliases: \n\n", server, nslookupAddress(dnsclient.ServerAddr(server)))
+	}
+	transport := dnsclient.TransportUDP
+	if *tcp {
+		transport = dnsclient.TransportTCP
+	}
+	client := dnsclient.NewClient(dnsclient.WithServers(servers...), dnsclient.WithTransport(transport), dnsclient.WithTimeout(time.Duration(*wait)*time.Second), dnsclient.WithAttempts(*retries+1))
+	defer client.Close()
+
+	if !host(client, name, qtypes, !*norecurse, os.Stdout) {
+		os.Exit(1)
+	}
+}
+
+// host looks name up with each type and prints the records found the way
+// host(1) does. It reports whether the name was found.
+func host(client *dnsclient.Client, name string, qtypes []uint16, recurse bool, out io.Writer) bool {
+	printed := make(map[string]bool)
+	for _, t := range qtypes {
+		response, err := client.Exchange(context.Background(), dnsclient.NewQuery(name, t).SetRD(recurse))
+		var timeout *dnsclient.TimeoutError
+		if errors.As(err, &timeout) {
+			fmt.Fprintln(out, ";; connection timed out; no servers could be reached")
+			return false
+		}
+		if err != nil {
+			fmt.Fprintf(out, ";; %v\n", err)
+			return false
+		}
+		if rcode := response.Header.Flags.RCode(); rcode != 0 {
+			fmt.Fprintf(out, "Host %s not found: %d(%s)\n", dnsclient.ToUnicode(name), rcode, dnsclient.RCodeToString(rcode))
+			return false
+		}
+		for _, r := range response.Answers {
+			// The CNAMEs come back with every type, host prints them once
+			line := hostRecord(r)
+			if !printed[line] {
+				printed[line] = true
+				fmt.Fprintln(out, line)
+			}
+		}
+		if len(response.Answers) == 0 && len(qtypes) == 1 {
+			fmt.Fprintf(out, "%s has no %s record\n", dnsclient.ToUnicode(name), dnsclient.TypeToString(t))
+		}
+	}
+	return true
+}
+
+// hostRecord formats a record the way host(1) does.
+func hostRecord(r dnsclient.DnsResourceRecord) string {
+	name := dnsclient.ToUnicode(r.Name)
+	rdata := r.RDataString()
+	switch r.Type {
+	case dnsclient.A:
+		return fmt.Sprintf("%s has address %s", name, rdata)
+	case dnsclient.AAAA:
+		return fmt.Sprintf("%s has IPv6 address %s", name, rdata)
+	case dnsclient.CNAME:
+		return fmt.Sprintf("%s is an alias for %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.MX:
+		return fmt.Sprintf("%s mail is handled by %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.NS:
+		return fmt.Sprintf("%s name server %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.PTR:
+		return fmt.Sprintf("%s domain name pointer %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.TXT:
+		return fmt.Sprintf("%s descriptive text %s", name, rdata)
+	}
+	return fmt.Sprintf("%s has %s record %s", name, dnsclient.TypeToString(r.Type), rdata)
+}
@@ -0,0 +1,651 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// subcommands run instead of a query when their name comes first. To query
+// a name that is also a subcommand put "--" before it or give it with the
+// trailing dot, "dns-client ping.". A subcommand that needs arguments and has
+// none is taken as a query for its name too.
+var subcommands = map[string]struct {
+	run       func(config dnsclient.Config, args []string)
+	needsArgs bool
+}{
+	"nslookup": {nslookupMain, false},
+	"host":     {hostMain, true},
+	"serve":    {func(config dnsclient.Config, args []string) { serveMain(config, "serve", args) }, false},
+	"proxy":    {func(config dnsclient.Config, args []string) { serveMain(config, "proxy", args) }, false},
+	"cache":    {func(_ dnsclient.Config, args []string) { cacheMain(args) }, true},
+	"update":   {updateMain, false},
+	"announce": {func(_ dnsclient.Config, args []string) { announceMain(args) }, true},
+	"ping":     {pingMain, true},
+	"chase":    {chaseMain, true},
+}
+
+func main() {
+	config, err := dnsclient.LoadConfig(dnsclient.ConfigPath())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+
+	// Symlinked as nslookup or host it behaves like them
+	switch filepath.Base(os.Args[0]) {
+	case "nslookup":
+		nslookupMain(config, os.Args[1:])
+		return
+	case "host":
+		hostMain(config, os.Args[1:])
+		return
+	}
+	if len(os.Args) > 1 {
+		if sub, ok := subcommands[os.Args[1]]; ok && (len(os.Args) > 2 || !sub.needsArgs) {
+			sub.run(config, os.Args[2:])
+			return
+		}
+	}
+
+	server := flag.String("server", strings.Join(config.Servers, ","), "comma separated list of servers to query")
+	typeName := flag.String("type", dnsclient.TypeToString(config.Type), "query type, as a name (AAAA), a number or TYPE####")
+	className := flag.String("class", "IN", "query class, as a name (CH), a number or CLASS####")
+	reverse := flag.Bool("x", false, "reverse lookup: treat names as ip addresses and query their PTR records")
+	chaos := flag.Bool("chaos", false, "query CH TXT server identification names (version.bind, hostname.bind, id.server by default)")
+	dual := flag.Bool("dual", false, "look up A and AAAA records concurrently and print the merged addresses")
+	batch := flag.String("f", "", "read names to resolve from a file (- for stdin), one name or name/type per line")
+	workers := flag.Int("workers", 16, "number of concurrent queries with -f")
+	noColor := flag.Bool("no-color", false, "don't color the output, even on a terminal")
+	human := flag.Bool("human", false, "print TTLs as durations with the time records expire, and when the response arrived")
+	format := flag.String("format", "", "print each response through a Go text/template, e.g. '{{range .Answers}}{{rdata .}}\\n{{end}}'")
+	jsonOutput := flag.Bool("json", config.Output == "json", "print one JSON object per query, a line each (NDJSON)")
+	csvOutput := flag.Bool("csv", config.Output == "csv", "print one name,type,ttl,rdata,rcode,server,rtt row per answer")
+	bufsize := flag.Uint("bufsize", uint(config.UDPSize), "EDNS UDP payload size to advertise, 0 to send queries without EDNS")
+	tcp := flag.Bool("tcp", config.Transport == dnsclient.TransportTCP, "send queries over TCP")
+	norecurse := flag.Bool("norecurse", false, "clear the RD bit, for asking authoritative servers directly")
+	adFlag := flag.Bool("ad", false, "set the AD bit, asking the resolver to say whether the answer validated with DNSSEC")
+	cdFlag := flag.Bool("cd", false, "set the CD bit, asking the resolver not to validate with DNSSEC")
+	attempts := flag.Int("attempts", 3, "how many times to send a query to each server when no answer comes back")
+	race := flag.Bool("race", false, "send queries to all servers at once and use the first answer")
+	fastest := flag.Bool("fastest", false, "try the fastest servers first, measured as queries go (useful with -f)")
+	pin := flag.String("pin", "", "comma separated base64 SHA-256 SPKI pins the tls: servers must match, instead of trusting the system CAs")
+	httpVersion := flag.String("http", "", "HTTP version for https:// servers: h2 or http/1.1 to insist on one, default prefers h2")
+	caFile := flag.String("cafile", "", "PEM file with the CAs to trust for tls: and https:// servers instead of the system ones")
+	certFile := flag.String("cert", "", "PEM client certificate for tls: and https:// servers")
+	keyFile := flag.String("key", "", "PEM private key of the -cert client certificate")
+	tlsName := flag.String("tls-name", "", "name to check tls: and https:// server certificates against, instead of the host in -server")
+	dohGet := flag.Bool("get", false, "send queries to https:// servers with GET instead of POST")
+	bootstrap := flag.String("bootstrap", "", "how to find tls: and https:// servers given by name: comma separated plain DNS servers and host=ip entries")
+	privacy := flag.String("privacy", "", "for tls: and https:// servers: strict fails unless the server is authenticated, opportunistic falls back to unauthenticated TLS and then cleartext")
+	cache := flag.Bool("cache", false, "cache answers for their TTL and refresh popular ones before they expire (useful with -f)")
+	qps := flag.Float64("qps", 0, "send each server at most this many queries a second (useful with -f), 0 for no limit")
+	budget := flag.Duration("budget", 0, "how long each lookup may take in all, across retries and servers, 0 for no limit beyond -timeout and -attempts")
+	timeout := flag.Duration("timeout", config.Timeout, "how long to wait for each of connecting, sending and reading the answer, 0 to wait forever")
+	dns64 := flag.Bool("dns64", false, "detect whether the server does DNS64 and print its NAT64 prefixes")
+	nat64 := flag.String("nat64", "", "look up AAAA records, synthesizing them from A records with this NAT64 prefix (e.g. 64:ff9b::/96)")
+	anchors := flag.Bool("anchors", false, "print the DNSSEC root trust anchors")
+	dnssec := flag.Bool("dnssec", config.DNSSEC, "request DNSSEC records and check the NSEC/NSEC3 proof of negative answers, without validating its signatures")
+	dsMode := flag.Bool("ds", false, "fetch the DNSKEY records of each zone and print the DS records the parent should publish for its KSKs")
+	knownHosts := flag.String("known-hosts", "", "check the keys in an ssh known_hosts file against the SSHFP records of each host")
+	zonemd := flag.Bool("zonemd", false, "transfer each zone with AXFR and verify its ZONEMD digest")
+	trace := flag.Bool("trace", false, "resolve iteratively from the root servers and print each referral on the way, like dig +trace")
+	traceGraph := flag.String("trace-graph", "", "with -trace, print the delegation path as a Graphviz graph (dot) or an ASCII tree (tree)")
+	diff := flag.Bool("diff", false, "send the query to every server and print the differences between the answers")
+	args, err := dnsclient.DigArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	flag.CommandLine.Parse(args)
+
+	var urls = flag.Args()
+	qtype, err := dnsclient.StringToType(*typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	// Types may follow the names instead: dns-client example.com A AAAA MX
+	qtypes := []uint16{qtype}
+	var trailing []uint16
+	for len(urls) > 1 && !strings.Contains(urls[len(urls)-1], ".") {
+		t, err := dnsclient.StringToType(urls[len(urls)-1])
+		if err != nil {
+			break
+		}
+		trailing = append([]uint16{t}, trailing...)
+		urls = urls[:len(urls)-1]
+	}
+	if len(trailing) > 0 {
+		qtype, qtypes = trailing[0], trailing
+	}
+	qclass, err := dnsclient.StringToClass(*className)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	if *bufsize > 65535 {
+		fmt.Fprintln(os.Stderr, "-bufsize must be at most 65535")
+		os.Exit(dnsclient.ExitUsage)
+	}
+	servers := strings.Split(*server, ",")
+	var pins []string
+	if *pin != "" {
+		pins = strings.Split(*pin, ",")
+	}
+	switch *httpVersion {
+	case dnsclient.HTTPPreferH2, dnsclient.HTTPOnlyH2, dnsclient.HTTPOnly1:
+	case "h3":
+		fmt.Fprintf(os.Stderr, "invalid -http h3: %v\n", dnsclient.ErrHTTP3)
+		os.Exit(dnsclient.ExitUsage)
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -http %q, must be h2 or http/1.1\n", *httpVersion)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	if *privacy != dnsclient.PrivacyNone && *privacy != dnsclient.PrivacyStrict && *privacy != dnsclient.PrivacyOpportunistic {
+		fmt.Fprintf(os.Stderr, "invalid -privacy %q, must be strict or opportunistic\n", *privacy)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	tlsConfig, err := dnsclient.LoadTLSConfig(*caFile, *certFile, *keyFile, *tlsName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	bootstrapHosts := map[string][]net.IP{}
+	var bootstrapServers []string
+	if *bootstrap != "" {
+		for _, b := range strings.Split(*bootstrap, ",") {
+			i := strings.Index(b, "=")
+			if i < 0 {
+				bootstrapServers = append(bootstrapServers, b)
+				continue
+			}
+			host, addr := b[:i], net.ParseIP(b[i+1:])
+			if addr == nil {
+				fmt.Fprintf(os.Stderr, "invalid bootstrap address %q\n", b)
+				os.Exit(dnsclient.ExitUsage)
+			}
+			bootstrapHosts[host] = append(bootstrapHosts[host], addr)
+		}
+	}
+	opts := []dnsclient.Option{
+		dnsclient.WithServers(servers...),
+		dnsclient.WithEDNS(uint16(*bufsize)),
+		dnsclient.WithTimeout(*timeout),
+		dnsclient.WithAttempts(*attempts),
+		dnsclient.WithBudget(*budget),
+		dnsclient.WithSPKIPins(pins...),
+		dnsclient.WithHTTPVersion(*httpVersion),
+		dnsclient.WithTLSConfig(tlsConfig),
+		dnsclient.WithPrivacy(*privacy),
+		dnsclient.WithBootstrap(bootstrapHosts, bootstrapServers...),
+		dnsclient.WithEDNSOptions(config.EDNSOptions...),
+	}
+	if *tcp {
+		opts = append(opts, dnsclient.WithTransport(dnsclient.TransportTCP))
+	}
+	if *qps > 0 {
+		opts = append(opts, dnsclient.WithRateLimit(dnsclient.NewRateLimiter(*qps)))
+	}
+	if *race {
+		opts = append(opts, dnsclient.WithRace())
+	}
+	if *fastest {
+		opts = append(opts, dnsclient.WithFastestFirst())
+	}
+	if *dohGet {
+		opts = append(opts, dnsclient.WithDoHGet())
+	}
+	if *cache {
+		opts = append(opts, dnsclient.WithCache(dnsclient.NewCache()))
+	}
+	newClient := func() *dnsclient.Client {
+		return dnsclient.NewClient(opts...)
+	}
+
+	if *chaos {
+		if len(urls) == 0 {
+			urls = dnsclient.ChaosNames
+		}
+		code := dnsclient.ExitOK
+		for _, s := range servers {
+			for _, u := range urls {
+				strs, err := dnsclient.ChaosQuery(context.Background(), s, u, opts...)
+				if err != nil {
+					fmt.Printf("%s %s: %v\n", s, u, err)
+					code = dnsclient.ExitFailure
+					continue
+				}
+				fmt.Printf("%s %s: %s\n", s, u, strings.Join(strs, " "))
+			}
+		}
+		os.Exit(code)
+	}
+
+	if *batch != "" {
+		in := os.Stdin
+		if *batch != "-" {
+			in, err = os.Open(*batch)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(dnsclient.ExitUsage)
+			}
+			defer in.Close()
+		}
+		code := dnsclient.ExitOK
+		client := newClient()
+		w := csv.NewWriter(os.Stdout)
+		if *csvOutput {
+			w.Write(dnsclient.CSVHeader)
+		}
+		for result := range dnsclient.ResolveBatch(context.Background(), client, in, qtype, *workers) {
+			if result.Err != nil {
+				code = dnsclient.ExitFailure
+			}
+			switch {
+			case *jsonOutput:
+				dnsclient.WriteJSON(os.Stdout, result.Question, result.Response, result.Err)
+			case !*csvOutput:
+				fmt.Printf("{ %s }\n", result)
+			case result.Err != nil:
+				dnsclient.WriteCSVError(w, result.Question, result.Err)
+			default:
+				w.WriteAll(dnsclient.CSVRecords(result.Question, result.Response))
+			}
+			w.Flush()
+		}
+		client.Close()
+		os.Exit(code)
+	}
+
+	if *anchors {
+		ds, err := dnsclient.RootTrustAnchors()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		for _, d := range ds {
+			fmt.Printf(". IN DS %s\n", d)
+		}
+		if len(ds) == 0 {
+			os.Exit(dnsclient.ExitFailure)
+		}
+		os.Exit(dnsclient.ExitOK)
+	}
+
+	if *dns64 {
+		client := newClient()
+		prefixes, err := client.DiscoverNAT64Prefixes(context.Background())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitNoData)
+		}
+		for _, p := range prefixes {
+			fmt.Println(p)
+		}
+		os.Exit(dnsclient.ExitOK)
+	}
+
+	if len(urls) == 0 {
+		urls = []string{"github.com"}
+	}
+
+	if *dsMode {
+		client := newClient()
+		code := dnsclient.ExitOK
+		for _, u := range urls {
+			response, err := client.Query(context.Background(), u, dnsclient.DNSKEY)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", u, err)
+				code = dnsclient.ExitFailure
+				continue
+			}
+			for _, a := range response.Answers {
+				key, err := dnsclient.ParseDNSKEY(a.RData)
+				if a.Type != dnsclient.DNSKEY || err != nil || key.Flags&dnsclient.DNSKEYFlagSEP == 0 {
+					continue
+				}
+				for _, digestType := range []uint8{dnsclient.DigestSHA256, dnsclient.DigestSHA384} {
+					ds, err := dnsclient.NewDS(a.Name, key, digestType)
+					if err != nil {
+						continue
+					}
+					fmt.Printf("%s. IN DS %s\n", a.Name, ds)
+				}
+			}
+		}
+		os.Exit(code)
+	}
+
+	if *zonemd {
+		code := dnsclient.ExitOK
+		for _, u := range urls {
+			records, err := dnsclient.Transfer(servers[0], u)
+			if err == nil {
+				err = dnsclient.VerifyZONEMD(u, records)
+			}
+			if err != nil {
+				fmt.Printf("%s: %v\n", u, err)
+				code = dnsclient.ExitFailure
+				continue
+			}
+			fmt.Printf("%s: ZONEMD verified (%d records)\n", u, len(records))
+		}
+		os.Exit(code)
+	}
+
+	if *knownHosts != "" {
+		data, err := os.ReadFile(*knownHosts)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		client := newClient()
+		code := dnsclient.ExitOK
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			kh, err := dnsclient.ParseKnownHostsLine(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				code = dnsclient.ExitFailure
+				continue
+			}
+			for _, host := range kh.Hosts {
+				// Hashed and negated entries don't give us a name to look up
+				if strings.HasPrefix(host, "|") || strings.HasPrefix(host, "!") || strings.ContainsAny(host, "*?") {
+					continue
+				}
+				if h, _, err := net.SplitHostPort(host); err == nil {
+					host = strings.Trim(h, "[]")
+				}
+				ok, err := client.VerifyHostKey(context.Background(), host, kh.Key)
+				switch {
+				case err != nil:
+					fmt.Printf("%s %s: %v\n", host, kh.KeyType, err)
+					code = dnsclient.ExitFailure
+				case ok:
+					fmt.Printf("%s %s: matches SSHFP\n", host, kh.KeyType)
+				default:
+					fmt.Printf("%s %s: does not match SSHFP\n", host, kh.KeyType)
+					code = dnsclient.ExitFailure
+				}
+			}
+		}
+		os.Exit(code)
+	}
+
+	if *nat64 != "" {
+		_, prefix, err := net.ParseCIDR(*nat64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		client := newClient()
+		code := dnsclient.ExitOK
+		for _, u := range urls {
+			ips, err := client.LookupIP64(context.Background(), u, prefix)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", u, err)
+				code = dnsclient.ExitFailure
+				continue
+			}
+			for _, ip := range ips {
+				fmt.Printf("%s %s\n", u, ip)
+			}
+		}
+		os.Exit(code)
+	}
+
+	if *dual {
+		code := dnsclient.ExitOK
+		for _, u := range urls {
+			ips, err := dnsclient.DualStackLookup(context.Background(), servers[0], u, opts...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", u, err)
+				code = dnsclient.ExitFailure
+				continue
+			}
+			for _, ip := range ips {
+				fmt.Printf("%s %s\n", u, ip)
+			}
+		}
+		os.Exit(code)
+	}
+
+	if *reverse {
+		for i, u := range urls {
+			name, err := dnsclient.ReverseName(u)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(dnsclient.ExitUsage)
+			}
+			urls[i] = name
+		}
+		qtypes = []uint16{dnsclient.PTR}
+	}
+
+	// Servers don't answer messages with several questions (RFC 9619), each
+	// name and type gets its own query and the exit code is the worst outcome
+	var requests []*dnsclient.DnsRequest
+	for _, u := range urls {
+		for _, t := range qtypes {
+			name := u
+			// Email addresses are looked up under their hashed owner name
+			if (t == dnsclient.OPENPGPKEY || t == dnsclient.SMIMEA) && strings.Contains(u, "@") {
+				name, err = dnsclient.OpenPGPKeyName(u)
+				if t == dnsclient.SMIMEA {
+					name, err = dnsclient.SMIMEAName(u)
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					os.Exit(dnsclient.ExitUsage)
+				}
+			}
+			request := dnsclient.NewQuery(name, t).SetClass(qclass).SetEDNS(uint16(*bufsize)).SetRD(!*norecurse).SetAD(*adFlag).SetCD(*cdFlag)
+			if *dnssec {
+				request.SetDO(true)
+			}
+			requests = append(requests, request)
+		}
+	}
+
+	if *diff {
+		if len(servers) < 2 {
+			fmt.Fprintln(os.Stderr, "-diff needs at least two servers")
+			os.Exit(dnsclient.ExitUsage)
+		}
+		for _, request := range requests {
+			if len(requests) > 1 {
+				q := request.Questions[0]
+				fmt.Printf("---- %s %s ----\n", dnsclient.ToUnicode(q.QName), dnsclient.TypeToString(q.QType))
+			}
+			fmt.Print(dnsclient.DiffServers(context.Background(), servers, *request, opts...))
+		}
+		return
+	}
+
+	var tmpl *template.Template
+	if *format != "" {
+		tmpl, err = dnsclient.ParseFormat(*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "bad -format: %v\n", err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+	}
+
+	if *traceGraph != "" && *traceGraph != dnsclient.TraceGraphDOT && *traceGraph != dnsclient.TraceGraphTree {
+		fmt.Fprintf(os.Stderr, "bad -trace-graph %q, must be dot or tree\n", *traceGraph)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	client := newClient()
+	if *trace || *traceGraph != "" {
+		code := dnsclient.ExitOK
+		for i, request := range requests {
+			if i > 0 {
+				fmt.Println()
+			}
+			q := request.Questions[0]
+			steps, err := dnsclient.Trace(context.Background(), client, q.QName, q.QType)
+			switch *traceGraph {
+			case dnsclient.TraceGraphDOT:
+				dnsclient.FormatTraceDOT(os.Stdout, steps)
+			case dnsclient.TraceGraphTree:
+				dnsclient.FormatTraceTree(os.Stdout, steps)
+			default:
+				dnsclient.FormatTrace(os.Stdout, steps)
+			}
+			c := dnsclient.ExitFailure
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", dnsclient.ToUnicode(q.QName), err)
+			} else {
+				c = dnsclient.ResponseExitCode(steps[len(steps)-1].Response)
+			}
+			if c > code {
+				code = c
+			}
+		}
+		os.Exit(code)
+	}
+	var w *csv.Writer
+	if *csvOutput {
+		w = csv.NewWriter(os.Stdout)
+		w.Write(dnsclient.CSVHeader)
+	}
+	// The queries go out concurrently, the results are printed in order
+	type result struct {
+		response *dnsclient.DnsResponse
+		err      error
+	}
+	results := make([]chan result, len(requests))
+	for i, request := range requests {
+		results[i] = make(chan result, 1)
+		go func(request dnsclient.DnsRequest, done chan result) {
+			response, err := client.Exchange(context.Background(), &request)
+			done <- result{response, err}
+		}(*request, results[i])
+	}
+	p := resultPrinter{
+		csv:     w,
+		format:  tmpl,
+		human:   *human,
+		dnssec:  *dnssec,
+		privacy: *privacy != dnsclient.PrivacyNone,
+		color:   dnsclient.UseColor(*noColor),
+		json:    *jsonOutput,
+	}
+	code := dnsclient.ExitOK
+	for i, request := range requests {
+		if i > 0 && w == nil && tmpl == nil && !*jsonOutput {
+			fmt.Println()
+		}
+		res := <-results[i]
+		c := p.print(*request, res.response, res.err)
+		if c > code {
+			code = c
+		}
+	}
+	if w != nil {
+		w.Flush()
+	}
+	os.Exit(code)
+}
+
+// resultPrinter prints the outcome of queries in the format the flags ask for.
+type resultPrinter struct {
+	csv     *csv.Writer
+	format  *template.Template
+	human   bool
+	dnssec  bool
+	privacy bool
+	color   bool
+	json    bool
+}
+
+// print prints a request and its response, or CSV rows or JSON. It returns
+// the exit code for the outcome.
+func (p resultPrinter) print(request dnsclient.DnsRequest, res *dnsclient.DnsResponse, err error) int {
+	name := dnsclient.ToUnicode(request.Questions[0].QName)
+	if p.csv == nil && p.format == nil && !p.json {
+		fmt.Print(p.colorize(fmt.Sprintf("---- Request ----\n%v\n\n", request)))
+	}
+	if err != nil && p.json {
+		dnsclient.WriteJSON(os.Stdout, request.Questions[0], nil, err)
+		return dnsclient.ExitFailure
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return dnsclient.ExitFailure
+	}
+	response := *res
+	broken := false
+	for _, f := range dnsclient.Validate(request, response) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", name, f)
+		broken = broken || f.Severity == dnsclient.SeverityError
+	}
+	if broken {
+		return dnsclient.ExitFailure
+	}
+
+	if p.csv != nil {
+		p.csv.WriteAll(dnsclient.CSVRecords(request.Questions[0], &response))
+		return dnsclient.ResponseExitCode(response)
+	}
+	if p.json {
+		dnsclient.WriteJSON(os.Stdout, request.Questions[0], &response, nil)
+		return dnsclient.ResponseExitCode(response)
+	}
+	if p.format != nil {
+		err = dnsclient.ExecuteFormat(os.Stdout, p.format, response)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return dnsclient.ExitFailure
+		}
+		return dnsclient.ResponseExitCode(response)
+	}
+
+	if p.human {
+		fmt.Print(p.colorize(fmt.Sprintf("---- Response ----\n%s\n", response.HumanString())))
+	} else {
+		fmt.Print(p.colorize(fmt.Sprintf("---- Response ----\n%v\n", response)))
+	}
+	if p.privacy {
+		fmt.Printf("Privacy: %s\n", dnsclient.PrivacyStatus(response))
+	}
+	if response.Header.Flags.AD() == 1 {
+		fmt.Println("Authenticated data: the resolver validated the answer with DNSSEC")
+	}
+
+	if p.dnssec && dnsclient.ResponseExitCode(response) != dnsclient.ExitOK {
+		result, err := dnsclient.VerifyDenial(response)
+		if err != nil {
+			fmt.Printf("\nDenial of existence: not proven: %v\n", err)
+		} else {
+			// Only the proof is checked here, not the RRSIGs over the
+			// NSEC/NSEC3 records; chase validates those
+			fmt.Printf("\nDenial of existence (unauthenticated, signatures not checked): %s\n", result)
+		}
+	}
+
+	if dnsclient.IsRFC8482(response) {
+		fmt.Println("\nThe server refuses ANY queries (RFC 8482) and answered with a placeholder HINFO record; query specific types instead.")
+	}
+	fmt.Printf("\n%s", response.Stats(request))
+	return dnsclient.ResponseExitCode(response)
+}
+
+func (p resultPrinter) colorize(s string) string {
+	if !p.color {
+		return s
+	}
+	return dnsclient.Colorize(s)
+}
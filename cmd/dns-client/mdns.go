@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// parseMDNSService parses "instance,_service._tcp,port[,key=value...]".
+func parseMDNSService(s string) (dnsclient.MDNSService, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) < 3 {
+		return dnsclient.MDNSService{}, fmt.Errorf("service %q must be instance,_service._tcp,port[,key=value...]", s)
+	}
+	port, err := strconv.ParseUint(fields[2], 10, 16)
+	if err != nil {
+		return dnsclient.MDNSService{}, fmt.Errorf("invalid port %q", fields[2])
+	}
+	if !strings.HasSuffix(fields[1], "._tcp") && !strings.HasSuffix(fields[1], "._udp") {
+		return dnsclient.MDNSService{}, fmt.Errorf("service type %q must end in ._tcp or ._udp", fields[1])
+	}
+	return dnsclient.MDNSService{Instance: fields[0], Service: fields[1], Port: uint16(port), TXT: fields[3:]}, nil
+}
+
+// announceMain implements the "announce" subcommand: it announces a host
+// name and services over mDNS until interrupted.
+func announceMain(args []string) {
+	flags := flag.NewFlagSet("announce", flag.ExitOnError)
+	ifName := flags.String("interface", "", "network interface to announce on, the default multicast one if empty")
+	addrs := flags.String("addr", "", "comma separated addresses of the host, the interface's addresses if empty")
+	var services []dnsclient.MDNSService
+	flags.Func("service", "a DNS-SD service to announce as instance,_service._tcp,port[,key=value...], can be repeated", func(s string) error {
+		service, err := parseMDNSService(s)
+		if err == nil {
+			services = append(services, service)
+		}
+		return err
+	})
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dns-client announce [-interface eth0] [-addr ip,...] [-service instance,_service._tcp,port[,key=value...]]... host")
+		os.Exit(dnsclient.ExitUsage)
+	}
+
+	m := &dnsclient.MDNSResponder{Host: strings.TrimSuffix(strings.TrimSuffix(flags.Arg(0), "."), ".local"), Services: services}
+	if *ifName != "" {
+		ifi, err := net.InterfaceByName(*ifName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		m.Interface = ifi
+	}
+	if *addrs != "" {
+		for _, a := range strings.Split(*addrs, ",") {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				fmt.Fprintf(os.Stderr, "invalid address %q\n", a)
+				os.Exit(dnsclient.ExitUsage)
+			}
+			m.Addrs = append(m.Addrs, ip)
+		}
+	}
+	err := m.Start(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitFailure)
+	}
+	fmt.Printf("Announcing %s.local (%s)\n", m.Host, joinIPs(m.Addrs))
+	for _, s := range m.Services {
+		fmt.Printf("Announcing %s.%s.local on port %d\n", s.Instance, s.Service, s.Port)
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	m.Close()
+}
+
+func joinIPs(ips []net.IP) string {
+	s := make([]string, len(ips))
+	for i, ip := range ips {
+		s[i] = ip.String()
+	}
+	return strings.Join(s, ", ")
+}
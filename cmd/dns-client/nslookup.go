@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// nslookupSettings are what nslookup's -option=value arguments and
+// interactive set commands change.
+type nslookupSettings struct {
+	server  string
+	port    string
+	qtypes  []uint16
+	timeout time.Duration
+	retry   int
+	tcp     bool
+	recurse bool
+}
+
+// set applies one option as given to nslookup, without the leading - or
+// "set ". Options can be shortened as long as they stay unambiguous, but
+// only the common short forms are accepted here.
+func (s *nslookupSettings) set(option string) error {
+	name, value := option, ""
+	if i := strings.IndexByte(option, '='); i >= 0 {
+		name, value = option[:i], option[i+1:]
+	}
+	switch strings.ToLower(name) {
+	case "type", "ty", "querytype", "query", "q":
+		t, err := dnsclient.StringToType(value)
+		if err != nil {
+			return err
+		}
+		s.qtypes = []uint16{t}
+	case "timeout", "ti":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bad timeout %q", value)
+		}
+		s.timeout = time.Duration(n) * time.Second
+	case "retry", "ret":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("bad retry %q", value)
+		}
+		s.retry = n
+	case "port", "po":
+		s.port = value
+	case "vc":
+		s.tcp = true
+	case "novc":
+		s.tcp = false
+	case "recurse", "rec":
+		s.recurse = true
+	case "norecurse", "norec":
+		s.recurse = false
+	case "debug", "nodebug", "d2", "nod2", "search", "nosearch":
+		// Accepted for compatibility, they change nothing
+	default:
+		return fmt.Errorf("invalid option: %s", option)
+	}
+	return nil
+}
+
+func (s *nslookupSettings) address() string {
+	return net.JoinHostPort(strings.Trim(s.server, "[]"), s.port)
+}
+
+// nslookupMain behaves like nslookup: "nslookup [-option...] [name] [server]"
+// looks name up, and without a name it reads commands from stdin.
+func nslookupMain(config dnsclient.Config, args []string) {
+	s := nslookupSettings{port: "53", qtypes: []uint16{dnsclient.A, dnsclient.AAAA}, timeout: 2 * time.Second, retry: 1, recurse: true}
+	if len(config.Servers) > 0 {
+		host, port, err := net.SplitHostPort(config.Servers[0])
+		if err != nil {
+			host = config.Servers[0]
+		} else {
+			s.port = port
+		}
+		s.server = host
+	}
+
+	var names []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			err := s.set(arg[1:])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "*** %v\n", err)
+				os.Exit(1)
+			}
+			continue
+		}
+		names = append(names, arg)
+	}
+	if len(names) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: nslookup [-option...] [name] [server]")
+		os.Exit(1)
+	}
+	if len(names) == 2 {
+		s.server = names[1]
+	}
+
+	if len(names) == 0 {
+		nslookupInteractive(&s, os.Stdin, os.Stdout)
+		return
+	}
+	if !nslookup(&s, names[0], os.Stdout) {
+		os.Exit(1)
+	}
+}
+
+// nslookupInteractive reads names to look up and "server x", "set x" and
+// "exit" commands, one per line.
+func nslookupInteractive(s *nslookupSettings, in io.Reader, out io.Writer) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "exit":
+			return
+		case fields[0] == "server" && len(fields) == 2:
+			s.server = fields[1]
+			fmt.Fprintf(out, "Default server: %s\nAddress: %s\n\n", s.server, nslookupAddress(s.address()))
+		case fields[0] == "set" && len(fields) == 2:
+			err := s.set(fields[1])
+			if err != nil {
+				fmt.Fprintf(out, "*** %v\n", err)
+			}
+		default:
+			nslookup(s, fields[0], out)
+		}
+	}
+}
+
+// nslookupAddress writes host:port the way nslookup does, as host#port.
+func nslookupAddress(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host + "#" + port
+}
+
+// nslookup looks name up with each of the settings' types and prints the
+// answers in nslookup's format. It reports whether the name was found.
+func nslookup(s *nslookupSettings, name string, out io.Writer) bool {
+	qtypes := s.qtypes
+	if ip := net.ParseIP(name); ip != nil {
+		name, _ = dnsclient.ReverseName(name)
+		qtypes = []uint16{dnsclient.PTR}
+	}
+	transport := dnsclient.TransportUDP
+	if s.tcp {
+		transport = dnsclient.TransportTCP
+	}
+	client := dnsclient.NewClient(dnsclient.WithServers(s.address()), dnsclient.WithTransport(transport), dnsclient.WithTimeout(s.timeout), dnsclient.WithAttempts(s.retry+1))
+	defer client.Close()
+
+	fmt.Fprintf(out, "Server:\t\t%s\nAddress:\t%s\n\n", s.server, nslookupAddress(s.address()))
+	var records []dnsclient.DnsResourceRecord
+	authoritative := false
+	for _, t := range qtypes {
+		response, err := client.Exchange(context.Background(), dnsclient.NewQuery(name, t).SetRD(s.recurse))
+		var timeout *dnsclient.TimeoutError
+		if errors.As(err, &timeout) {
+			fmt.Fprintf(out, ";; connection timed out; no servers could be reached\n\n")
+			return false
+		}
+		if err != nil {
+			fmt.Fprintf(out, ";; %v\n\n", err)
+			return false
+		}
+		if rcode := response.Header.Flags.RCode(); rcode != 0 {
+			fmt.Fprintf(out, "** server can't find %s: %s\n\n", dnsclient.ToUnicode(name), dnsclient.RCodeToString(rcode))
+			return false
+		}
+		records = append(records, response.Answers...)
+		authoritative = response.Header.Flags.AA() == 1
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(out, "*** Can't find %s: No answer\n\n", dnsclient.ToUnicode(name))
+		return true
+	}
+	if !authoritative {
+		fmt.Fprintln(out, "Non-authoritative answer:")
+	}
+	for _, r := range records {
+		fmt.Fprintln(out, nslookupRecord(r))
+	}
+	fmt.Fprintln(out)
+	return true
+}
+
+// nslookupRecord formats a record the way nslookup does, with names fully
+// qualified.
+func nslookupRecord(r dnsclient.DnsResourceRecord) string {
+	name := dnsclient.ToUnicode(r.Name)
+	rdata := r.RDataString()
+	switch r.Type {
+	case dnsclient.A, dnsclient.AAAA:
+		return fmt.Sprintf("Name:\t%s\nAddress: %s", name, rdata)
+	case dnsclient.CNAME:
+		return fmt.Sprintf("%s\tcanonical name = %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.NS:
+		return fmt.Sprintf("%s\tnameserver = %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.PTR:
+		return fmt.Sprintf("%s\tname = %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.MX:
+		return fmt.Sprintf("%s\tmail exchanger = %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.SRV:
+		return fmt.Sprintf("%s\tservice = %s", name, dnsclient.Name(rdata).FQDN())
+	case dnsclient.TXT:
+		return fmt.Sprintf("%s\ttext = %s", name, rdata)
+	case dnsclient.SOA:
+		soa, err := dnsclient.ParseSOA(r.RData)
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("%s\n\torigin = %s\n\tmail addr = %s\n\tserial = %d\n\trefresh = %d\n\tretry = %d\n\texpire = %d\n\tminimum = %d",
+			name, dnsclient.FormatName(soa.MName), dnsclient.FormatName(soa.RName), soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+	}
+	return fmt.Sprintf("%s\trdata_%d = %s", name, r.Type, rdata)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// pingMain implements the "ping" subcommand.
+func pingMain(config dnsclient.Config, args []string) {
+	flags := flag.NewFlagSet("ping", flag.ExitOnError)
+	defaultServer := ""
+	if len(config.Servers) > 0 {
+		defaultServer = config.Servers[0]
+	}
+	server := flags.String("server", defaultServer, "server to query")
+	typeName := flags.String("type", "A", "query type")
+	count := flags.Int("c", 0, "how many queries to send, 0 to keep going until interrupted")
+	interval := flags.Duration("i", time.Second, "time between queries")
+	timeout := flags.Duration("W", 2*time.Second, "how long to wait for each answer")
+	tcp := flags.Bool("tcp", config.Transport == dnsclient.TransportTCP, "query over TCP")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: dns-client ping [-server addr] [-type A] [-c count] [-i interval] [-W timeout] [-tcp] name")
+		os.Exit(dnsclient.ExitUsage)
+	}
+	qtype, err := dnsclient.StringToType(*typeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "-i must be positive")
+		os.Exit(dnsclient.ExitUsage)
+	}
+	transport := dnsclient.TransportUDP
+	if *tcp {
+		transport = dnsclient.TransportTCP
+	}
+	client := dnsclient.NewClient(dnsclient.WithServers(*server), dnsclient.WithTransport(transport), dnsclient.WithTimeout(*timeout), dnsclient.WithEDNS(config.UDPSize), dnsclient.WithEDNSOptions(config.EDNSOptions...))
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	name := flags.Arg(0)
+	fmt.Printf("PING %s %s via %s\n", name, dnsclient.TypeToString(qtype), *server)
+	stats := dnsclient.Ping(ctx, client, name, qtype, *count, *interval, func(p dnsclient.PingProbe) {
+		if p.Err != nil {
+			fmt.Printf("seq=%d %v\n", p.Seq, p.Err)
+			return
+		}
+		fmt.Printf("seq=%d %s %d bytes time=%s ms\n", p.Seq, dnsclient.RCodeToString(p.Response.RCode()), p.Response.Size, millis(p.RTT))
+	})
+
+	fmt.Printf("\n--- %s ping statistics ---\n", *server)
+	fmt.Printf("%d queries sent, %d answered, %.1f%% loss\n", stats.Sent, stats.Received, stats.Loss())
+	if stats.Received > 0 {
+		fmt.Printf("rtt min/avg/max/jitter = %s ms\n", strings.Join([]string{
+			millis(stats.Min), millis(stats.Avg), millis(stats.Max), millis(stats.Jitter)}, "/"))
+	} else {
+		os.Exit(dnsclient.ExitFailure)
+	}
+}
+
+func millis(d time.Duration) string {
+	return fmt.Sprintf("%.3f", float64(d)/float64(time.Millisecond))
+}
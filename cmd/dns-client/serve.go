@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// serveMain implements the "serve" subcommand, and "proxy", which is serve
+// with the defaults of a local caching resolver: listening on port 53 of
+// localhost, with the cache on.
+func serveMain(config dnsclient.Config, command string, args []string) {
+	listenAddr, cacheDefault := "127.0.0.1:5300", false
+	if command == "proxy" {
+		listenAddr, cacheDefault = "127.0.0.1:53", true
+	}
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	listen := flags.String("listen", listenAddr, "address to listen on for UDP and TCP queries")
+	upstream := flags.String("upstream", strings.Join(config.Servers, ","), "comma separated list of upstream servers, tls:host and https:// URLs for DoT and DoH")
+	tcp := flags.Bool("tcp", config.Transport == dnsclient.TransportTCP, "send queries to plain DNS upstreams over TCP")
+	timeout := flags.Duration("timeout", config.Timeout, "how long to wait for each of connecting, sending and reading the answer upstream")
+	route := flags.String("route", "", "comma separated zone=server rules sending queries for names in a zone to other upstreams, e.g. corp.example=10.0.0.2")
+	upstreamQPS := flags.Float64("upstream-qps", 0, "queries a second each upstream may be sent, 0 for no limit")
+	clientQPS := flags.Float64("client-qps", 0, "queries a second each client address may send before the rest are dropped, 0 for no limit")
+	cache := flags.Bool("cache", cacheDefault, "cache replies for their TTL")
+	redis := flags.String("redis", "", "cache replies in the Redis server at this address, shared with other forwarders")
+	blocklist := flags.String("blocklist", "", "comma separated hosts files or domain lists (paths or URLs) of names to block")
+	blockAnswer := flags.String("block-answer", dnsclient.BlockNXDomain, "how to answer blocked names: nxdomain, or null for 0.0.0.0 and ::")
+	overrides := flags.String("overrides", "", "comma separated hosts files or zone file snippets of names to answer locally")
+	rewrite := flags.String("rewrite", "", "file of rules rewriting answers: address name ip..., strip-aaaa zone, ttl zone min max")
+	dohListen := flags.String("doh-listen", "", "address to also answer DoH queries on over HTTPS, at "+dnsclient.DoHPath)
+	dohCert := flags.String("doh-cert", "", "certificate file for -doh-listen")
+	dohKey := flags.String("doh-key", "", "key file for -doh-listen")
+	control := flags.String("control", "", "address to serve the cache and blocklist control API on over HTTP, see the cache subcommand")
+	flags.Parse(args)
+
+	transport := dnsclient.TransportUDP
+	if *tcp {
+		transport = dnsclient.TransportTCP
+	}
+	opts := []dnsclient.Option{dnsclient.WithTransport(transport), dnsclient.WithTimeout(*timeout)}
+	if *upstreamQPS > 0 {
+		// Shared with the routes, which may have upstreams in common
+		opts = append(opts, dnsclient.WithRateLimit(dnsclient.NewRateLimiter(*upstreamQPS)))
+	}
+	upstreams := strings.Split(*upstream, ",")
+	f := &dnsclient.Forwarder{
+		Upstreams: upstreams,
+		Client:    dnsclient.NewClient(append(opts, dnsclient.WithServers(upstreams...))...),
+	}
+	if *clientQPS > 0 {
+		f.ClientLimit = dnsclient.NewRateLimiter(*clientQPS)
+	}
+	if *route != "" {
+		routes, err := dnsclient.ParseRoutes(*route, opts...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		f.Routes = routes
+	}
+	var memory *dnsclient.MemoryCache
+	switch {
+	case *redis != "":
+		f.Cache = &dnsclient.RedisCache{Addr: *redis}
+	case *cache:
+		memory = dnsclient.NewCache()
+		f.Cache = memory
+	}
+	if *overrides != "" {
+		f.Overrides = dnsclient.NewOverrides()
+		for _, path := range strings.Split(*overrides, ",") {
+			err := f.Overrides.LoadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(dnsclient.ExitUsage)
+			}
+		}
+	}
+	if *blocklist != "" {
+		if *blockAnswer != dnsclient.BlockNXDomain && *blockAnswer != dnsclient.BlockNull {
+			fmt.Fprintln(os.Stderr, "-block-answer must be nxdomain or null")
+			os.Exit(dnsclient.ExitUsage)
+		}
+		f.Blocklist = dnsclient.NewBlocklist()
+		f.Blocklist.Answer = *blockAnswer
+		for _, path := range strings.Split(*blocklist, ",") {
+			err := f.Blocklist.LoadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(dnsclient.ExitUsage)
+			}
+		}
+		log.Printf("blocking %d names", f.Blocklist.Stats(0).Names)
+	}
+	if *rewrite != "" {
+		rules, err := dnsclient.LoadRewriteRules(*rewrite)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		f.Rewrite = rules
+	}
+	if *control != "" {
+		if memory == nil && f.Blocklist == nil {
+			fmt.Fprintln(os.Stderr, "-control needs -cache or -blocklist")
+			os.Exit(dnsclient.ExitUsage)
+		}
+		mux := http.NewServeMux()
+		if memory != nil {
+			mux.Handle("/cache", dnsclient.CacheHandler(memory))
+			mux.Handle("/cache/", dnsclient.CacheHandler(memory))
+		}
+		if f.Blocklist != nil {
+			mux.Handle("/blocklist/", dnsclient.BlocklistHandler(f.Blocklist))
+		}
+		go func() {
+			log.Fatal(http.ListenAndServe(*control, mux))
+		}()
+	}
+
+	pc, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(dnsclient.ExitUsage)
+	}
+	var dohListener net.Listener
+	if *dohListen != "" {
+		if *dohCert == "" || *dohKey == "" {
+			fmt.Fprintln(os.Stderr, "-doh-listen needs -doh-cert and -doh-key")
+			os.Exit(dnsclient.ExitUsage)
+		}
+		dohListener, err = net.Listen("tcp", *dohListen)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		log.Printf("answering DoH queries on https://%s%s", *dohListen, dnsclient.DoHPath)
+	}
+	log.Printf("forwarding queries on %s to %s", *listen, *upstream)
+
+	errs := make(chan error, 3)
+	go func() { errs <- f.ServeUDP(pc) }()
+	go func() { errs <- f.ServeTCP(l) }()
+	if dohListener != nil {
+		go func() { errs <- f.ServeDoH(dohListener, *dohCert, *dohKey) }()
+	}
+	fmt.Fprintln(os.Stderr, <-errs)
+	os.Exit(dnsclient.ExitFailure)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dnsclient "github.com/iechevarria/dns-client"
+)
+
+// updateSession holds the state of an nsupdate style script.
+type updateSession struct {
+	client  *dnsclient.Client
+	server  string
+	key     *dnsclient.TSIGKey
+	tcp     bool
+	ttl     uint32
+	update  dnsclient.DnsUpdate
+	pending bool
+	answer  *dnsclient.DnsResponse
+	out     io.Writer
+}
+
+// class is the class of the zone being updated, IN unless set.
+func (s *updateSession) class() uint16 {
+	if s.update.Class == 0 {
+		return dnsclient.IN
+	}
+	return s.update.Class
+}
+
+// run executes one directive, returning false to stop reading.
+func (s *updateSession) run(line string) (bool, error) {
+	fields := dnsclient.ZoneFields(line)
+	if len(fields) == 0 {
+		// A blank line sends what has been given so far, like send
+		if s.pending {
+			return true, s.send()
+		}
+		return true, nil
+	}
+	cmd, args := strings.ToLower(fields[0]), fields[1:]
+	if cmd == "update" {
+		if len(args) == 0 {
+			return true, errors.New("update needs add or delete")
+		}
+		cmd, args = strings.ToLower(args[0]), args[1:]
+	}
+	switch cmd {
+	case "server":
+		if len(args) < 1 || len(args) > 2 {
+			return true, errors.New("usage: server address [port]")
+		}
+		s.server = args[0]
+		if len(args) == 2 {
+			s.server = net.JoinHostPort(args[0], args[1])
+		}
+	case "zone":
+		if len(args) != 1 {
+			return true, errors.New("usage: zone name")
+		}
+		s.update.Zone = dnsclient.ZoneName(args[0])
+	case "class":
+		if len(args) != 1 {
+			return true, errors.New("usage: class name")
+		}
+		c, err := dnsclient.StringToClass(args[0])
+		if err != nil {
+			return true, err
+		}
+		s.update.Class = c
+	case "ttl":
+		ttl, err := strconv.ParseUint(strings.Join(args, ""), 10, 31)
+		if err != nil || len(args) != 1 {
+			return true, errors.New("usage: ttl seconds")
+		}
+		s.ttl = uint32(ttl)
+	case "key":
+		if len(args) != 2 {
+			return true, errors.New("usage: key [algorithm:]name secret")
+		}
+		algorithm, name := dnsclient.HmacSHA256, args[0]
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			algorithm, name = name[:i], name[i+1:]
+		}
+		key, err := dnsclient.NewTSIGKey(name, algorithm, args[1])
+		if err != nil {
+			return true, err
+		}
+		s.key = &key
+	case "prereq":
+		return true, s.prereq(args)
+	case "add":
+		if len(args) < 3 {
+			return true, errors.New("usage: update add name ttl [class] type rdata")
+		}
+		if _, err := strconv.ParseUint(args[1], 10, 31); err != nil && s.ttl == 0 {
+			return true, errors.New("update add needs a ttl, give one or set a default with ttl")
+		}
+		r, err := dnsclient.ParseRR(strings.Join(args, " "), s.ttl)
+		if err != nil {
+			return true, err
+		}
+		r.Class = s.class()
+		s.update.Add(r)
+		s.pending = true
+	case "del", "delete":
+		return true, s.delete(args)
+	case "show":
+		fmt.Fprint(s.out, s.show())
+	case "send":
+		return true, s.send()
+	case "answer":
+		if s.answer != nil {
+			fmt.Fprintln(s.out, s.answer)
+		}
+	case "quit":
+		return false, nil
+	default:
+		return true, fmt.Errorf("unknown command %q", fields[0])
+	}
+	return true, nil
+}
+
+func (s *updateSession) prereq(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: prereq nxdomain|yxdomain|nxrrset|yxrrset name [class] [type [rdata]]")
+	}
+	kind, name, rest := strings.ToLower(args[0]), dnsclient.ZoneName(args[1]), args[2:]
+	switch kind {
+	case "nxdomain", "yxdomain":
+		if len(rest) != 0 {
+			return fmt.Errorf("prereq %s only takes a name", kind)
+		}
+		s.update.Require(name, dnsclient.ANY, kind == "yxdomain", nil)
+	case "nxrrset", "yxrrset":
+		if len(rest) > 0 && !dnsclient.IsTypeName(rest[0]) {
+			if _, err := dnsclient.StringToClass(rest[0]); err == nil {
+				rest = rest[1:]
+			}
+		}
+		if len(rest) == 0 {
+			return fmt.Errorf("prereq %s needs a type", kind)
+		}
+		t, err := dnsclient.StringToType(rest[0])
+		if err != nil {
+			return err
+		}
+		var rdata []byte
+		if len(rest) > 1 {
+			if kind == "nxrrset" {
+				return errors.New("prereq nxrrset takes no rdata")
+			}
+			rdata, err = dnsclient.ParseRDataText(t, rest[1:])
+			if err != nil {
+				return err
+			}
+		}
+		s.update.Require(name, t, kind == "yxrrset", rdata)
+	default:
+		return fmt.Errorf("unknown prerequisite %q", args[0])
+	}
+	s.pending = true
+	return nil
+}
+
+// delete handles "update delete name [ttl] [class] [type [rdata]]".
+func (s *updateSession) delete(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: update delete name [ttl] [class] [type [rdata]]")
+	}
+	name, rest := dnsclient.ZoneName(args[0]), args[1:]
+	if len(rest) > 0 {
+		if _, err := strconv.ParseUint(rest[0], 10, 31); err == nil {
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 0 && !dnsclient.IsTypeName(rest[0]) {
+		if _, err := dnsclient.StringToClass(rest[0]); err == nil {
+			rest = rest[1:]
+		}
+	}
+	t, rdata := uint16(dnsclient.ANY), []byte(nil)
+	if len(rest) > 0 {
+		var err error
+		t, err = dnsclient.StringToType(rest[0])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 1 {
+			rdata, err = dnsclient.ParseRDataText(t, rest[1:])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	s.update.Delete(name, t, rdata)
+	s.pending = true
+	return nil
+}
+
+func (s *updateSession) show() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Outgoing update query:\nZone: %s %s SOA\n", dnsclient.FormatName(s.update.Zone), dnsclient.ClassToString(s.class()))
+	for _, section := range []struct {
+		title   string
+		records []dnsclient.DnsResourceRecord
+	}{{"Prerequisites", s.update.Prerequisites}, {"Updates", s.update.Updates}} {
+		if len(section.records) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:\n", section.title)
+		for _, r := range section.records {
+			rdata := ""
+			if len(r.RData) > 0 {
+				rdata = " " + dnsclient.FormatRData(r.Type, r.RData)
+			}
+			fmt.Fprintf(&b, "  %s %d %s %s%s\n", dnsclient.FormatName(r.Name), r.TTL, dnsclient.ClassToString(r.Class), dnsclient.TypeToString(r.Type), rdata)
+		}
+	}
+	return b.String()
+}
+
+// send sends the update to the server, finding the zone and its primary
+// from the SOA of the first name if they weren't given.
+func (s *updateSession) send() error {
+	defer func() {
+		s.update = dnsclient.DnsUpdate{Zone: s.update.Zone, Class: s.update.Class}
+		s.pending = false
+	}()
+	if len(s.update.Updates) == 0 && len(s.update.Prerequisites) == 0 {
+		return errors.New("nothing to send")
+	}
+	zone, server := s.update.Zone, s.server
+	if zone == "" || server == "" {
+		name := s.update.Zone
+		if name == "" {
+			records := append(append([]dnsclient.DnsResourceRecord{}, s.update.Updates...), s.update.Prerequisites...)
+			name = records[0].Name
+		}
+		soaZone, primary, err := findPrimary(s.client, name)
+		if err != nil {
+			return err
+		}
+		if zone == "" {
+			zone = soaZone
+		}
+		if server == "" {
+			server = primary
+		}
+	}
+	update := s.update
+	update.Zone = zone
+	response, err := dnsclient.SendUpdate(server, update, s.key, s.tcp, s.client.Timeouts)
+	if err != nil {
+		return err
+	}
+	s.answer = &response
+	if rcode := response.Header.Flags.RCode(); rcode != 0 {
+		return fmt.Errorf("update failed: %s", dnsclient.RCodeToString(rcode))
+	}
+	return nil
+}
+
+// findPrimary returns the zone name belongs to and the address of its
+// primary server, from the MNAME of its SOA.
+func findPrimary(c *dnsclient.Client, name string) (string, string, error) {
+	response, err := c.Query(context.Background(), name, dnsclient.SOA)
+	if err != nil {
+		return "", "", err
+	}
+	for _, r := range append(append([]dnsclient.DnsResourceRecord{}, response.Answers...), response.Authorities...) {
+		if r.Type != dnsclient.SOA {
+			continue
+		}
+		soa, err := dnsclient.ParseSOA(r.RData)
+		if err != nil {
+			return "", "", err
+		}
+		addrs, err := c.LookupHost(context.Background(), soa.MName)
+		if err != nil {
+			return "", "", fmt.Errorf("primary %s of %s: %v", soa.MName, r.Name, err)
+		}
+		for _, a := range addrs {
+			if net.ParseIP(a).To4() != nil {
+				return r.Name, a, nil
+			}
+		}
+		return "", "", fmt.Errorf("primary %s of %s has no IPv4 address", soa.MName, r.Name)
+	}
+	return "", "", fmt.Errorf("no SOA found for %s, give the zone and server", name)
+}
+
+// updateMain implements the "update" subcommand: it reads nsupdate style
+// directives from a file or stdin and sends the updates.
+func updateMain(config dnsclient.Config, args []string) {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	keyFlag := flags.String("y", "", "TSIG key to sign updates with, as [algorithm:]name:base64-secret")
+	keyFile := flags.String("k", "", "file with the TSIG key to sign updates with: a BIND key clause or a K*.private file")
+	tcp := flags.Bool("v", false, "send updates over TCP")
+	timeout := flags.Duration("t", 10*time.Second, "how long to wait for each of connecting, sending and reading the answer")
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		fmt.Fprintln(os.Stderr, "usage: dns-client update [-y [algorithm:]name:secret | -k keyfile] [-v] [-t timeout] [file]")
+		os.Exit(dnsclient.ExitUsage)
+	}
+
+	s := &updateSession{
+		client: dnsclient.NewClient(dnsclient.WithServers(config.Servers...), dnsclient.WithTimeout(*timeout)),
+		tcp:    *tcp,
+		out:    os.Stdout,
+	}
+	if *keyFlag != "" && *keyFile != "" {
+		fmt.Fprintln(os.Stderr, "-y and -k can't be used together")
+		os.Exit(dnsclient.ExitUsage)
+	}
+	if *keyFlag != "" {
+		key, err := dnsclient.ParseTSIGKey(*keyFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		s.key = &key
+	}
+	if *keyFile != "" {
+		key, err := dnsclient.LoadTSIGKeyFile(*keyFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		s.key = &key
+	}
+	in := io.Reader(os.Stdin)
+	interactive := false
+	if flags.NArg() == 1 {
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitUsage)
+		}
+		defer f.Close()
+		in = f
+	} else if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
+
+	scanner := bufio.NewScanner(in)
+	for lineNo := 1; ; lineNo++ {
+		if interactive {
+			fmt.Print("> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		more, err := s.run(line)
+		if err != nil {
+			if interactive {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", lineNo, err)
+			os.Exit(dnsclient.ExitFailure)
+		}
+		if !more {
+			return
+		}
+	}
+	// Like nsupdate, what is left at the end is sent too
+	if s.pending {
+		if err := s.send(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(dnsclient.ExitFailure)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package dnsclient
+
+import (
+	"os"
+	"regexp"
+)
+
+// ANSI escape sequences
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorCyan  = "\x1b[36m"
+)
+
+// UseColor reports whether output should be colored: only on a terminal,
+// and not if noColor or the NO_COLOR environment variable (no-color.org)
+// says otherwise.
+func UseColor(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+var (
+	headerPattern   = regexp.MustCompile(`(?m)^(---- .* ----|[A-Z][a-z]+: [\[{])`)
+	typePattern     = regexp.MustCompile(`(Q?Type: )([A-Z0-9]+)`)
+	badRCodePattern = regexp.MustCompile(`(RCode: )([1-9][0-9]*|[A-Z]+[A-Z0-9]*)`)
+)
+
+// Colorize highlights the section headers and record types of printed
+// messages, and response codes other than NOERROR in red.
+func Colorize(s string) string {
+	s = headerPattern.ReplaceAllString(s, colorBold+"$1"+colorReset)
+	s = typePattern.ReplaceAllString(s, "$1"+colorCyan+"$2"+colorReset)
+	return badRCodePattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := badRCodePattern.FindStringSubmatch(m)
+		if sub[2] == "NOERROR" {
+			return m
+		}
+		return sub[1] + colorRed + sub[2] + colorReset
+	})
+}
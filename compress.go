@@ -0,0 +1,126 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// Compression maps the names already written to a message, lowercased, to
+// their offsets, so later copies, whatever their case, can point at them
+// (RFC 1035 section 4.1.4). A message is written with one, starting from
+// an empty one at the header.
+type Compression map[string]int
+
+// compressedNames says where the names that may be compressed are in the
+// wire RData of the RFC 1035 types: after how many fixed bytes, and how
+// many. Newer types must not be compressed (RFC 3597 section 4).
+var compressedNames = map[uint16]struct{ skip, names int }{
+	NS:    {0, 1},
+	CNAME: {0, 1},
+	PTR:   {0, 1},
+	MX:    {2, 1},
+	SOA:   {0, 2},
+	MINFO: {0, 2},
+}
+
+// WriteName writes name to buf, which holds the message from its start,
+// pointing to the longest suffix in c that was written before and adding
+// the new suffixes to c. A nil c writes the name uncompressed.
+func WriteName(buf *bytes.Buffer, name string, c Compression) {
+	labels := strings.Split(strings.TrimSuffix(name, "."), ".")
+	if labels[0] == "" {
+		labels = nil
+	}
+	for i := range labels {
+		if c == nil {
+			break
+		}
+		suffix := strings.ToLower(strings.Join(labels[i:], "."))
+		if offset, ok := c[suffix]; ok {
+			binary.Write(buf, binary.BigEndian, uint16(0xc000|offset))
+			return
+		}
+		// Pointers only have 14 bits
+		if buf.Len() < 0x4000 {
+			c[suffix] = buf.Len()
+		}
+		buf.WriteByte(byte(len(labels[i])))
+		buf.WriteString(labels[i])
+	}
+	if c == nil {
+		buf.Write(SerializeName(name))
+		return
+	}
+	buf.WriteByte(0)
+}
+
+// WriteQuestion writes question to buf with its name compressed.
+func WriteQuestion(buf *bytes.Buffer, question DnsQuestion, c Compression) {
+	WriteName(buf, question.QName, c)
+	binary.Write(buf, binary.BigEndian, question.QType)
+	binary.Write(buf, binary.BigEndian, question.QClass)
+}
+
+// WriteResourceRecord writes record to buf like SerializeResourceRecord,
+// with the RData encoded by its registered type, but with the owner name
+// and the names in the RData of the RFC 1035 types compressed.
+func WriteResourceRecord(buf *bytes.Buffer, record DnsResourceRecord, c Compression) {
+	WriteName(buf, record.Name, c)
+	binary.Write(buf, binary.BigEndian, record.Type)
+	binary.Write(buf, binary.BigEndian, record.Class)
+	binary.Write(buf, binary.BigEndian, record.TTL)
+
+	rdata := SerializeRData(record.Type, record.RData)
+	lengthAt := buf.Len()
+	binary.Write(buf, binary.BigEndian, uint16(0))
+	if !writeCompressedRData(buf, record.Type, rdata, c) {
+		buf.Write(rdata)
+	}
+	binary.BigEndian.PutUint16(buf.Bytes()[lengthAt:], uint16(buf.Len()-lengthAt-2))
+}
+
+// writeCompressedRData writes rdata with its names compressed, returning
+// false if the type has none or the names don't parse.
+func writeCompressedRData(buf *bytes.Buffer, t uint16, rdata []byte, c Compression) bool {
+	layout, ok := compressedNames[t]
+	if !ok || c == nil || len(rdata) < layout.skip {
+		return false
+	}
+	r := bytes.NewReader(rdata[layout.skip:])
+	names := make([]string, layout.names)
+	for i := range names {
+		var err error
+		if names[i], err = ReadName(r); err != nil {
+			return false
+		}
+	}
+	buf.Write(rdata[:layout.skip])
+	for _, name := range names {
+		WriteName(buf, name, c)
+	}
+	buf.Write(rdata[len(rdata)-r.Len():])
+	return true
+}
+
+// SerializeCompressed encodes response like SerializeResponse, but with
+// name compression.
+func SerializeCompressed(response DnsResponse) []byte {
+	var buf bytes.Buffer
+	header := response.Header
+	header.QdCount = uint16(len(response.Questions))
+	header.AnCount = uint16(len(response.Answers))
+	header.NsCount = uint16(len(response.Authorities))
+	header.ArCount = uint16(len(response.Additionals))
+	binary.Write(&buf, binary.BigEndian, header)
+	c := Compression{}
+	for _, q := range response.Questions {
+		WriteQuestion(&buf, q, c)
+	}
+	for _, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, response.Additionals} {
+		for _, r := range section {
+			WriteResourceRecord(&buf, r, c)
+		}
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,251 @@
+package dnsclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the defaults for command line flags. Flags given on the command
+// line always win over the config file.
+type Config struct {
+	Servers []string
+	Type    uint16
+	Output  string
+	UDPSize uint16
+	// TransportUDP or TransportTCP
+	Transport string
+	Timeout   time.Duration
+	// Sets the DO bit on queries
+	DNSSEC bool
+	// Added to every query with EDNS
+	EDNSOptions []EDNSOption
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Servers:   []string{"8.8.8.8"},
+		Type:      NS,
+		Output:    "text",
+		UDPSize:   DefaultUDPSize,
+		Transport: TransportUDP,
+		Timeout:   5 * time.Second,
+	}
+}
+
+// ClientOptions returns the options for a Client that queries the way the
+// config says.
+func (c Config) ClientOptions() []Option {
+	opts := []Option{
+		WithServers(c.Servers...),
+		WithTransport(c.Transport),
+		WithTimeout(c.Timeout),
+		WithEDNS(c.UDPSize),
+		WithEDNSOptions(c.EDNSOptions...),
+	}
+	if c.DNSSEC {
+		opts = append(opts, WithDNSSEC())
+	}
+	return opts
+}
+
+// ConfigPath is $DNS_CLIENT_CONFIG if set, otherwise ~/.config/dns-client/config.yaml.
+func ConfigPath() string {
+	if path := os.Getenv("DNS_CLIENT_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "dns-client", "config.yaml")
+}
+
+// LoadConfig reads the config file at path on top of the defaults. A missing
+// file is not an error.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path == "" {
+		return config, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	values, err := ParseConfig(data)
+	if err != nil {
+		return config, fmt.Errorf("%s: %v", path, err)
+	}
+	for key, vals := range values {
+		switch key {
+		case "servers", "server":
+			if len(vals) == 0 {
+				return config, fmt.Errorf("%s: %s needs at least one server", path, key)
+			}
+			config.Servers = vals
+		case "type":
+			if len(vals) != 1 {
+				return config, fmt.Errorf("%s: type takes a single value", path)
+			}
+			t, err := StringToType(vals[0])
+			if err != nil {
+				return config, fmt.Errorf("%s: %v", path, err)
+			}
+			config.Type = t
+		case "output":
+			if len(vals) != 1 || (vals[0] != "text" && vals[0] != "csv" && vals[0] != "json") {
+				return config, fmt.Errorf("%s: output must be text, csv or json", path)
+			}
+			config.Output = vals[0]
+		case "bufsize":
+			if len(vals) != 1 {
+				return config, fmt.Errorf("%s: bufsize takes a single value", path)
+			}
+			size, err := strconv.ParseUint(vals[0], 10, 16)
+			if err != nil {
+				return config, fmt.Errorf("%s: bufsize must be a number between 0 and 65535", path)
+			}
+			config.UDPSize = uint16(size)
+		case "transport":
+			if len(vals) != 1 || (vals[0] != TransportUDP && vals[0] != TransportTCP) {
+				return config, fmt.Errorf("%s: transport must be udp or tcp", path)
+			}
+			config.Transport = vals[0]
+		case "timeout":
+			if len(vals) != 1 {
+				return config, fmt.Errorf("%s: timeout takes a single value", path)
+			}
+			d, err := time.ParseDuration(vals[0])
+			if err != nil || d < 0 {
+				return config, fmt.Errorf("%s: timeout must be a duration like 2s or 500ms", path)
+			}
+			config.Timeout = d
+		case "dnssec":
+			if len(vals) != 1 {
+				return config, fmt.Errorf("%s: dnssec takes a single value", path)
+			}
+			do, err := strconv.ParseBool(vals[0])
+			if err != nil {
+				return config, fmt.Errorf("%s: dnssec must be true or false", path)
+			}
+			config.DNSSEC = do
+		case "edns-options":
+			config.EDNSOptions = nil
+			for _, v := range vals {
+				opt, err := parseEDNSOption(v)
+				if err != nil {
+					return config, fmt.Errorf("%s: %v", path, err)
+				}
+				config.EDNSOptions = append(config.EDNSOptions, opt)
+			}
+		default:
+			return config, fmt.Errorf("%s: unknown key %q", path, key)
+		}
+	}
+	return config, nil
+}
+
+// parseEDNSOption parses an option of the config file: a registered name
+// like nsid or a code, optionally followed by =hex data. A cookie without
+// data gets a random client cookie.
+func parseEDNSOption(s string) (EDNSOption, error) {
+	name, data := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		name, data = s[:i], s[i+1:]
+	}
+	var opt EDNSOption
+	code, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(name), "OPT"), 10, 16)
+	if err == nil {
+		opt.Code = uint16(code)
+	} else {
+		found := false
+		for code, t := range ednsOptionTypes {
+			if strings.EqualFold(t.Name, name) {
+				opt.Code, found = code, true
+			}
+		}
+		if !found {
+			return opt, fmt.Errorf("unknown EDNS option %q", name)
+		}
+	}
+	opt.Data, err = hex.DecodeString(data)
+	if err != nil {
+		return opt, fmt.Errorf("EDNS option %s: data must be hex", name)
+	}
+	if opt.Code == EDNSCookie && len(opt.Data) == 0 {
+		opt.Data = make([]byte, 8)
+		rand.Read(opt.Data)
+	}
+	return opt, nil
+}
+
+// ParseConfig parses the small subset of YAML the config file uses: top level
+// "key: value" pairs whose value is a scalar, a flow list ([a, b]) or a block
+// list of "- item" lines.
+func ParseConfig(data []byte) (map[string][]string, error) {
+	values := make(map[string][]string)
+	var listKey string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey == "" {
+				return nil, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			values[listKey] = append(values[listKey], unquote(strings.TrimSpace(trimmed[1:])))
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return nil, fmt.Errorf("line %d: unexpected indentation", lineNo)
+		}
+
+		parts := strings.SplitN(trimmed, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		listKey = ""
+		switch {
+		case value == "":
+			listKey = key
+			values[key] = nil
+		case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+			var list []string
+			for _, item := range strings.Split(value[1:len(value)-1], ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, unquote(item))
+				}
+			}
+			values[key] = list
+		default:
+			values[key] = []string{unquote(value)}
+		}
+	}
+	return values, scanner.Err()
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package dnsclient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		yaml   string
+		check  func(Config) bool
+		hasErr bool
+	}{
+		{"defaults", "", func(c Config) bool {
+			return c.Transport == TransportUDP && c.Timeout == 5*time.Second && !c.DNSSEC && c.EDNSOptions == nil
+		}, false},
+		{"transport", "transport: tcp\n", func(c Config) bool { return c.Transport == TransportTCP }, false},
+		{"bad transport", "transport: quic\n", nil, true},
+		{"timeout", "timeout: 500ms\n", func(c Config) bool { return c.Timeout == 500*time.Millisecond }, false},
+		{"bad timeout", "timeout: 5\n", nil, true},
+		{"dnssec", "dnssec: true\n", func(c Config) bool { return c.DNSSEC }, false},
+		{"bad dnssec", "dnssec: maybe\n", nil, true},
+		{"empty timeout", "timeout:\n", nil, true},
+		{"empty timeout list", "timeout: []\n", nil, true},
+		{"empty dnssec", "dnssec: []\n", nil, true},
+		{"two timeouts", "timeout: [1s, 2s]\n", nil, true},
+		{"servers", "servers:\n  - 192.0.2.1\n  - 2001:db8::1\n", func(c Config) bool {
+			return len(c.Servers) == 2 && c.Servers[1] == "2001:db8::1"
+		}, false},
+		{"bufsize", "bufsize: 4096\n", func(c Config) bool { return c.UDPSize == 4096 }, false},
+		{"bad bufsize", "bufsize: 70000\n", nil, true},
+		{"empty bufsize", "bufsize: []\n", nil, true},
+		{"empty servers", "servers:\n", nil, true},
+		{"empty servers list", "servers: []\n", nil, true},
+		{"edns options", "edns-options:\n  - NSID\n  - OPT65001=beef\n  - cookie\n", func(c Config) bool {
+			o := c.EDNSOptions
+			return len(o) == 3 && o[0].Code == EDNSNSID && len(o[0].Data) == 0 &&
+				o[1].Code == 65001 && string(o[1].Data) == "\xbe\xef" &&
+				o[2].Code == EDNSCookie && len(o[2].Data) == 8
+		}, false},
+		{"unknown edns option", "edns-options: [bogus]\n", nil, true},
+		{"bad edns data", "edns-options: [nsid=xyz]\n", nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.yaml), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path)
+			if test.hasErr {
+				if err == nil {
+					t.Fatalf("LoadConfig() = %+v, want an error", config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(config) {
+				t.Errorf("LoadConfig() = %+v", config)
+			}
+		})
+	}
+}
+
+func TestConfigEDNSOptions(t *testing.T) {
+	var sent *DnsRequest
+	config := DefaultConfig()
+	config.Servers = []string{"test"}
+	config.EDNSOptions = []EDNSOption{{Code: EDNSNSID}, {Code: EDNSPadding, Data: make([]byte, 4)}}
+	opts := append(config.ClientOptions(), WithServerTransport("test", TransportFunc(func(ctx context.Context, msg []byte) ([]byte, error) {
+		request, err := ReadRequest(msg)
+		if err != nil {
+			return nil, err
+		}
+		sent = &request
+		return SerializeResponse(DnsResponse{Header: DnsHeader{Id: request.Header.Id, Flags: 0x8180, QdCount: 1}, Questions: request.Questions}), nil
+	})))
+	client := NewClient(opts...)
+	request := NewQuery("example.com", A).SetEDNS(DefaultUDPSize)
+	request.AddEDNSOption(EDNSOption{Code: EDNSPadding, Data: []byte{0}})
+	if _, err := client.Exchange(context.Background(), request); err != nil {
+		t.Fatal(err)
+	}
+	if sent == nil {
+		t.Fatal("no query sent")
+	}
+	var got []EDNSOption
+	for _, a := range sent.Additionals {
+		if a.Type == OPT {
+			got, _ = ParseEDNSOptions(a.RData)
+		}
+	}
+	if len(got) != 2 || got[0].Code != EDNSPadding || len(got[0].Data) != 1 || got[1].Code != EDNSNSID {
+		t.Errorf("query has options %+v, want the padding it had and NSID", got)
+	}
+	if len(request.Additionals) != 1 {
+		t.Errorf("Exchange() changed the request")
+	}
+}
@@ -0,0 +1,37 @@
+package dnsclient
+
+import (
+	"encoding/csv"
+	"strconv"
+)
+
+var CSVHeader = []string{"name", "type", "ttl", "rdata", "rcode", "server", "rtt"}
+
+// CSVRecords returns one row per answer in response. A response without
+// answers still gets a row so the rcode is not lost.
+func CSVRecords(question DnsQuestion, response *DnsResponse) [][]string {
+	rcode := strconv.Itoa(int(response.Header.Flags.RCode()))
+	rtt := strconv.FormatFloat(response.RTT.Seconds()*1000, 'f', 3, 64)
+	if len(response.Answers) == 0 {
+		return [][]string{{ToUnicode(question.QName), TypeToString(question.QType), "", "", rcode, response.Server, rtt}}
+	}
+
+	var rows [][]string
+	for _, a := range response.Answers {
+		rows = append(rows, []string{
+			ToUnicode(a.Name),
+			TypeToString(a.Type),
+			strconv.Itoa(int(a.TTL)),
+			a.RDataString(),
+			rcode,
+			response.Server,
+			rtt,
+		})
+	}
+	return rows
+}
+
+// WriteCSVError writes a row for a query that got no response at all.
+func WriteCSVError(w *csv.Writer, question DnsQuestion, err error) error {
+	return w.Write([]string{ToUnicode(question.QName), TypeToString(question.QType), "", err.Error(), "", "", ""})
+}
@@ -0,0 +1,55 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const CSYNC = 62
+
+// CSYNC flags (RFC 7477 section 2.1.1.2)
+const (
+	CSYNCImmediate  = 0x01
+	CSYNCSOAMinimum = 0x02
+)
+
+// CSYNCRecord is the RData of a CSYNC record (RFC 7477), listing the types
+// the parent should copy from the child zone.
+type CSYNCRecord struct {
+	Serial uint32
+	Flags  uint16
+	Types  []uint16
+}
+
+func (c CSYNCRecord) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%d %d %s", c.Serial, c.Flags, formatTypes(c.Types)))
+}
+
+func ParseCSYNC(rdata []byte) (CSYNCRecord, error) {
+	var c CSYNCRecord
+	if len(rdata) < 6 {
+		return c, errors.New("csync rdata too short")
+	}
+	c.Serial = binary.BigEndian.Uint32(rdata)
+	c.Flags = binary.BigEndian.Uint16(rdata[4:])
+	types, err := ParseTypeBitmap(rdata[6:])
+	if err != nil {
+		return c, err
+	}
+	c.Types = types
+	return c, nil
+}
+
+func init() {
+	RegisterType(CSYNC, RRType{
+		Format: func(rdata []byte) string {
+			c, err := ParseCSYNC(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return c.String()
+		},
+	})
+}
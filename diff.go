@@ -0,0 +1,145 @@
+package dnsclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RecordDiff describes one record that is not served identically by every server.
+type RecordDiff struct {
+	Record  string
+	TTLs    map[string]int32
+	Missing []string
+}
+
+type ResponseDiff struct {
+	Servers []string
+	RCodes  map[string]uint16
+	Errors  map[string]error
+	Records []RecordDiff
+}
+
+func (d ResponseDiff) String() string {
+	var b strings.Builder
+	b.WriteString("---- Diff ----\nRCodes: [")
+	for _, s := range d.Servers {
+		if err, ok := d.Errors[s]; ok {
+			fmt.Fprintf(&b, "\n  { Server: %s, Error: %v }", s, err)
+			continue
+		}
+		fmt.Fprintf(&b, "\n  { Server: %s, RCode: %s }", s, RCodeToString(d.RCodes[s]))
+	}
+	b.WriteString("\n]\nRecords: [")
+	for _, r := range d.Records {
+		var ttls []string
+		for _, s := range d.Servers {
+			if ttl, ok := r.TTLs[s]; ok {
+				ttls = append(ttls, fmt.Sprintf("%s=%d", s, ttl))
+			}
+		}
+		fmt.Fprintf(&b, "\n  { %s, TTLs: { %s }, Missing: [%s] }", r.Record, strings.Join(ttls, ", "), strings.Join(r.Missing, ", "))
+	}
+	b.WriteString("\n]\n")
+	return b.String()
+}
+
+// Differs reports whether the servers disagreed about anything.
+func (d ResponseDiff) Differs() bool {
+	if len(d.Errors) > 0 || len(d.Records) > 0 {
+		return true
+	}
+	for _, s := range d.Servers {
+		if d.RCodes[s] != d.RCodes[d.Servers[0]] {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffResponses compares responses to the same request. Records are matched on
+// name, type, class and rdata; a record is reported if any server is missing it
+// or if the servers disagree on its TTL.
+func DiffResponses(servers []string, responses map[string]DnsResponse, errs map[string]error) ResponseDiff {
+	diff := ResponseDiff{
+		Servers: servers,
+		RCodes:  make(map[string]uint16),
+		Errors:  errs,
+	}
+
+	var keys []string
+	ttls := make(map[string]map[string]int32)
+	for _, s := range servers {
+		response, ok := responses[s]
+		if !ok {
+			continue
+		}
+		diff.RCodes[s] = response.Header.Flags.RCode()
+		for _, a := range response.Answers {
+			a = CanonicalRecord(a)
+			key := fmt.Sprintf("Name: %s, Type: %s, Class: %s, RData: %s", a.Name, TypeToString(a.Type), ClassToString(a.Class), a.RDataString())
+			if _, ok := ttls[key]; !ok {
+				ttls[key] = make(map[string]int32)
+				keys = append(keys, key)
+			}
+			ttls[key][s] = a.TTL
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		r := RecordDiff{Record: key, TTLs: ttls[key]}
+		var ttl int32
+		seen := false
+		sameTTL := true
+		for _, s := range servers {
+			if _, ok := responses[s]; !ok {
+				continue
+			}
+			t, ok := r.TTLs[s]
+			if !ok {
+				r.Missing = append(r.Missing, s)
+				continue
+			}
+			if !seen {
+				ttl, seen = t, true
+			} else if t != ttl {
+				sameTTL = false
+			}
+		}
+		if len(r.Missing) > 0 || !sameTTL {
+			diff.Records = append(diff.Records, r)
+		}
+	}
+	return diff
+}
+
+// DiffServers sends request to every server concurrently, each with a client
+// made with opts, and compares the answers. Servers that don't answer within
+// the client timeouts or before ctx is done are reported as errors.
+func DiffServers(ctx context.Context, servers []string, request DnsRequest, opts ...Option) ResponseDiff {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	responses := make(map[string]DnsResponse)
+	errs := make(map[string]error)
+	for _, s := range servers {
+		wg.Add(1)
+		go func(server string) {
+			defer wg.Done()
+			client := NewClient(withServers(opts, server)...)
+			query := request
+			response, err := client.Exchange(ctx, &query)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[server] = err
+				return
+			}
+			responses[server] = *response
+		}(s)
+	}
+	wg.Wait()
+	return DiffResponses(servers, responses, errs)
+}
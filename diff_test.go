@@ -0,0 +1,60 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+func TestDiffServersDeadServer(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.HandleA("example.com", net.IPv4(192, 0, 2, 1))
+
+	givesUp(t, func(ctx context.Context, dead string, opts ...Option) error {
+		servers := []string{server.Addr(), dead}
+		diff := DiffServers(ctx, servers, *NewQuery("example.com", A), opts...)
+		if _, ok := diff.RCodes[servers[0]]; !ok {
+			t.Errorf("no answer from the live server: %v", diff.Errors[servers[0]])
+		}
+		if !diff.Differs() {
+			t.Error("Differs() = false with a server failing")
+		}
+		return diff.Errors[dead]
+	})
+}
+
+func TestDiffServersSharedOpts(t *testing.T) {
+	var servers []string
+	for i := 0; i < 4; i++ {
+		server, err := dnstest.NewServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		server.HandleA("example.com", net.IPv4(192, 0, 2, byte(i)))
+		servers = append(servers, server.Addr())
+	}
+	// Room to append in place, as the CLI's opts have
+	opts := make([]Option, 1, 5)
+	opts[0] = WithTimeout(time.Second)
+	diff := DiffServers(context.Background(), servers, *NewQuery("example.com", A), opts...)
+	if len(diff.Errors) > 0 {
+		t.Fatal(diff.Errors)
+	}
+	// Every server has its own address, so each record is missing from three
+	if len(diff.Records) != len(servers) {
+		t.Fatalf("got %d differing records, want %d:\n%s", len(diff.Records), len(servers), diff)
+	}
+	for _, r := range diff.Records {
+		if len(r.TTLs) != 1 || len(r.Missing) != len(servers)-1 {
+			t.Errorf("record %s served by %d servers, want 1", r.Record, len(r.TTLs))
+		}
+	}
+}
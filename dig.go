@@ -0,0 +1,63 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ShortFormat is the -format template +short stands for: just the rdata of
+// the answers, one per line.
+const ShortFormat = `{{range .Answers}}{{rdata .}}\n{{end}}`
+
+// DigArgs rewrites the dig options in args (+short, +tcp, +time=2 and so on,
+// and @server) as the equivalent flags, so dig habits and scripts carry over.
+// The flags are moved to the front as the flag package stops at the first
+// name. Other arguments are left as they are.
+func DigArgs(args []string) ([]string, error) {
+	var flags, rest []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") && len(arg) > 1 {
+			flags = append(flags, "-server", arg[1:])
+			continue
+		}
+		if !strings.HasPrefix(arg, "+") {
+			rest = append(rest, arg)
+			continue
+		}
+		option, value := arg[1:], ""
+		if i := strings.IndexByte(option, '='); i >= 0 {
+			option, value = option[:i], option[i+1:]
+		}
+		switch option {
+		case "short":
+			flags = append(flags, "-format", ShortFormat)
+		case "tcp", "vc":
+			flags = append(flags, "-tcp")
+		case "dnssec":
+			flags = append(flags, "-dnssec")
+		case "norecurse", "norec":
+			flags = append(flags, "-norecurse")
+		case "trace":
+			flags = append(flags, "-trace")
+		case "time", "tries", "retry":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("+%s needs a number", option)
+			}
+			switch option {
+			case "time":
+				flags = append(flags, "-timeout", strconv.Itoa(n)+"s")
+			case "tries":
+				flags = append(flags, "-attempts", strconv.Itoa(n))
+			case "retry":
+				flags = append(flags, "-attempts", strconv.Itoa(n+1))
+			}
+		case "recurse", "rec", "notcp", "novc", "nodnssec", "noshort", "notrace":
+			// The defaults
+		default:
+			return nil, fmt.Errorf("unsupported dig option %s", arg)
+		}
+	}
+	return append(flags, rest...), nil
+}
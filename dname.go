@@ -0,0 +1,66 @@
+package dnsclient
+
+import (
+	"strings"
+)
+
+const DNAME = 39
+
+// Aliases are never followed further than this, in case of loops.
+const maxAliasChain = 16
+
+// DNAMESubstitute rewrites name for a DNAME at owner pointing to target (RFC
+// 6672 section 2.2): the owner suffix of name is replaced by target. The DNAME
+// only applies to names strictly below owner, and ok is false for anything
+// else or if the result would be longer than a name can be.
+func DNAMESubstitute(name, owner, target string) (string, bool) {
+	n, o := Name(name), Name(owner)
+	if n.CountLabels() <= o.CountLabels() || !n.IsSubdomainOf(o) {
+		return "", false
+	}
+	prefix := n.Labels()[:n.CountLabels()-o.CountLabels()]
+
+	result := strings.Join(prefix, ".")
+	if t := Name(target); !t.IsRoot() {
+		result += "." + strings.TrimSuffix(string(t), ".")
+	}
+	if len(SerializeName(result)) > 255 {
+		return "", false
+	}
+	return result, true
+}
+
+// FollowAliases follows the CNAME and DNAME records in answers starting from
+// name and returns the name the chain ends at, with the alias records used
+// along the way. A CNAME owned by the name wins over a DNAME above it, which
+// also covers the CNAMEs servers synthesize from DNAMEs.
+func FollowAliases(answers []DnsResourceRecord, name string) (string, []DnsResourceRecord) {
+	var chain []DnsResourceRecord
+	for len(chain) < maxAliasChain {
+		next := ""
+		var used DnsResourceRecord
+		for _, a := range answers {
+			if a.Type == CNAME && CompareNames(a.Name, name) == 0 {
+				next, used = string(a.RData), a
+				break
+			}
+		}
+		if next == "" {
+			for _, a := range answers {
+				if a.Type != DNAME {
+					continue
+				}
+				if n, ok := DNAMESubstitute(name, a.Name, string(a.RData)); ok {
+					next, used = n, a
+					break
+				}
+			}
+		}
+		if next == "" {
+			break
+		}
+		chain = append(chain, used)
+		name = next
+	}
+	return name, chain
+}
@@ -1,12 +1,13 @@
-package main
+package dnsclient
 
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
-	"syscall"
+	"time"
 )
 
 const (
@@ -26,6 +27,14 @@ const (
 	MINFO
 	MX
 	TXT
+	AAAA = 28
+	SRV  = 33
+)
+
+// QTYPE only values
+const (
+	AXFR = 252
+	ANY  = 255
 )
 
 const (
@@ -55,14 +64,39 @@ func (f DnsFlags) RD() uint16 {
 func (f DnsFlags) RA() uint16 {
 	return uint16(f >> 7 & 0b1)
 }
+
+// Z is the one bit left of the old three bit Z field, AD and CD took the
+// others (RFC 4035 section 3.2)
 func (f DnsFlags) Z() uint16 {
-	return uint16(f >> 4 & 0b111)
+	return uint16(f >> 6 & 0b1)
+}
+func (f DnsFlags) AD() uint16 {
+	return uint16(f >> 5 & 0b1)
+}
+func (f DnsFlags) CD() uint16 {
+	return uint16(f >> 4 & 0b1)
 }
 func (f DnsFlags) RCode() uint16 {
 	return uint16(f & 0b1111)
 }
+
+// String lists the flags that are set in lower case the way dig does, e.g.
+// "qr rd ra". The opcode and rcode are left to DnsHeader.String.
 func (f DnsFlags) String() string {
-	return fmt.Sprintf("QR: %d, OpCode: %d, AA: %d, TC: %d, RD: %d, RA: %d, Z: %d, RCode: %d", f.QR(), f.OpCode(), f.AA(), f.TC(), f.RD(), f.RA(), f.Z(), f.RCode())
+	bits := []struct {
+		name string
+		set  uint16
+	}{
+		{"qr", f.QR()}, {"aa", f.AA()}, {"tc", f.TC()}, {"rd", f.RD()},
+		{"ra", f.RA()}, {"z", f.Z()}, {"ad", f.AD()}, {"cd", f.CD()},
+	}
+	var names []string
+	for _, b := range bits {
+		if b.set == 1 {
+			names = append(names, b.name)
+		}
+	}
+	return strings.Join(names, " ")
 }
 
 type DnsHeader struct {
@@ -75,7 +109,7 @@ type DnsHeader struct {
 }
 
 func (h DnsHeader) String() string {
-	return fmt.Sprintf("Id: %d, Flags: { %s }, QdCount: %d, AnCount: %d, NsCount: %d, ArCount: %d", h.Id, h.Flags, h.QdCount, h.AnCount, h.NsCount, h.ArCount)
+	return fmt.Sprintf("Id: %d, OpCode: %s, RCode: %s, Flags: [%s], QdCount: %d, AnCount: %d, NsCount: %d, ArCount: %d", h.Id, OpCodeToString(h.Flags.OpCode()), RCodeToString(h.Flags.RCode()), h.Flags, h.QdCount, h.AnCount, h.NsCount, h.ArCount)
 }
 
 type DnsQuestion struct {
@@ -85,12 +119,13 @@ type DnsQuestion struct {
 }
 
 func (q DnsQuestion) String() string {
-	return fmt.Sprintf("QName: %s, QType: %d, QClass: %d", q.QName, q.QType, q.QClass)
+	return fmt.Sprintf("QName: %s, QType: %s, QClass: %s", ToUnicode(q.QName), TypeToString(q.QType), ClassToString(q.QClass))
 }
 
 type DnsRequest struct {
-	Header    DnsHeader
-	Questions []DnsQuestion
+	Header      DnsHeader
+	Questions   []DnsQuestion
+	Additionals []DnsResourceRecord
 }
 
 func (r DnsRequest) String() string {
@@ -98,7 +133,15 @@ func (r DnsRequest) String() string {
 	for _, q := range r.Questions {
 		qStr += fmt.Sprintf("\n  { %s }", q)
 	}
-	return fmt.Sprintf("Header: { %s }\nQuestions: [ %s\n]", r.Header, qStr)
+	s := fmt.Sprintf("Header: { %s }\nQuestions: [ %s\n]", r.Header, qStr)
+	if len(r.Additionals) > 0 {
+		var arStr string
+		for _, a := range r.Additionals {
+			arStr += fmt.Sprintf("\n  { %s }", a)
+		}
+		s += fmt.Sprintf("\nAdditionals: [%s\n]", arStr)
+	}
+	return s
 }
 
 type DnsResourceRecord struct {
@@ -110,43 +153,99 @@ type DnsResourceRecord struct {
 	RData    []byte
 }
 
+func (r DnsResourceRecord) RDataString() string {
+	return FormatRData(r.Type, r.RData)
+}
+
 func (r DnsResourceRecord) String() string {
-	switch r.Type {
-	case CNAME, NS:
-		return fmt.Sprintf("Name: %s, Type: %d, Class: %d, TTL: %d, RDLength: %d, RData: %s", r.Name, r.Type, r.Class, r.TTL, r.RDLength, string(r.RData))
-	default:
-		return fmt.Sprintf("Name: %s, Type: %d, Class: %d, TTL: %d, RDLength: %d, RData: %v", r.Name, r.Type, r.Class, r.TTL, r.RDLength, r.RData)
-	}
+	return fmt.Sprintf("Name: %s, Type: %s, Class: %s, TTL: %d, RDLength: %d, RData: %s", ToUnicode(r.Name), TypeToString(r.Type), ClassToString(r.Class), r.TTL, r.RDLength, r.RDataString())
 }
 
 type DnsResponse struct {
-	Header    DnsHeader
-	Questions []DnsQuestion
-	Answers   []DnsResourceRecord
+	Header      DnsHeader
+	Questions   []DnsQuestion
+	Answers     []DnsResourceRecord
+	Authorities []DnsResourceRecord
+	Additionals []DnsResourceRecord
+
+	// Where the response came from and how long it took, filled in when sending
+	Server string
+	RTT    time.Duration
+	// HTTP version that carried a DoH response, e.g. HTTP/2.0
+	Protocol string
+	// Whether the DoT or DoH server's certificate was verified
+	Authenticated bool
+	// When Client.Exchange got the response
+	Time time.Time
+	// What carried the response: udp, tcp, tls, https, unix or unixgram
+	Transport string
+	// Size of the message as received, in bytes
+	Size int
+	// The message as received, sharing the buffer it was read from
+	Raw []byte
+	// Whether Client.Exchange answered from its cache
+	Cached bool
 }
 
 func (r DnsResponse) String() string {
+	return r.format(DnsResourceRecord.String)
+}
+
+func (r DnsResponse) format(record func(DnsResourceRecord) string) string {
 	var qStr string
 	var aStr string
 	for _, q := range r.Questions {
 		qStr += fmt.Sprintf("\n  { %s }", q)
 	}
 	for _, a := range r.Answers {
-		aStr += fmt.Sprintf("\n  { %s }", a)
+		aStr += fmt.Sprintf("\n  { %s }", record(a))
+	}
+	s := fmt.Sprintf("Header: { %s }\nQuestions: [%s\n]\nAnswers: [%s\n]", r.Header, qStr, aStr)
+	if len(r.Authorities) > 0 {
+		var nsStr string
+		for _, a := range r.Authorities {
+			nsStr += fmt.Sprintf("\n  { %s }", record(a))
+		}
+		s += fmt.Sprintf("\nAuthorities: [%s\n]", nsStr)
 	}
-	return fmt.Sprintf("Header: { %s }\nQuestions: [%s\n]\nAnswers: [%s\n]", r.Header, qStr, aStr)
+	if len(r.Additionals) > 0 {
+		var arStr string
+		for _, a := range r.Additionals {
+			arStr += fmt.Sprintf("\n  { %s }", record(a))
+		}
+		s += fmt.Sprintf("\nAdditionals: [%s\n]", arStr)
+	}
+	return s
 }
 
+// Names are at most 255 octets on the wire (RFC 1035 section 2.3.4), so a
+// name can't take more pointers than that to read either.
+const (
+	maxNameLength  = 255
+	maxPointerHops = 127
+)
+
+// ErrBadPointer is returned for names with a compression pointer that
+// doesn't point to an earlier name, which is how pointer loops are made.
+var ErrBadPointer = errors.New("compression pointer doesn't point back")
+
+// ErrNameTooLong is returned for names longer than 255 octets.
+var ErrNameTooLong = errors.New("name longer than 255 octets")
+
+// ReadName reads a name, following compression pointers. A pointer has to
+// point before the labels leading up to it, which keeps them from looping.
 func ReadName(r *bytes.Reader) (string, error) {
-	// Should I be declaring stuff here?
 	var name string
-	var compressedName string
-	var length uint8
-	var pointer uint16
-	var nextByte byte
-	var err error
-	for {
-		length, err = r.ReadByte()
+	// Where the labels read since the last pointer start
+	segment, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", err
+	}
+	// Where reading continues after the name, once a pointer is followed
+	end := int64(-1)
+	wire := 1
+	for hops := 0; ; {
+		length, err := r.ReadByte()
 		if err != nil {
 			return "", err
 		}
@@ -154,52 +253,53 @@ func ReadName(r *bytes.Reader) (string, error) {
 		// Handle compressed name
 		// 0xc0 = 0b11000000
 		if length&0xc0 == 0xc0 {
-			// Get pointer
-			nextByte, err = r.ReadByte()
+			nextByte, err := r.ReadByte()
 			if err != nil {
 				return "", err
 			}
-			pointer = uint16(length&0b00111111)<<8 | uint16(nextByte)
-
-			// Save old reader position
-			pos, err := r.Seek(0, io.SeekCurrent)
-			if err != nil {
-				return "", err
+			pointer := int64(length&0b00111111)<<8 | int64(nextByte)
+			if pointer >= segment {
+				return "", ErrBadPointer
 			}
-
-			// Seek to pointer and read name
-			_, err = r.Seek(int64(pointer), io.SeekStart)
-			if err != nil {
-				return "", err
+			if hops++; hops > maxPointerHops {
+				return "", ErrBadPointer
 			}
-			compressedName, err = ReadName(r)
-			if err != nil {
-				return "", err
+			if end < 0 {
+				if end, err = r.Seek(0, io.SeekCurrent); err != nil {
+					return "", err
+				}
 			}
-			name += compressedName
-
-			// Restore reader position
-			_, err = r.Seek(pos, io.SeekStart)
-			if err != nil {
+			if segment, err = r.Seek(pointer, io.SeekStart); err != nil {
 				return "", err
 			}
-			return name, nil
+			continue
+		}
+		if length&0xc0 != 0 {
+			return "", fmt.Errorf("unsupported label type %#x", length&0xc0)
 		}
 
 		if length == 0 {
 			// Removes last dot. This is hacky and should be done better :)
-			name = name[:len(name)-1]
+			if len(name) > 0 {
+				name = name[:len(name)-1]
+			}
 			break
 		}
 
-		// Reads label. Is there not a better way to do this?
+		if wire += int(length) + 1; wire > maxNameLength {
+			return "", ErrNameTooLong
+		}
 		label := make([]byte, length)
-		_, err = r.Read(label)
-		if err != nil {
+		if _, err := io.ReadFull(r, label); err != nil {
 			return "", err
 		}
 		name += string(label) + "."
 	}
+	if end >= 0 {
+		if _, err := r.Seek(end, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
 	return name, nil
 }
 
@@ -213,11 +313,17 @@ func ReadQuestion(r *bytes.Reader) (DnsQuestion, error) {
 	}
 	q.QName = QName
 
-	binary.Read(r, binary.BigEndian, &q.QType)
-	binary.Read(r, binary.BigEndian, &q.QClass)
+	if err := binary.Read(r, binary.BigEndian, &q.QType); err != nil {
+		return q, errTruncated
+	}
+	if err := binary.Read(r, binary.BigEndian, &q.QClass); err != nil {
+		return q, errTruncated
+	}
 	return q, nil
 }
 
+var errTruncated = errors.New("message ends in the middle of a record")
+
 func ReadResourceRecord(r *bytes.Reader) (DnsResourceRecord, error) {
 	var res DnsResourceRecord
 	name, err := ReadName(r)
@@ -225,31 +331,39 @@ func ReadResourceRecord(r *bytes.Reader) (DnsResourceRecord, error) {
 		return res, err
 	}
 	res.Name = name
-	binary.Read(r, binary.BigEndian, &res.Type)
-	binary.Read(r, binary.BigEndian, &res.Class)
-	binary.Read(r, binary.BigEndian, &res.TTL)
-	binary.Read(r, binary.BigEndian, &res.RDLength)
-
-	switch res.Type {
-	case CNAME, NS:
-		name, err := ReadName(r)
-		if err != nil {
-			return res, err
-		}
-		res.RData = []byte(name)
-	default:
-		res.RData = make([]byte, res.RDLength)
-		_, err = r.Read(res.RData)
-		if err != nil {
-			return res, err
+	for _, field := range []interface{}{&res.Type, &res.Class, &res.TTL, &res.RDLength} {
+		if err := binary.Read(r, binary.BigEndian, field); err != nil {
+			return res, errTruncated
 		}
 	}
+	if r.Len() < int(res.RDLength) {
+		return res, errTruncated
+	}
+
+	start, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return res, err
+	}
+	res.RData, err = ParseRData(r, res.Type, res.RDLength)
+	if err != nil {
+		return res, err
+	}
+
+	// Always continue right after the RData, whatever the parser consumed
+	_, err = r.Seek(start+int64(res.RDLength), io.SeekStart)
+	if err != nil {
+		return res, err
+	}
 
 	return res, nil
 }
 
 func SerializeName(name string) []byte {
 	var buf bytes.Buffer
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return []byte{0}
+	}
 	for _, label := range strings.Split(name, ".") {
 		buf.WriteByte(byte(len(label)))
 		buf.WriteString(label)
@@ -264,126 +378,12 @@ func SerializeQuestion(buf *bytes.Buffer, question DnsQuestion) {
 	binary.Write(buf, binary.BigEndian, question.QClass)
 }
 
-func ValidateResponseHeader(response DnsResponse, request DnsRequest) {
-	if response.Header.Id != request.Header.Id {
-		panic(fmt.Sprintf("response id %d does not match request id %d", response.Header.Id, request.Header.Id))
-	}
-	if response.Header.QdCount != request.Header.QdCount {
-		panic(fmt.Sprintf("response qdcount %d does not match request qdcount %d", response.Header.QdCount, request.Header.QdCount))
-	}
-	if response.Header.AnCount == 0 {
-		panic("response ancount is 0")
-	}
-	if response.Header.NsCount != request.Header.NsCount {
-		panic(fmt.Sprintf("response nscount %d does not match request nscount %d", response.Header.NsCount, request.Header.NsCount))
-	}
-	if response.Header.ArCount != request.Header.ArCount {
-		panic(fmt.Sprintf("response arcount %d does not match request arcount %d", response.Header.ArCount, request.Header.ArCount))
-	}
-	if response.Header.Flags.QR() != 1 {
-		panic("response qr is not 1 (response)")
-	}
-	if response.Header.Flags.OpCode() != 0 {
-		panic("response opcode is not 0 (standard query)")
-	}
-	if response.Header.Flags.AA() != 0 {
-		panic("response aa is not 0 (not authoritative)")
-	}
-	if response.Header.Flags.TC() != 0 {
-		panic("response tc is not 0 (not truncated)")
-	}
-	if response.Header.Flags.RD() != request.Header.Flags.RD() {
-		panic(fmt.Sprintf("response rd %d does not match request rd %d (recursion desired)", response.Header.Flags.RD(), request.Header.Flags.RD()))
-	}
-	if response.Header.Flags.RA() != 1 {
-		panic("response ra is not 1 (recursion available)")
-	}
-	if response.Header.Flags.Z() != 0 {
-		panic("response z is not 0")
-	}
-	if response.Header.Flags.RCode() != 0 {
-		panic("response rcode is not 0 (no error)")
-	}
-}
-
-func main() {
-	var urls = []string{"github.com"}
-
-	var request DnsRequest
-	request.Header = DnsHeader{
-		Id:      12345,
-		Flags:   0x0100,
-		QdCount: uint16(len(urls)),
-		AnCount: 0,
-		NsCount: 0,
-		ArCount: 0,
-	}
-	for _, u := range urls {
-		request.Questions = append(request.Questions, DnsQuestion{
-			QName:  u,
-			QType:  NS,
-			QClass: IN,
-		})
-	}
-
-	fmt.Printf("---- Request ----\n%v\n\n", request)
-
-	// Serialize query: write header and questions
-	var reqBuf bytes.Buffer
-	binary.Write(&reqBuf, binary.BigEndian, request.Header)
-	for _, q := range request.Questions {
-		SerializeQuestion(&reqBuf, q)
-	}
-
-	// Send reqBuf
-	sock, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
-	if err != nil {
-		panic(err)
-	}
-	err = syscall.Bind(sock, &syscall.SockaddrInet4{Port: 53})
-	if err != nil {
-		panic(err)
-	}
-	err = syscall.Sendto(sock, reqBuf.Bytes(), 0, &syscall.SockaddrInet4{Port: 53, Addr: [4]byte{8, 8, 8, 8}})
-	if err != nil {
-		panic(err)
-	}
-
-	// Recv response
-	resBuf := make([]byte, 512)
-	n, _, err := syscall.Recvfrom(sock, resBuf, 0)
-	if err != nil {
-		panic(err)
-	}
-
-	// Read response header
-	responseReader := bytes.NewReader(resBuf[:n])
-	var response DnsResponse
-	binary.Read(responseReader, binary.BigEndian, &response.Header)
-	ValidateResponseHeader(response, request)
-
-	// Read response questions
-	for i := 0; i < int(response.Header.QdCount); i++ {
-		question, err := ReadQuestion(responseReader)
-		if err != nil {
-			panic(err)
-		}
-		response.Questions = append(response.Questions, question)
-	}
-
-	// Read response answers
-	for i := 0; i < int(response.Header.AnCount); i++ {
-		answer, err := ReadResourceRecord(responseReader)
-		if err != nil {
-			panic(err)
-		}
-		response.Answers = append(response.Answers, answer)
-	}
-
-	fmt.Printf("---- Response ----\n%v\n", response)
-
-	err = syscall.Close(sock)
-	if err != nil {
-		panic(err)
-	}
+func SerializeResourceRecord(buf *bytes.Buffer, record DnsResourceRecord) {
+	rdata := SerializeRData(record.Type, record.RData)
+	buf.Write(SerializeName(record.Name))
+	binary.Write(buf, binary.BigEndian, record.Type)
+	binary.Write(buf, binary.BigEndian, record.Class)
+	binary.Write(buf, binary.BigEndian, record.TTL)
+	binary.Write(buf, binary.BigEndian, uint16(len(rdata)))
+	buf.Write(rdata)
 }
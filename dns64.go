@@ -0,0 +1,124 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// WellKnownNAT64Prefix is the prefix from RFC 6052 section 2.1.
+var WellKnownNAT64Prefix = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+
+// The well known IPv4 addresses of ipv4only.arpa (RFC 7050 section 2.2)
+var ipv4onlyAddrs = []net.IP{net.IPv4(192, 0, 0, 170), net.IPv4(192, 0, 0, 171)}
+
+// RFC 6052 only allows these prefix lengths.
+var nat64PrefixLengths = []int{96, 64, 56, 48, 40, 32}
+
+// SynthesizeAAAA embeds ipv4 in the NAT64 prefix as described in RFC 6052
+// section 2.2. Bits 64 to 71 are reserved and always zero, so for prefixes
+// shorter than /96 the address skips over them.
+func SynthesizeAAAA(prefix *net.IPNet, ipv4 net.IP) (net.IP, error) {
+	v4 := ipv4.To4()
+	if v4 == nil {
+		return nil, fmt.Errorf("%s is not an ipv4 address", ipv4)
+	}
+	ones, bits := prefix.Mask.Size()
+	if bits != 128 || !validNAT64PrefixLength(ones) {
+		return nil, fmt.Errorf("invalid nat64 prefix length /%d", ones)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP.To16()[:ones/8])
+	pos := ones / 8
+	for _, b := range v4 {
+		if pos == 8 {
+			pos++
+		}
+		ip[pos] = b
+		pos++
+	}
+	return ip, nil
+}
+
+// ExtractIPv4 is the reverse of SynthesizeAAAA for a prefix of length ones.
+func ExtractIPv4(ip net.IP, ones int) net.IP {
+	ip = ip.To16()
+	v4 := make(net.IP, 0, net.IPv4len)
+	pos := ones / 8
+	for len(v4) < net.IPv4len {
+		if pos == 8 {
+			pos++
+		}
+		v4 = append(v4, ip[pos])
+		pos++
+	}
+	return v4
+}
+
+func validNAT64PrefixLength(ones int) bool {
+	for _, l := range nat64PrefixLengths {
+		if ones == l {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscoverNAT64Prefixes detects a DNS64 resolver by asking for the AAAA
+// records of ipv4only.arpa, which has no real ones (RFC 7050). Any answer was
+// synthesized, and the prefixes are found by locating the well known IPv4
+// addresses inside them.
+func (c *Client) DiscoverNAT64Prefixes(ctx context.Context) ([]*net.IPNet, error) {
+	ips, err := c.LookupIP(ctx, "ip6", "ipv4only.arpa")
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, errors.New("no DNS64: ipv4only.arpa has no AAAA records")
+		}
+		return nil, err
+	}
+
+	var prefixes []*net.IPNet
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		for _, ones := range nat64PrefixLengths {
+			v4 := ExtractIPv4(ip, ones)
+			if !v4.Equal(ipv4onlyAddrs[0]) && !v4.Equal(ipv4onlyAddrs[1]) {
+				continue
+			}
+			prefix := &net.IPNet{IP: ip.Mask(net.CIDRMask(ones, 128)), Mask: net.CIDRMask(ones, 128)}
+			if !seen[prefix.String()] {
+				seen[prefix.String()] = true
+				prefixes = append(prefixes, prefix)
+			}
+			break
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil, errors.New("ipv4only.arpa AAAA records do not contain a known nat64 prefix")
+	}
+	return prefixes, nil
+}
+
+// LookupIP64 returns the AAAA addresses of host, synthesizing them from its
+// A records with prefix when it has none, as a DNS64 resolver would.
+func (c *Client) LookupIP64(ctx context.Context, host string, prefix *net.IPNet) ([]net.IP, error) {
+	ips, err := c.LookupIP(ctx, "ip6", host)
+	if err == nil {
+		return ips, nil
+	}
+	v4s, err4 := c.LookupIP(ctx, "ip4", host)
+	if err4 != nil {
+		return nil, err
+	}
+	for _, v4 := range v4s {
+		ip, err := SynthesizeAAAA(prefix, v4)
+		if err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
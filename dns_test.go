@@ -0,0 +1,149 @@
+package dnsclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// A query whose name is a pointer to itself
+var pointerLoop = []byte{
+	0x00, 0x01, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01,
+}
+
+var errAny = errors.New("any error")
+
+func TestReadName(t *testing.T) {
+	long := bytes.Repeat([]byte{63}, 1)
+	for i := 0; i < 63; i++ {
+		long = append(long, 'a')
+	}
+	tests := []struct {
+		name string
+		msg  []byte
+		// Where the name starts
+		at   int
+		want string
+		// errAny for any error
+		err error
+	}{
+		{"plain", []byte{3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0}, 0, "www.example.com", nil},
+		{"root", []byte{0}, 0, "", nil},
+		{"pointer back", []byte{7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0, 3, 'w', 'w', 'w', 0xc0, 0x00}, 9, "www.example", nil},
+		{"pointer to a pointer", []byte{3, 'c', 'o', 'm', 0, 0xc0, 0x00, 3, 'w', 'w', 'w', 0xc0, 0x05}, 7, "www.com", nil},
+		{"pointer to itself", []byte{0xc0, 0x00}, 0, "", ErrBadPointer},
+		{"pointer forward", []byte{0xc0, 0x02, 0}, 0, "", ErrBadPointer},
+		{"pointer loop", []byte{0, 0, 0xc0, 0x04, 0xc0, 0x02}, 2, "", ErrBadPointer},
+		{"label in a loop", []byte{1, 'a', 0xc0, 0x00}, 0, "", ErrBadPointer},
+		{"too long", bytes.Repeat(long, 5), 0, "", ErrNameTooLong},
+		{"extended label", []byte{0x41, 0}, 0, "", errAny},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := bytes.NewReader(test.msg)
+			r.Seek(int64(test.at), 0)
+			name, err := ReadName(r)
+			if test.err != nil {
+				if err == nil || test.err != errAny && !errors.Is(err, test.err) {
+					t.Fatalf("ReadName() = %q, %v, want error %v", name, err, test.err)
+				}
+				return
+			}
+			if err != nil || name != test.want {
+				t.Fatalf("ReadName() = %q, %v, want %q", name, err, test.want)
+			}
+			if r.Len() != 0 {
+				t.Errorf("%d bytes left after the name", r.Len())
+			}
+		})
+	}
+}
+
+func TestPointerLoop(t *testing.T) {
+	if _, err := ReadRequest(pointerLoop); !errors.Is(err, ErrBadPointer) {
+		t.Errorf("ReadRequest() error = %v, want %v", err, ErrBadPointer)
+	}
+	if _, err := ReadResponse(pointerLoop); !errors.Is(err, ErrBadPointer) {
+		t.Errorf("ReadResponse() error = %v, want %v", err, ErrBadPointer)
+	}
+	f := &Forwarder{Upstreams: []string{"127.0.0.1:1"}}
+	if _, err := f.Forward(pointerLoop); err == nil {
+		t.Error("Forward() of a query with a pointer loop didn't fail")
+	}
+}
+
+func TestResponseRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		response DnsResponse
+	}{
+		{"empty", DnsResponse{Header: DnsHeader{Id: 1, Flags: 0x8180}}},
+		{"answer", DnsResponse{
+			Header:    DnsHeader{Id: 2, Flags: 0x8180, QdCount: 1, AnCount: 1},
+			Questions: []DnsQuestion{{QName: "example.com", QType: A, QClass: IN}},
+			Answers:   []DnsResourceRecord{{Name: "example.com", Type: A, Class: IN, TTL: 300, RDLength: 4, RData: []byte{192, 0, 2, 1}}},
+		}},
+		{"nxdomain", DnsResponse{
+			Header:      DnsHeader{Id: 3, Flags: 0x8183, QdCount: 1, NsCount: 1},
+			Questions:   []DnsQuestion{{QName: "nx.example.com", QType: AAAA, QClass: IN}},
+			Authorities: []DnsResourceRecord{{Name: "example.com", Type: TXT, Class: IN, TTL: 60, RDLength: 6, RData: []byte("\x05hello")}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			msg := SerializeResponse(test.response)
+			got, err := ReadResponse(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Header != test.response.Header || len(got.Questions) != len(test.response.Questions) ||
+				len(got.Answers) != len(test.response.Answers) || len(got.Authorities) != len(test.response.Authorities) {
+				t.Fatalf("ReadResponse() = %+v, want %+v", got, test.response)
+			}
+			for i, q := range test.response.Questions {
+				if got.Questions[i] != q {
+					t.Errorf("question %d = %v, want %v", i, got.Questions[i], q)
+				}
+			}
+			for i, a := range test.response.Answers {
+				if got.Answers[i].Name != a.Name || got.Answers[i].Type != a.Type || !bytes.Equal(got.Answers[i].RData, a.RData) {
+					t.Errorf("answer %d = %v, want %v", i, got.Answers[i], a)
+				}
+			}
+		})
+	}
+}
+
+func TestReadResponseTruncated(t *testing.T) {
+	msg := SerializeResponse(DnsResponse{
+		Header:    DnsHeader{Id: 2, Flags: 0x8180, QdCount: 1, AnCount: 1},
+		Questions: []DnsQuestion{{QName: "example.com", QType: A, QClass: IN}},
+		Answers:   []DnsResourceRecord{{Name: "example.com", Type: A, Class: IN, TTL: 300, RDLength: 4, RData: []byte{192, 0, 2, 1}}},
+	})
+	for n := 0; n < len(msg); n++ {
+		if _, err := ReadResponse(msg[:n]); err == nil {
+			t.Errorf("ReadResponse() of the first %d of %d bytes didn't fail", n, len(msg))
+		}
+	}
+}
+
+func FuzzReadResponse(f *testing.F) {
+	f.Add(pointerLoop)
+	f.Add(SerializeRequest(*NewQuery("example.com", A).SetEDNS(DefaultUDPSize)))
+	f.Add(SerializeResponse(DnsResponse{
+		Header:    DnsHeader{Id: 2, Flags: 0x8180, QdCount: 1, AnCount: 1},
+		Questions: []DnsQuestion{{QName: "xn--bcher-kva.example", QType: MX, QClass: IN}},
+		Answers:   []DnsResourceRecord{{Name: "xn--bcher-kva.example", Type: MX, Class: IN, TTL: 300, RData: []byte{0, 10, 2, 'm', 'x', 0xc0, 0x0c}}},
+	}))
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		response, err := ReadResponse(msg)
+		if err != nil {
+			return
+		}
+		// Whatever parses has to print and encode again
+		_ = response.String()
+		SerializeResponse(response)
+		ReadRequest(msg)
+	})
+}
@@ -0,0 +1,350 @@
+package dnsclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RRSIG  = 46
+	DNSKEY = 48
+)
+
+// DNSKEY flags (RFC 4034 section 2.1.1)
+const (
+	DNSKEYFlagZone = 0x0100
+	DNSKEYFlagSEP  = 0x0001
+)
+
+// DNSSEC algorithm numbers we can verify
+const (
+	AlgRSASHA1          = 5
+	AlgRSASHA1NSEC3SHA1 = 7
+	AlgRSASHA256        = 8
+	AlgRSASHA512        = 10
+	AlgECDSAP256SHA256  = 13
+	AlgECDSAP384SHA384  = 14
+	AlgED25519          = 15
+)
+
+type DNSKEYRecord struct {
+	Flags     uint16
+	Protocol  uint8
+	Algorithm uint8
+	PublicKey []byte
+}
+
+func (k DNSKEYRecord) String() string {
+	return fmt.Sprintf("%d %d %d %s", k.Flags, k.Protocol, k.Algorithm, base64.StdEncoding.EncodeToString(k.PublicKey))
+}
+
+func ParseDNSKEY(rdata []byte) (DNSKEYRecord, error) {
+	var key DNSKEYRecord
+	if len(rdata) < 4 {
+		return key, errors.New("dnskey rdata too short")
+	}
+	key.Flags = binary.BigEndian.Uint16(rdata)
+	key.Protocol = rdata[2]
+	key.Algorithm = rdata[3]
+	key.PublicKey = rdata[4:]
+	return key, nil
+}
+
+func SerializeDNSKEY(key DNSKEYRecord) []byte {
+	rdata := []byte{byte(key.Flags >> 8), byte(key.Flags), key.Protocol, key.Algorithm}
+	return append(rdata, key.PublicKey...)
+}
+
+type RRSIGRecord struct {
+	TypeCovered uint16
+	Algorithm   uint8
+	Labels      uint8
+	OriginalTTL uint32
+	Expiration  uint32
+	Inception   uint32
+	KeyTag      uint16
+	SignerName  string
+	Signature   []byte
+}
+
+func (s RRSIGRecord) String() string {
+	const layout = "20060102150405"
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s. %s", TypeToString(s.TypeCovered), s.Algorithm, s.Labels, s.OriginalTTL,
+		time.Unix(int64(s.Expiration), 0).UTC().Format(layout), time.Unix(int64(s.Inception), 0).UTC().Format(layout),
+		s.KeyTag, s.SignerName, base64.StdEncoding.EncodeToString(s.Signature))
+}
+
+func ParseRRSIG(rdata []byte) (RRSIGRecord, error) {
+	var sig RRSIGRecord
+	if len(rdata) < 19 {
+		return sig, errors.New("rrsig rdata too short")
+	}
+	sig.TypeCovered = binary.BigEndian.Uint16(rdata)
+	sig.Algorithm = rdata[2]
+	sig.Labels = rdata[3]
+	sig.OriginalTTL = binary.BigEndian.Uint32(rdata[4:])
+	sig.Expiration = binary.BigEndian.Uint32(rdata[8:])
+	sig.Inception = binary.BigEndian.Uint32(rdata[12:])
+	sig.KeyTag = binary.BigEndian.Uint16(rdata[16:])
+	r := bytes.NewReader(rdata[18:])
+	signer, err := ReadName(r)
+	if err != nil {
+		return sig, err
+	}
+	sig.SignerName = signer
+	sig.Signature = rdata[len(rdata)-r.Len():]
+	return sig, nil
+}
+
+func init() {
+	RegisterType(DNSKEY, RRType{
+		Format: func(rdata []byte) string {
+			key, err := ParseDNSKEY(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return key.String()
+		},
+	})
+	RegisterType(RRSIG, RRType{
+		Format: func(rdata []byte) string {
+			sig, err := ParseRRSIG(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return sig.String()
+		},
+	})
+}
+
+// KeyTag computes the key tag of a DNSKEY (RFC 4034 appendix B).
+func KeyTag(key DNSKEYRecord) uint16 {
+	return keyTag(SerializeDNSKEY(key))
+}
+
+func keyTag(rdata []byte) uint16 {
+	var ac uint32
+	for i, b := range rdata {
+		if i&1 == 0 {
+			ac += uint32(b) << 8
+		} else {
+			ac += uint32(b)
+		}
+	}
+	ac += ac >> 16 & 0xffff
+	return uint16(ac & 0xffff)
+}
+
+// DS digest types
+const (
+	DigestSHA1   = 1
+	DigestSHA256 = 2
+	DigestSHA384 = 4
+)
+
+// NewDS generates the DS record for the DNSKEY of zone owner (RFC 4034
+// section 5.1.4), which is what the parent zone should publish for it.
+func NewDS(owner string, key DNSKEYRecord, digestType uint8) (DSRecord, error) {
+	digest, err := dsDigest(owner, SerializeDNSKEY(key), digestType)
+	if err != nil {
+		return DSRecord{}, err
+	}
+	return DSRecord{
+		KeyTag:     KeyTag(key),
+		Algorithm:  key.Algorithm,
+		DigestType: digestType,
+		Digest:     digest,
+	}, nil
+}
+
+func dsDigest(owner string, rdata []byte, digestType uint8) ([]byte, error) {
+	data := append(SerializeName(strings.ToLower(owner)), rdata...)
+	switch digestType {
+	case DigestSHA1:
+		h := sha1.Sum(data)
+		return h[:], nil
+	case DigestSHA256:
+		h := sha256.Sum256(data)
+		return h[:], nil
+	case DigestSHA384:
+		h := sha512.Sum384(data)
+		return h[:], nil
+	}
+	return nil, fmt.Errorf("unsupported ds digest type %d", digestType)
+}
+
+// canonicalRData returns rdata in the canonical form used for signing: wire
+// format with the names in the RFC 4034 section 6.2 types lowercased.
+func canonicalRData(t uint16, rdata []byte) []byte {
+	switch t {
+	case NS, CNAME, PTR, DNAME:
+		return SerializeName(strings.ToLower(string(rdata)))
+	case MX, SRV:
+		fixed := 2
+		if t == SRV {
+			fixed = 6
+		}
+		if len(rdata) <= fixed {
+			return rdata
+		}
+		name, err := ReadName(bytes.NewReader(rdata[fixed:]))
+		if err != nil {
+			return rdata
+		}
+		return append(append([]byte{}, rdata[:fixed]...), SerializeName(strings.ToLower(name))...)
+	case MINFO, RP:
+		return canonicalTwoNames(rdata)
+	case SOA:
+		return canonicalSOA(rdata)
+	}
+	return SerializeRData(t, rdata)
+}
+
+// signedData builds the data an RRSIG signs (RFC 4034 section 3.1.8.1): the
+// RRSIG rdata without the signature followed by the RRset in canonical form
+// and order.
+func signedData(sig RRSIGRecord, rrset []DnsResourceRecord) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, sig.TypeCovered)
+	buf.WriteByte(sig.Algorithm)
+	buf.WriteByte(sig.Labels)
+	binary.Write(&buf, binary.BigEndian, sig.OriginalTTL)
+	binary.Write(&buf, binary.BigEndian, sig.Expiration)
+	binary.Write(&buf, binary.BigEndian, sig.Inception)
+	binary.Write(&buf, binary.BigEndian, sig.KeyTag)
+	buf.Write(SerializeName(strings.ToLower(sig.SignerName)))
+
+	rdatas := make([][]byte, len(rrset))
+	for i, rr := range rrset {
+		rdatas[i] = canonicalRData(rr.Type, rr.RData)
+	}
+	sort.Slice(rdatas, func(i, j int) bool { return bytes.Compare(rdatas[i], rdatas[j]) < 0 })
+
+	owner := strings.ToLower(rrset[0].Name)
+	// Expanded wildcards are signed as the wildcard itself
+	if labels := nameLabels(owner); len(labels) > int(sig.Labels) {
+		owner = "*." + strings.Join(labels[len(labels)-int(sig.Labels):], ".")
+	}
+	for i, rdata := range rdatas {
+		if i > 0 && bytes.Equal(rdata, rdatas[i-1]) {
+			continue
+		}
+		buf.Write(SerializeName(owner))
+		binary.Write(&buf, binary.BigEndian, rrset[0].Type)
+		binary.Write(&buf, binary.BigEndian, rrset[0].Class)
+		binary.Write(&buf, binary.BigEndian, sig.OriginalTTL)
+		binary.Write(&buf, binary.BigEndian, uint16(len(rdata)))
+		buf.Write(rdata)
+	}
+	return buf.Bytes()
+}
+
+// VerifyRRSIG checks that sig is a currently valid signature by key over rrset.
+func VerifyRRSIG(sig RRSIGRecord, key DNSKEYRecord, rrset []DnsResourceRecord, now time.Time) error {
+	if len(rrset) == 0 {
+		return errors.New("empty rrset")
+	}
+	if sig.Algorithm != key.Algorithm {
+		return errors.New("signature and key algorithms differ")
+	}
+	t := uint32(now.Unix())
+	if t < sig.Inception {
+		return errors.New("signature is not valid yet")
+	}
+	if t > sig.Expiration {
+		return errors.New("signature has expired")
+	}
+
+	data := signedData(sig, rrset)
+	switch sig.Algorithm {
+	case AlgRSASHA1, AlgRSASHA1NSEC3SHA1, AlgRSASHA256, AlgRSASHA512:
+		pub, err := parseRSAKey(key.PublicKey)
+		if err != nil {
+			return err
+		}
+		var hash crypto.Hash
+		var digest []byte
+		switch sig.Algorithm {
+		case AlgRSASHA256:
+			h := sha256.Sum256(data)
+			hash, digest = crypto.SHA256, h[:]
+		case AlgRSASHA512:
+			h := sha512.Sum512(data)
+			hash, digest = crypto.SHA512, h[:]
+		default:
+			h := sha1.Sum(data)
+			hash, digest = crypto.SHA1, h[:]
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig.Signature)
+	case AlgECDSAP256SHA256, AlgECDSAP384SHA384:
+		curve, size := elliptic.P256(), 32
+		var digest []byte
+		if sig.Algorithm == AlgECDSAP384SHA384 {
+			curve, size = elliptic.P384(), 48
+			h := sha512.Sum384(data)
+			digest = h[:]
+		} else {
+			h := sha256.Sum256(data)
+			digest = h[:]
+		}
+		if len(key.PublicKey) != 2*size || len(sig.Signature) != 2*size {
+			return errors.New("bad ecdsa key or signature length")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(key.PublicKey[:size]),
+			Y:     new(big.Int).SetBytes(key.PublicKey[size:]),
+		}
+		r := new(big.Int).SetBytes(sig.Signature[:size])
+		s := new(big.Int).SetBytes(sig.Signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("ecdsa signature does not verify")
+		}
+		return nil
+	case AlgED25519:
+		if len(key.PublicKey) != ed25519.PublicKeySize {
+			return errors.New("bad ed25519 key length")
+		}
+		if !ed25519.Verify(ed25519.PublicKey(key.PublicKey), data, sig.Signature) {
+			return errors.New("ed25519 signature does not verify")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %d", sig.Algorithm)
+}
+
+// parseRSAKey decodes an RSA public key in the RFC 3110 format.
+func parseRSAKey(data []byte) (*rsa.PublicKey, error) {
+	if len(data) < 3 {
+		return nil, errors.New("rsa key too short")
+	}
+	expLen := int(data[0])
+	data = data[1:]
+	if expLen == 0 {
+		expLen = int(binary.BigEndian.Uint16(data))
+		data = data[2:]
+	}
+	if expLen > 4 || len(data) <= expLen {
+		return nil, errors.New("unsupported rsa key")
+	}
+	var exp int
+	for _, b := range data[:expLen] {
+		exp = exp<<8 | int(b)
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(data[expLen:]), E: exp}, nil
+}
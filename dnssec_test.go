@@ -0,0 +1,207 @@
+package dnsclient
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSigner signs RRsets for a zone.
+type testSigner struct {
+	zone string
+	key  DNSKEYRecord
+	// signs the data an RRSIG covers
+	signer func(data []byte) []byte
+	// shifts the validity period of the signatures
+	offset time.Duration
+}
+
+// newTestSigner returns a signer with a fresh Ed25519 key.
+func newTestSigner(t *testing.T, zone string) *testSigner {
+	return newTestSignerAlg(t, zone, AlgED25519)
+}
+
+func newTestSignerAlg(t *testing.T, zone string, algorithm uint8) *testSigner {
+	t.Helper()
+	s := &testSigner{zone: zone, key: DNSKEYRecord{Flags: DNSKEYFlagZone, Protocol: 3, Algorithm: algorithm}}
+	switch algorithm {
+	case AlgED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.key.PublicKey = pub
+		s.signer = func(data []byte) []byte { return ed25519.Sign(priv, data) }
+	case AlgECDSAP256SHA256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.key.PublicKey = append(pad(priv.X, 32), pad(priv.Y, 32)...)
+		s.signer = func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			r, sig, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return append(pad(r, 32), pad(sig, 32)...)
+		}
+	case AlgRSASHA256:
+		priv, err := rsa.GenerateKey(rand.Reader, 1024)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := big.NewInt(int64(priv.E)).Bytes()
+		s.key.PublicKey = append(append([]byte{byte(len(e))}, e...), priv.N.Bytes()...)
+		s.signer = func(data []byte) []byte {
+			digest := sha256.Sum256(data)
+			sig, err := rsa.SignPKCS1v15(rand.Reader, priv, crypto.SHA256, digest[:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return sig
+		}
+	default:
+		t.Fatalf("can't sign with algorithm %d", algorithm)
+	}
+	return s
+}
+
+func pad(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func (s *testSigner) tagged() taggedKey {
+	return taggedKey{KeyTag(s.key), s.key}
+}
+
+// sign returns the RRSIG record over rrset, valid for an hour either side of
+// now.
+func (s *testSigner) sign(rrset []DnsResourceRecord) DnsResourceRecord {
+	return s.rrsigRecord(rrset[0].Name, s.rrsig(rrset))
+}
+
+func (s *testSigner) rrsig(rrset []DnsResourceRecord) RRSIGRecord {
+	now := time.Now().Add(s.offset)
+	sig := RRSIGRecord{
+		TypeCovered: rrset[0].Type,
+		Algorithm:   s.key.Algorithm,
+		Labels:      uint8(len(nameLabels(strings.TrimPrefix(rrset[0].Name, "*.")))),
+		OriginalTTL: uint32(rrset[0].TTL),
+		Expiration:  uint32(now.Add(time.Hour).Unix()),
+		Inception:   uint32(now.Add(-time.Hour).Unix()),
+		KeyTag:      KeyTag(s.key),
+		SignerName:  s.zone,
+	}
+	sig.Signature = s.signer(signedData(sig, rrset))
+	return sig
+}
+
+func (s *testSigner) rrsigRecord(owner string, sig RRSIGRecord) DnsResourceRecord {
+	var rdata bytes.Buffer
+	binary.Write(&rdata, binary.BigEndian, sig.TypeCovered)
+	rdata.WriteByte(sig.Algorithm)
+	rdata.WriteByte(sig.Labels)
+	binary.Write(&rdata, binary.BigEndian, sig.OriginalTTL)
+	binary.Write(&rdata, binary.BigEndian, sig.Expiration)
+	binary.Write(&rdata, binary.BigEndian, sig.Inception)
+	binary.Write(&rdata, binary.BigEndian, sig.KeyTag)
+	rdata.Write(SerializeName(sig.SignerName))
+	rdata.Write(sig.Signature)
+	return DnsResourceRecord{Name: owner, Type: RRSIG, Class: IN, TTL: int32(sig.OriginalTTL), RData: rdata.Bytes()}
+}
+
+// nsecRData builds NSEC rdata with the types in the first window.
+func nsecRData(next string, types ...uint16) []byte {
+	bitmap := make([]byte, 32)
+	size := 0
+	for _, t := range types {
+		bitmap[t/8] |= 0x80 >> (t % 8)
+		if int(t/8)+1 > size {
+			size = int(t/8) + 1
+		}
+	}
+	rdata := SerializeName(next)
+	rdata = append(rdata, 0, byte(size))
+	return append(rdata, bitmap[:size]...)
+}
+
+func TestVerifyRRSIG(t *testing.T) {
+	a := func(name string, ip ...byte) DnsResourceRecord {
+		return DnsResourceRecord{Name: name, Type: A, Class: IN, TTL: 300, RData: ip}
+	}
+	rrset := []DnsResourceRecord{a("www.example.com", 192, 0, 2, 1), a("www.example.com", 192, 0, 2, 2)}
+	for _, algorithm := range []uint8{AlgED25519, AlgECDSAP256SHA256, AlgRSASHA256} {
+		s := newTestSignerAlg(t, "example.com", algorithm)
+		other := newTestSignerAlg(t, "example.com", algorithm)
+		sig := s.rrsig(rrset)
+		expired, notYet := *s, *s
+		expired.offset, notYet.offset = -3*time.Hour, 3*time.Hour
+
+		wildcard := []DnsResourceRecord{a("*.example.com", 192, 0, 2, 3)}
+		wildcardSig := s.rrsig(wildcard)
+		expanded := []DnsResourceRecord{a("anything.example.com", 192, 0, 2, 3)}
+
+		tests := []struct {
+			name  string
+			sig   RRSIGRecord
+			key   DNSKEYRecord
+			rrset []DnsResourceRecord
+			err   string
+		}{
+			{"valid", sig, s.key, rrset, ""},
+			{"in another order", sig, s.key, []DnsResourceRecord{rrset[1], rrset[0]}, ""},
+			{"case of the owner", sig, s.key, []DnsResourceRecord{a("WWW.Example.COM", 192, 0, 2, 1), a("www.example.com", 192, 0, 2, 2)}, ""},
+			{"expanded wildcard", wildcardSig, s.key, expanded, ""},
+			{"record missing", sig, s.key, rrset[:1], "verif"},
+			{"record changed", sig, s.key, []DnsResourceRecord{rrset[0], a("www.example.com", 192, 0, 2, 3)}, "verif"},
+			{"other owner", sig, s.key, []DnsResourceRecord{a("ftp.example.com", 192, 0, 2, 1), a("ftp.example.com", 192, 0, 2, 2)}, "verif"},
+			{"other key", sig, other.key, rrset, "verif"},
+			{"expired", expired.rrsig(rrset), s.key, rrset, "expired"},
+			{"not valid yet", notYet.rrsig(rrset), s.key, rrset, "not valid yet"},
+			{"empty", sig, s.key, nil, "empty"},
+		}
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("alg %d %s", algorithm, test.name), func(t *testing.T) {
+				err := VerifyRRSIG(test.sig, test.key, test.rrset, time.Now())
+				if test.err == "" {
+					if err != nil {
+						t.Fatalf("VerifyRRSIG() = %v", err)
+					}
+					return
+				}
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("VerifyRRSIG() = %v, want error %q", err, test.err)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRRSIG(t *testing.T) {
+	s := newTestSigner(t, "example.com")
+	rrset := []DnsResourceRecord{{Name: "example.com", Type: A, Class: IN, TTL: 300, RData: []byte{192, 0, 2, 1}}}
+	want := s.rrsig(rrset)
+	got, err := ParseRRSIG(s.sign(rrset).RData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("ParseRRSIG() = %v, want %v", got, want)
+	}
+	if _, err := ParseRRSIG(make([]byte, 10)); err == nil {
+		t.Error("ParseRRSIG() of short rdata didn't fail")
+	}
+}
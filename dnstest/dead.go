@@ -0,0 +1,41 @@
+package dnstest
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// DeadServer starts a server on a random loopback port that takes UDP
+// queries and TCP connections and never answers, and returns its "ip:port".
+// It is stopped when the test ends.
+func DeadServer(t testing.TB) string {
+	t.Helper()
+	pc, l, err := listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var held []net.Conn
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			held = append(held, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		pc.Close()
+		l.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range held {
+			conn.Close()
+		}
+	})
+	return pc.LocalAddr().String()
+}
@@ -0,0 +1,101 @@
+package dnstest
+
+import (
+	"context"
+	"time"
+)
+
+// Step scripts what happens to one message sent through a Pipe.
+type Step struct {
+	// Delay holds the reply (or the error) back for a while
+	Delay time.Duration
+	// Drop loses the message: no reply comes and Exchange fails with a
+	// timeout after the pipe's Timeout
+	Drop bool
+	// Err is returned instead of a reply
+	Err error
+	// Response is sent instead of the programmed one
+	Response *Response
+}
+
+// DefaultPipeTimeout is how long a Pipe waits before reporting a dropped
+// message as timed out.
+const DefaultPipeTimeout = time.Second
+
+// Pipe answers queries in memory, without sockets. It has the Exchange
+// method of the client's Transport interface, so it can be plugged in with
+// WithServerTransport. Each message takes the next scripted Step, and once
+// the script runs out the programmed responses are sent back like Server
+// does.
+type Pipe struct {
+	handler
+	// Timeout is how long a dropped message takes to time out
+	Timeout time.Duration
+
+	script   []Step
+	messages [][]byte
+}
+
+// NewPipe returns a pipe with no responses programmed and an empty script.
+func NewPipe() *Pipe {
+	return &Pipe{handler: handler{responses: make(map[key]Response)}, Timeout: DefaultPipeTimeout}
+}
+
+// Script queues steps for the next messages, after any still queued.
+func (p *Pipe) Script(steps ...Step) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.script = append(p.script, steps...)
+}
+
+// Messages returns copies of all the messages sent through the pipe so far,
+// dropped ones included.
+func (p *Pipe) Messages() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	messages := make([][]byte, len(p.messages))
+	for i, msg := range p.messages {
+		messages[i] = append([]byte{}, msg...)
+	}
+	return messages
+}
+
+// Exchange sends msg through the pipe and returns the reply.
+func (p *Pipe) Exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	p.mu.Lock()
+	p.messages = append(p.messages, append([]byte{}, msg...))
+	var step Step
+	if len(p.script) > 0 {
+		step = p.script[0]
+		p.script = p.script[1:]
+	}
+	p.mu.Unlock()
+
+	wait := step.Delay
+	if step.Drop {
+		wait += p.Timeout
+	}
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	switch {
+	case step.Drop:
+		return nil, timeoutError{}
+	case step.Err != nil:
+		return nil, step.Err
+	}
+	return p.reply(msg, step.Response, false)
+}
+
+// timeoutError looks like a net.Error timeout to the client.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "dnstest: message dropped, timed out" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -0,0 +1,292 @@
+// Package dnstest provides an in-process DNS server with canned responses for
+// hermetic tests of resolution logic.
+package dnstest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Record is a resource record to put in the answer section. RData is in wire
+// format, names inside it must not be compressed.
+type Record struct {
+	Name  string
+	Type  uint16
+	Class uint16
+	TTL   uint32
+	RData []byte
+}
+
+// Response is what the server sends back for a name and type.
+type Response struct {
+	RCode   uint16
+	Answers []Record
+	// Truncated sets the TC flag and leaves out the answers over UDP, asking
+	// the client to retry over TCP, where the full response is sent
+	Truncated bool
+}
+
+type key struct {
+	name  string
+	qtype uint16
+}
+
+// Server answers UDP and TCP queries on the same loopback port. Questions
+// without a programmed response get NODATA if the name has responses for
+// other types and NXDOMAIN otherwise.
+type Server struct {
+	pc net.PacketConn
+	l  net.Listener
+	handler
+}
+
+// handler holds the programmed responses, shared by Server and Pipe.
+type handler struct {
+	mu        sync.Mutex
+	responses map[key]Response
+	queries   int
+	noEDNS    bool
+}
+
+// NewServer starts a server on a random loopback port.
+func NewServer() (*Server, error) {
+	pc, l, err := listen()
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{pc: pc, l: l, handler: handler{responses: make(map[key]Response)}}
+	go s.serveUDP()
+	go s.serveTCP()
+	return s, nil
+}
+
+// listen opens a UDP socket and a TCP listener on the same random loopback
+// port. The port is picked for UDP, so another one is tried if it's taken
+// for TCP.
+func listen() (net.PacketConn, net.Listener, error) {
+	var err error
+	for i := 0; i < 10; i++ {
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, err
+		}
+		var l net.Listener
+		l, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			return pc, l, nil
+		}
+		pc.Close()
+	}
+	return nil, nil, err
+}
+
+// Addr returns the "ip:port" the server listens on.
+func (s *Server) Addr() string {
+	return s.pc.LocalAddr().String()
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	s.l.Close()
+	return s.pc.Close()
+}
+
+// Handle programs the response for queries of name and qtype. Names are
+// matched case insensitively, with or without a trailing dot.
+func (s *handler) Handle(name string, qtype uint16, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[key{normalize(name), qtype}] = response
+}
+
+// RejectEDNS makes the server answer queries with an OPT record with a
+// FORMERR, as servers that don't know EDNS do (RFC 6891 section 7).
+func (s *handler) RejectEDNS() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.noEDNS = true
+}
+
+// HandleA is a shortcut for an answer with A or AAAA records for ips.
+func (s *handler) HandleA(name string, ips ...net.IP) {
+	var v4, v6 []Record
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			v4 = append(v4, Record{Name: name, Type: 1, Class: 1, TTL: 300, RData: ip4})
+		} else {
+			v6 = append(v6, Record{Name: name, Type: 28, Class: 1, TTL: 300, RData: ip.To16()})
+		}
+	}
+	s.Handle(name, 1, Response{Answers: v4})
+	s.Handle(name, 28, Response{Answers: v6})
+}
+
+// Queries returns how many queries the server has answered.
+func (s *handler) Queries() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := s.pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		reply, err := s.reply(buf[:n], nil, false)
+		if err != nil {
+			continue
+		}
+		s.pc.WriteTo(reply, addr)
+	}
+}
+
+func (s *Server) serveTCP() {
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				var length uint16
+				if binary.Read(conn, binary.BigEndian, &length) != nil {
+					return
+				}
+				msg := make([]byte, length)
+				if _, err := io.ReadFull(conn, msg); err != nil {
+					return
+				}
+				reply, err := s.reply(msg, nil, true)
+				if err != nil {
+					return
+				}
+				binary.Write(conn, binary.BigEndian, uint16(len(reply)))
+				conn.Write(reply)
+			}
+		}()
+	}
+}
+
+// reply builds the response to the query msg, the programmed one unless
+// override is given. Over a stream the response is never truncated.
+func (s *handler) reply(msg []byte, override *Response, stream bool) ([]byte, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg[4:]) != 1 {
+		return nil, errors.New("dnstest: only queries with one question are supported")
+	}
+	name, end, err := readName(msg, 12)
+	if err != nil {
+		return nil, err
+	}
+	if end+4 > len(msg) {
+		return nil, errors.New("dnstest: short question")
+	}
+	qtype := binary.BigEndian.Uint16(msg[end:])
+
+	s.mu.Lock()
+	response, ok := s.responses[key{normalize(name), qtype}]
+	if override != nil {
+		response, ok = *override, true
+	}
+	if s.noEDNS && hasOPT(msg, end+4) {
+		response, ok = Response{RCode: 1}, true
+	}
+	if !ok {
+		// NODATA if the name exists with some other type, NXDOMAIN otherwise
+		response = Response{RCode: 3}
+		for k := range s.responses {
+			if k.name == normalize(name) {
+				response = Response{}
+				break
+			}
+		}
+	}
+	s.queries++
+	s.mu.Unlock()
+
+	var buf bytes.Buffer
+	flags := binary.BigEndian.Uint16(msg[2:])&0x0110 | 0x8080 | response.RCode&0xf
+	if response.Truncated && !stream {
+		flags |= 0x0200
+		response.Answers = nil
+	}
+	binary.Write(&buf, binary.BigEndian, []uint16{binary.BigEndian.Uint16(msg), flags, 1, uint16(len(response.Answers)), 0, 0})
+	buf.Write(msg[12 : end+4])
+	for _, r := range response.Answers {
+		buf.Write(encodeName(r.Name))
+		class := r.Class
+		if class == 0 {
+			class = 1
+		}
+		binary.Write(&buf, binary.BigEndian, r.Type)
+		binary.Write(&buf, binary.BigEndian, class)
+		binary.Write(&buf, binary.BigEndian, r.TTL)
+		binary.Write(&buf, binary.BigEndian, uint16(len(r.RData)))
+		buf.Write(r.RData)
+	}
+	return buf.Bytes(), nil
+}
+
+// readName reads an uncompressed name starting at off and returns it with the
+// offset just past it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	for {
+		if off >= len(msg) {
+			return "", 0, errors.New("dnstest: name overflows message")
+		}
+		n := int(msg[off])
+		off++
+		if n == 0 {
+			return strings.Join(labels, "."), off, nil
+		}
+		if n&0xc0 != 0 || off+n > len(msg) {
+			return "", 0, errors.New("dnstest: bad label")
+		}
+		labels = append(labels, string(msg[off:off+n]))
+		off += n
+	}
+}
+
+// hasOPT reports whether the additional section of msg, which starts at
+// off, has an OPT record.
+func hasOPT(msg []byte, off int) bool {
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[10:])); i++ {
+		_, end, err := readName(msg, off)
+		if err != nil || end+10 > len(msg) {
+			return false
+		}
+		if binary.BigEndian.Uint16(msg[end:]) == 41 {
+			return true
+		}
+		off = end + 10 + int(binary.BigEndian.Uint16(msg[end+8:]))
+	}
+	return false
+}
+
+// encodeName returns name in uncompressed wire format.
+func encodeName(name string) []byte {
+	var buf bytes.Buffer
+	name = strings.TrimSuffix(name, ".")
+	if name != "" {
+		for _, label := range strings.Split(name, ".") {
+			buf.WriteByte(byte(len(label)))
+			buf.WriteString(label)
+		}
+	}
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
@@ -0,0 +1,196 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DoHContentType is the media type of DNS messages over HTTPS (RFC 8484).
+const DoHContentType = "application/dns-message"
+
+// HTTP versions for DoH, see Client.HTTPVersion.
+const (
+	HTTPPreferH2 = ""
+	HTTPOnlyH2   = "h2"
+	HTTPOnly1    = "http/1.1"
+)
+
+// ErrHTTP3 is returned for HTTP version "h3": HTTP/3 needs QUIC, which the
+// standard library doesn't have.
+var ErrHTTP3 = errors.New("HTTP/3 is not supported, there is no QUIC support")
+
+// httpClient returns the HTTP client used for DoH, created on first use so
+// connections are shared between queries.
+func (c *Client) httpClient(host string, authenticate bool) (*http.Client, error) {
+	tlsConfig := c.tlsConfig(host, authenticate)
+	key := host
+	if !authenticate {
+		key += " unauthenticated"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if hc := c.httpClients[key]; hc != nil {
+		return hc, nil
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           c.dial,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   c.Timeouts.Handshake,
+		ResponseHeaderTimeout: c.Timeouts.Read,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	switch c.HTTPVersion {
+	case HTTPPreferH2, HTTPOnlyH2:
+		transport.ForceAttemptHTTP2 = true
+	case HTTPOnly1:
+		transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "h3":
+		return nil, ErrHTTP3
+	default:
+		return nil, fmt.Errorf("unknown http version %q", c.HTTPVersion)
+	}
+
+	if c.httpClients == nil {
+		c.httpClients = make(map[string]*http.Client)
+	}
+	hc := &http.Client{Transport: transport}
+	c.httpClients[key] = hc
+	return hc, nil
+}
+
+// sendDoH sends request to the DoH endpoint at server (RFC 8484 section 4.1)
+// with POST, or GET if the client is set up for it.
+func (c *Client) sendDoH(ctx context.Context, server string, request DnsRequest, authenticate bool) (DnsResponse, error) {
+	var response DnsResponse
+	msg := SerializeRequest(request)
+	var req *http.Request
+	var err error
+	if c.DoHGet {
+		var endpoint string
+		endpoint, err = ExpandDoHTemplate(server, base64.RawURLEncoding.EncodeToString(msg))
+		if err == nil {
+			req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		}
+	} else {
+		var endpoint string
+		endpoint, err = ExpandDoHTemplate(server, "")
+		if err == nil {
+			req, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(msg))
+		}
+	}
+	if err != nil {
+		return response, err
+	}
+	if req.Method == http.MethodPost {
+		req.Header.Set("Content-Type", DoHContentType)
+	}
+	req.Header.Set("Accept", DoHContentType)
+	hc, err := c.httpClient(req.URL.Hostname(), authenticate)
+	if err != nil {
+		return response, err
+	}
+
+	start := time.Now()
+	resp, err := hc.Do(req)
+	if err != nil {
+		return response, contextError(ctx, timeoutError(err, "read", server, c.Timeouts.Read))
+	}
+	defer resp.Body.Close()
+	if c.HTTPVersion == HTTPOnlyH2 && resp.ProtoMajor != 2 {
+		return response, fmt.Errorf("%s answered over %s, not HTTP/2", server, resp.Proto)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return response, fmt.Errorf("%s: http status %s", server, resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, DoHContentType) {
+		return response, fmt.Errorf("%s: unexpected content type %q", server, ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return response, contextError(ctx, timeoutError(err, "read", server, c.Timeouts.Read))
+	}
+
+	response, err = ReadResponse(body)
+	if err != nil {
+		return response, err
+	}
+	if !MatchesRequest(response, request) {
+		return response, errors.New("response does not match the request")
+	}
+	response.Server = server
+	response.RTT = time.Since(start)
+	response.Protocol = resp.Proto
+	response.Transport = "https"
+	response.Authenticated = authenticate
+	return response, nil
+}
+
+// ExpandDoHTemplate expands a DoH URI template (RFC 6570, as used by RFC 8484
+// section 3) with dns as the value of the dns variable. Templates without
+// the variable get a dns query parameter added if dns is not empty, and an
+// empty dns leaves the variable undefined, which is what POST wants.
+// Variables other than dns are always undefined.
+func ExpandDoHTemplate(template string, dns string) (string, error) {
+	var buf strings.Builder
+	found := false
+	for {
+		open := strings.IndexByte(template, '{')
+		if open < 0 {
+			buf.WriteString(template)
+			break
+		}
+		end := strings.IndexByte(template[open:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated expression in uri template %q", template)
+		}
+		buf.WriteString(template[:open])
+		expr := template[open+1 : open+end]
+		template = template[open+end+1:]
+
+		op := ""
+		if expr != "" && strings.ContainsRune("+#./;?&", rune(expr[0])) {
+			op, expr = expr[:1], expr[1:]
+		}
+		var vars []string
+		for _, name := range strings.Split(expr, ",") {
+			if name == "dns" {
+				found = true
+				if dns != "" {
+					vars = append(vars, name)
+				}
+			}
+		}
+		if len(vars) == 0 {
+			continue
+		}
+		switch op {
+		case "?", "&":
+			buf.WriteString(op + "dns=" + dns)
+		case "":
+			buf.WriteString(dns)
+		default:
+			return "", fmt.Errorf("unsupported uri template operator %q", op)
+		}
+	}
+
+	u := buf.String()
+	if !found && dns != "" {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += sep + "dns=" + dns
+	}
+	return u, nil
+}
@@ -0,0 +1,94 @@
+package dnsclient
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DoHPath is where the DoH frontend answers queries.
+const DoHPath = "/dns-query"
+
+// ServeHTTP answers DoH queries (RFC 8484 section 4.1), sent with GET in the
+// dns parameter or with POST in the body, through the forwarder.
+func (f *Forwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var msg []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		msg, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil || len(msg) == 0 {
+			http.Error(w, "missing or invalid dns parameter", http.StatusBadRequest)
+			return
+		}
+	case http.MethodPost:
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, DoHContentType) {
+			http.Error(w, "content type must be "+DoHContentType, http.StatusUnsupportedMediaType)
+			return
+		}
+		msg, err = io.ReadAll(io.LimitReader(r.Body, 65536))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if f.ClientLimit != nil {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if !f.ClientLimit.Allow(host) {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+	}
+	reply, err := f.Forward(msg)
+	if err != nil {
+		log.Printf("%s: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", DoHContentType)
+	if ttl, ok := replyTTL(reply); ok {
+		// Section 5.1: HTTP caches should keep the reply no longer than its records
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", ttl))
+	}
+	w.Write(reply)
+}
+
+// replyTTL returns the smallest TTL of the records in reply, for successful
+// replies with any.
+func replyTTL(reply []byte) (int32, bool) {
+	response, err := ReadResponse(reply)
+	if err != nil || response.Header.Flags.RCode() != 0 {
+		return 0, false
+	}
+	var ttl int32 = -1
+	for _, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, response.Additionals} {
+		for _, r := range section {
+			if r.Type != OPT && (ttl < 0 || r.TTL < ttl) {
+				ttl = r.TTL
+			}
+		}
+	}
+	return ttl, ttl >= 0
+}
+
+// ServeDoH answers DoH queries on DoHPath over HTTPS on l, with the
+// certificate and key in certFile and keyFile.
+func (f *Forwarder) ServeDoH(l net.Listener, certFile, keyFile string) error {
+	mux := http.NewServeMux()
+	mux.Handle(DoHPath, f)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	return server.ServeTLS(l, certFile, keyFile)
+}
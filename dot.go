@@ -0,0 +1,109 @@
+package dnsclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// DNS over TLS (RFC 7858) servers are given as "tls:host" or "tls:host:port".
+const DefaultDoTPort = "853"
+
+// DoTAddr returns the host:port to connect to for a "tls:" server and the
+// name to check its certificate against.
+func DoTAddr(server string) (addr string, serverName string) {
+	server = strings.TrimPrefix(server, "tls:")
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = strings.Trim(server, "[]"), DefaultDoTPort
+	}
+	return net.JoinHostPort(host, port), host
+}
+
+// SPKIPin returns the pin of a certificate as used in RFC 7858 section 4.2:
+// the base64 SHA-256 digest of its SubjectPublicKeyInfo.
+func SPKIPin(cert *x509.Certificate) string {
+	h := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// verifySPKIPins accepts a connection if any certificate the server presents
+// matches one of pins. The CA store is not consulted, the pins replace it.
+func verifySPKIPins(pins []string) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, cert := range cs.PeerCertificates {
+			pin := SPKIPin(cert)
+			for _, p := range pins {
+				if p == pin {
+					return nil
+				}
+			}
+		}
+		return errors.New("no certificate presented by the server matches the SPKI pins")
+	}
+}
+
+// tlsConfig returns the TLS settings for connecting to serverName, starting
+// from the client's TLSConfig if it has one. All connections share the
+// client's session cache, so reconnecting to a server resumes the earlier
+// session instead of doing a full handshake. Without authenticate any
+// certificate is accepted, for opportunistic privacy.
+func (c *Client) tlsConfig(serverName string, authenticate bool) *tls.Config {
+	c.mu.Lock()
+	if c.TLSSessionCache == nil {
+		c.TLSSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+	cache := c.TLSSessionCache
+	c.mu.Unlock()
+
+	config := &tls.Config{}
+	if c.TLSConfig != nil {
+		config = c.TLSConfig.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = serverName
+	}
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = cache
+	}
+	switch {
+	case !authenticate:
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = nil
+	case len(c.SPKIPins) > 0:
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = verifySPKIPins(c.SPKIPins)
+	}
+	return config
+}
+
+// LoadTLSConfig builds a TLS configuration for DoT and DoH servers from PEM
+// files: a CA bundle to trust instead of the system CAs and a client
+// certificate and key. Empty arguments are left at their defaults.
+func LoadTLSConfig(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
@@ -0,0 +1,41 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// DualStackLookup sends separate A and AAAA queries for name concurrently and
+// merges the addresses, IPv6 first, sending them to server with a client made
+// with opts. It only fails if both queries fail.
+func DualStackLookup(ctx context.Context, server string, name string, opts ...Option) ([]net.IP, error) {
+	client := NewClient(withServers(opts, server)...)
+	qtypes := []uint16{AAAA, A}
+	results := make([][]net.IP, len(qtypes))
+	errs := make([]error, len(qtypes))
+
+	var wg sync.WaitGroup
+	for i, qtype := range qtypes {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			response, err := client.Query(ctx, name, qtype)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			for _, a := range response.Answers {
+				if a.Type == qtype {
+					results[i] = append(results[i], net.IP(a.RData))
+				}
+			}
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	if errs[0] != nil && errs[1] != nil {
+		return nil, errs[0]
+	}
+	return append(results[0], results[1]...), nil
+}
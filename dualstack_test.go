@@ -0,0 +1,28 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+func TestDualStackLookup(t *testing.T) {
+	server, err := dnstest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.HandleA("www.example.com", net.IPv4(192, 0, 2, 1), net.ParseIP("2001:db8::1"))
+	ips, err := DualStackLookup(context.Background(), server.Addr(), "www.example.com", WithTimeout(time.Second))
+	if err != nil || len(ips) != 2 || !ips[0].Equal(net.ParseIP("2001:db8::1")) || !ips[1].Equal(net.IPv4(192, 0, 2, 1)) {
+		t.Errorf("DualStackLookup() = %v, %v, want [2001:db8::1 192.0.2.1]", ips, err)
+	}
+
+	givesUp(t, func(ctx context.Context, server string, opts ...Option) error {
+		_, err := DualStackLookup(ctx, server, "www.example.com", opts...)
+		return err
+	})
+}
@@ -0,0 +1,171 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// DefaultUDPSize is the EDNS payload size recommended by DNS Flag Day 2020,
+// small enough to avoid IP fragmentation on virtually every path.
+const DefaultUDPSize = 1232
+
+const OPT = 41
+
+// NewOPT returns an OPT pseudo-record (RFC 6891) advertising udpSize. The
+// requestor's UDP payload size goes in the class field.
+func NewOPT(udpSize uint16) DnsResourceRecord {
+	return DnsResourceRecord{
+		Name:  "",
+		Type:  OPT,
+		Class: udpSize,
+		RData: []byte{},
+	}
+}
+
+// SetEDNS adds an OPT record advertising udpSize to the request, or updates
+// the size of the existing one, or removes it if udpSize is 0. Like the other
+// setters it returns the request so calls can be chained.
+func (r *DnsRequest) SetEDNS(udpSize uint16) *DnsRequest {
+	var additionals []DnsResourceRecord
+	opt := NewOPT(udpSize)
+	for _, a := range r.Additionals {
+		if a.Type == OPT {
+			opt.RData = a.RData
+			continue
+		}
+		additionals = append(additionals, a)
+	}
+	if udpSize != 0 {
+		additionals = append(additionals, opt)
+	}
+	r.Additionals = additionals
+	r.Header.ArCount = uint16(len(additionals))
+	return r
+}
+
+// UDPSize is the largest UDP response the request allows: the size from its
+// OPT record, or 512 without EDNS (RFC 1035 2.3.4).
+func (r DnsRequest) UDPSize() uint16 {
+	for _, a := range r.Additionals {
+		if a.Type == OPT && a.Class > 512 {
+			return a.Class
+		}
+	}
+	return 512
+}
+
+// EDNSOption is one option from the RData of an OPT record.
+type EDNSOption struct {
+	Code uint16
+	Data []byte
+}
+
+func ParseEDNSOptions(rdata []byte) ([]EDNSOption, error) {
+	var opts []EDNSOption
+	for len(rdata) > 0 {
+		if len(rdata) < 4 {
+			return opts, errors.New("truncated edns option")
+		}
+		code := binary.BigEndian.Uint16(rdata)
+		length := int(binary.BigEndian.Uint16(rdata[2:]))
+		if len(rdata) < 4+length {
+			return opts, errors.New("truncated edns option")
+		}
+		opts = append(opts, EDNSOption{Code: code, Data: rdata[4 : 4+length]})
+		rdata = rdata[4+length:]
+	}
+	return opts, nil
+}
+
+func SerializeEDNSOptions(opts []EDNSOption) []byte {
+	rdata := []byte{}
+	for _, opt := range opts {
+		rdata = append(rdata, byte(opt.Code>>8), byte(opt.Code), byte(len(opt.Data)>>8), byte(len(opt.Data)))
+		rdata = append(rdata, opt.Data...)
+	}
+	return rdata
+}
+
+// AddEDNSOption appends opt to the request's OPT record, adding one if the
+// request does not use EDNS yet.
+func (r *DnsRequest) AddEDNSOption(opt EDNSOption) *DnsRequest {
+	if !r.HasEDNS() {
+		r.SetEDNS(512)
+	}
+	for i, a := range r.Additionals {
+		if a.Type == OPT {
+			rdata := append([]byte{}, a.RData...)
+			r.Additionals[i].RData = append(rdata, SerializeEDNSOptions([]EDNSOption{opt})...)
+		}
+	}
+	return r
+}
+
+// withEDNSOptions returns a copy of request with the options it doesn't have
+// yet added.
+func withEDNSOptions(request DnsRequest, opts []EDNSOption) *DnsRequest {
+	have := map[uint16]bool{}
+	for _, a := range request.Additionals {
+		if a.Type == OPT {
+			existing, _ := ParseEDNSOptions(a.RData)
+			for _, opt := range existing {
+				have[opt.Code] = true
+			}
+		}
+	}
+	request.Additionals = append([]DnsResourceRecord{}, request.Additionals...)
+	for _, opt := range opts {
+		if !have[opt.Code] {
+			request.AddEDNSOption(opt)
+		}
+	}
+	return &request
+}
+
+// EDNS flag asking for DNSSEC records in the response (RFC 3225), the top
+// bit of the flags half of the OPT TTL
+const EDNSFlagDO = 0x8000
+
+// SetDO sets or clears the DNSSEC OK bit, adding EDNS if needed.
+func (r *DnsRequest) SetDO(do bool) *DnsRequest {
+	if !r.HasEDNS() {
+		r.SetEDNS(DefaultUDPSize)
+	}
+	for i, a := range r.Additionals {
+		if a.Type != OPT {
+			continue
+		}
+		if do {
+			r.Additionals[i].TTL |= EDNSFlagDO
+		} else {
+			r.Additionals[i].TTL &^= EDNSFlagDO
+		}
+	}
+	return r
+}
+
+func (r DnsRequest) HasEDNS() bool {
+	for _, a := range r.Additionals {
+		if a.Type == OPT {
+			return true
+		}
+	}
+	return false
+}
+
+// EDNSOption returns the data of the first option with the given code in the
+// response's OPT record.
+func (r DnsResponse) EDNSOption(code uint16) ([]byte, bool) {
+	for _, a := range r.Additionals {
+		if a.Type != OPT {
+			continue
+		}
+		opts, _ := ParseEDNSOptions(a.RData)
+		for _, opt := range opts {
+			if opt.Code == code {
+				return opt.Data, true
+			}
+		}
+	}
+	return nil, false
+}
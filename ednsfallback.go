@@ -0,0 +1,69 @@
+package dnsclient
+
+import (
+	"errors"
+	"net"
+)
+
+// Servers that don't know EDNS answer queries with an OPT record with a
+// FORMERR without one (RFC 6891 section 7), and some firewalls in front of
+// servers drop them. Either way the client learns to send the server its
+// queries without EDNS from then on.
+
+// formErrNoEDNS reports whether response is the FORMERR of a server that
+// doesn't do EDNS.
+func formErrNoEDNS(request DnsRequest, response DnsResponse) bool {
+	if !request.HasEDNS() || response.Header.Flags.RCode() != 1 {
+		return false
+	}
+	_, ok := response.EDNS()
+	return !ok
+}
+
+// dropsEDNS reports whether err, from a query to server, may mean the query
+// was dropped for its OPT record: it had one, the reply never came, and the
+// server hasn't shown yet that EDNS isn't the problem.
+func (c *Client) dropsEDNS(server string, request DnsRequest, err error) bool {
+	if err == nil || !request.HasEDNS() {
+		return false
+	}
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) && timeout.Phase != "read" {
+		// Not reachable at all
+		return false
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.ednsChecked[server]
+}
+
+// answeredEDNS notes that server answered a query with EDNS, so its
+// timeouts are just timeouts.
+func (c *Client) answeredEDNS(server string, request DnsRequest, response DnsResponse) {
+	if _, ok := response.EDNS(); ok && request.HasEDNS() {
+		c.checkedEDNS(server)
+	}
+}
+
+// checkedEDNS notes that EDNS is not why queries to server go unanswered:
+// it answered one with EDNS, or the ones without timed out too. Otherwise
+// a server that is down would wait out every timeout twice.
+func (c *Client) checkedEDNS(server string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ednsChecked == nil {
+		c.ednsChecked = make(map[string]bool)
+	}
+	c.ednsChecked[server] = true
+}
+
+// withoutEDNS returns a copy of request without its OPT record.
+func withoutEDNS(request DnsRequest) DnsRequest {
+	request.Additionals = append([]DnsResourceRecord{}, request.Additionals...)
+	request.SetEDNS(0)
+	return request
+}
@@ -0,0 +1,200 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EDNS option codes with built in handling
+const (
+	EDNSNSID    = 3
+	EDNSCookie  = 10
+	EDNSPadding = 12
+)
+
+// EDNSOptionType describes how to handle the data of one EDNS option code,
+// like RRType does for records. Nil functions fall back to treating the
+// data as opaque bytes.
+type EDNSOptionType struct {
+	Name string
+	// Encode turns a value into option data
+	Encode func(v interface{}) ([]byte, error)
+	// Decode turns option data into a value
+	Decode func(data []byte) (interface{}, error)
+	// Format returns the option data in presentation format
+	Format func(data []byte) string
+}
+
+var ednsOptionTypes = map[uint16]EDNSOptionType{}
+
+// RegisterEDNSOption sets the handling for EDNS option code, replacing any
+// previous registration. Library users can register new and private use
+// (65001-65534) options this way.
+func RegisterEDNSOption(code uint16, t EDNSOptionType) {
+	ednsOptionTypes[code] = t
+}
+
+// EDNSOptionName returns the registered name of code, or OPTnnn.
+func EDNSOptionName(code uint16) string {
+	if t, ok := ednsOptionTypes[code]; ok && t.Name != "" {
+		return t.Name
+	}
+	return fmt.Sprintf("OPT%d", code)
+}
+
+// EncodeEDNSOption builds the option code from v with the registered
+// encoder. Without one v must be the data itself, a []byte.
+func EncodeEDNSOption(code uint16, v interface{}) (EDNSOption, error) {
+	if t, ok := ednsOptionTypes[code]; ok && t.Encode != nil {
+		data, err := t.Encode(v)
+		if err != nil {
+			return EDNSOption{}, fmt.Errorf("%s: %v", EDNSOptionName(code), err)
+		}
+		return EDNSOption{Code: code, Data: data}, nil
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return EDNSOption{}, fmt.Errorf("%s has no encoder, its data must be given as []byte", EDNSOptionName(code))
+	}
+	return EDNSOption{Code: code, Data: data}, nil
+}
+
+// Decode returns the value of the option from the registered decoder, or
+// the data itself if there is none.
+func (opt EDNSOption) Decode() (interface{}, error) {
+	if t, ok := ednsOptionTypes[opt.Code]; ok && t.Decode != nil {
+		return t.Decode(opt.Data)
+	}
+	return opt.Data, nil
+}
+
+func (opt EDNSOption) String() string {
+	if t, ok := ednsOptionTypes[opt.Code]; ok && t.Format != nil {
+		return EDNSOptionName(opt.Code) + ": " + t.Format(opt.Data)
+	}
+	return fmt.Sprintf("%s: %x", EDNSOptionName(opt.Code), opt.Data)
+}
+
+// SetEDNSOptionValue encodes v as option code and adds it to the request.
+func (r *DnsRequest) SetEDNSOptionValue(code uint16, v interface{}) error {
+	opt, err := EncodeEDNSOption(code, v)
+	if err != nil {
+		return err
+	}
+	r.AddEDNSOption(opt)
+	return nil
+}
+
+// EDNSOptionValue decodes the first option code in the response's OPT
+// record. ok is false if there is none.
+func (r DnsResponse) EDNSOptionValue(code uint16) (v interface{}, ok bool, err error) {
+	data, ok := r.EDNSOption(code)
+	if !ok {
+		return nil, false, nil
+	}
+	v, err = EDNSOption{Code: code, Data: data}.Decode()
+	return v, true, err
+}
+
+// EDNSOptions returns all the options in the response's OPT record.
+func (r DnsResponse) EDNSOptions() ([]EDNSOption, error) {
+	for _, a := range r.Additionals {
+		if a.Type == OPT {
+			return ParseEDNSOptions(a.RData)
+		}
+	}
+	return nil, nil
+}
+
+func init() {
+	// NSID (RFC 5001): empty in queries, the server's identifier in responses
+	RegisterEDNSOption(EDNSNSID, EDNSOptionType{
+		Name: "NSID",
+		Encode: func(v interface{}) ([]byte, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("value must be a string")
+			}
+			return []byte(s), nil
+		},
+		Decode: func(data []byte) (interface{}, error) { return string(data), nil },
+		Format: func(data []byte) string {
+			return fmt.Sprintf("%x (%q)", data, data)
+		},
+	})
+	// Cookie (RFC 7873): an 8 byte client cookie and 8 to 32 bytes of server cookie
+	RegisterEDNSOption(EDNSCookie, EDNSOptionType{
+		Name: "COOKIE",
+		Encode: func(v interface{}) ([]byte, error) {
+			data, ok := v.([]byte)
+			if !ok {
+				return nil, errors.New("value must be a []byte")
+			}
+			if n := len(data); n != 8 && (n < 16 || n > 40) {
+				return nil, fmt.Errorf("invalid cookie length %d", n)
+			}
+			return data, nil
+		},
+		Format: func(data []byte) string {
+			if len(data) <= 8 {
+				return hex.EncodeToString(data)
+			}
+			return hex.EncodeToString(data[:8]) + " " + hex.EncodeToString(data[8:])
+		},
+	})
+	// TCP keepalive (RFC 7828): idle timeout in units of 100 milliseconds
+	RegisterEDNSOption(EDNSTCPKeepalive, EDNSOptionType{
+		Name: "TCP-KEEPALIVE",
+		Encode: func(v interface{}) ([]byte, error) {
+			d, ok := v.(time.Duration)
+			if !ok {
+				return nil, errors.New("value must be a time.Duration")
+			}
+			if d < 0 {
+				return []byte{}, nil
+			}
+			units := d / (100 * time.Millisecond)
+			if units > 0xffff {
+				units = 0xffff
+			}
+			return []byte{byte(units >> 8), byte(units)}, nil
+		},
+		Decode: func(data []byte) (interface{}, error) {
+			switch len(data) {
+			case 0:
+				return time.Duration(0), nil
+			case 2:
+				return time.Duration(binary.BigEndian.Uint16(data)) * 100 * time.Millisecond, nil
+			}
+			return nil, fmt.Errorf("invalid tcp keepalive length %d", len(data))
+		},
+		Format: func(data []byte) string {
+			if len(data) != 2 {
+				return hex.EncodeToString(data)
+			}
+			return (time.Duration(binary.BigEndian.Uint16(data)) * 100 * time.Millisecond).String()
+		},
+	})
+	// Padding (RFC 7830): zeros, the value is how many
+	RegisterEDNSOption(EDNSPadding, EDNSOptionType{
+		Name: "PADDING",
+		Encode: func(v interface{}) ([]byte, error) {
+			n, ok := v.(int)
+			if !ok || n < 0 || n > 0xffff {
+				return nil, errors.New("value must be an int length")
+			}
+			return make([]byte, n), nil
+		},
+		Decode: func(data []byte) (interface{}, error) { return len(data), nil },
+		Format: func(data []byte) string {
+			if strings.Trim(string(data), "\x00") != "" {
+				return fmt.Sprintf("%d bytes, not all zero", len(data))
+			}
+			return fmt.Sprintf("%d bytes", len(data))
+		},
+	})
+}
@@ -0,0 +1,161 @@
+package dnsclient
+
+// EDNSVersion is the highest EDNS version the client and the forwarder
+// speak. Version 0 (RFC 6891) is the only one defined so far.
+const EDNSVersion = 0
+
+// BADVERS is the extended RCODE for an EDNS version the server doesn't
+// implement, with the highest one it does in its OPT record.
+const BADVERS = 16
+
+// The OPT TTL holds the upper 8 bits of the extended RCODE, the version and
+// the flags (RFC 6891 section 6.1.3).
+func optVersion(ttl int32) uint8 { return uint8(uint32(ttl) >> 16) }
+
+func optRCode(ttl int32) uint16 { return uint16(uint32(ttl)>>24) << 4 }
+
+// SetEDNSVersion sets the EDNS version of the request, adding EDNS if
+// needed.
+func (r *DnsRequest) SetEDNSVersion(version uint8) *DnsRequest {
+	if !r.HasEDNS() {
+		r.SetEDNS(DefaultUDPSize)
+	}
+	for i, a := range r.Additionals {
+		if a.Type == OPT {
+			r.Additionals[i].TTL = int32(uint32(a.TTL)&^0x00ff0000 | uint32(version)<<16)
+		}
+	}
+	return r
+}
+
+// EDNSVersion returns the EDNS version of the request, 0 without EDNS.
+func (r DnsRequest) EDNSVersion() uint8 {
+	for _, a := range r.Additionals {
+		if a.Type == OPT {
+			return optVersion(a.TTL)
+		}
+	}
+	return 0
+}
+
+// EDNSInfo is what the OPT record of a response says about the server.
+type EDNSInfo struct {
+	Version uint8
+	// The largest UDP response the server accepts
+	UDPSize uint16
+	DO      bool
+	Options []EDNSOption
+}
+
+// EDNS returns what the response's OPT record advertises. ok is false if
+// the server didn't answer with EDNS.
+func (r DnsResponse) EDNS() (info EDNSInfo, ok bool) {
+	for _, a := range r.Additionals {
+		if a.Type != OPT {
+			continue
+		}
+		opts, _ := ParseEDNSOptions(a.RData)
+		return EDNSInfo{
+			Version: optVersion(a.TTL),
+			UDPSize: a.Class,
+			DO:      a.TTL&EDNSFlagDO != 0,
+			Options: opts,
+		}, true
+	}
+	return EDNSInfo{}, false
+}
+
+// RCode returns the full 12 bit RCODE: the 4 bits in the header and the
+// upper 8 in the OPT record, if there is one.
+func (r DnsResponse) RCode() uint16 {
+	rcode := r.Header.Flags.RCode()
+	for _, a := range r.Additionals {
+		if a.Type == OPT {
+			rcode |= optRCode(a.TTL)
+		}
+	}
+	return rcode
+}
+
+// BadVersion builds a BADVERS response to request, advertising the EDNS
+// version we do speak.
+func BadVersion(request DnsRequest) []byte {
+	opt := NewOPT(DefaultUDPSize)
+	opt.TTL = BADVERS>>4<<24 | EDNSVersion<<16
+	request.Header.Flags = request.Header.Flags&0x7910 | 0x8080 | BADVERS&0xf
+	request.Additionals = []DnsResourceRecord{opt}
+	return SerializeRequest(request)
+}
+
+// ServerEDNS is what the client has learned about a server's EDNS support.
+type ServerEDNS struct {
+	// The highest version the server answered without BADVERS
+	Version uint8
+	// The server doesn't do EDNS at all, or something on the way drops
+	// queries with it, so they go without it
+	Disabled bool
+}
+
+// ServerEDNS returns what the client learned about server's EDNS support.
+// ok is false if it had nothing to learn yet: the server answered all
+// queries the way they were sent.
+func (c *Client) ServerEDNS(server string) (support ServerEDNS, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	support, ok = c.edns[server]
+	return support, ok
+}
+
+func (c *Client) learnEDNS(server string, support ServerEDNS) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.edns == nil {
+		c.edns = make(map[string]ServerEDNS)
+	}
+	c.edns[server] = support
+}
+
+// downgradeEDNS returns request as server can take it: with a lower EDNS
+// version or without EDNS, if that is what the client learned before.
+func (c *Client) downgradeEDNS(server string, request DnsRequest) DnsRequest {
+	if !request.HasEDNS() {
+		return request
+	}
+	support, ok := c.ServerEDNS(server)
+	if !ok {
+		return request
+	}
+	request.Additionals = append([]DnsResourceRecord{}, request.Additionals...)
+	if support.Disabled {
+		request.SetEDNS(0)
+	} else if request.EDNSVersion() > support.Version {
+		request.SetEDNSVersion(support.Version)
+	}
+	return request
+}
+
+// negotiateEDNS checks a response for BADVERS, or a FORMERR from a server
+// without EDNS, and learns from it what server can do: the lower version it
+// says it speaks, or no EDNS at all if it doesn't say. It reports whether
+// the query should be sent again.
+func (c *Client) negotiateEDNS(server string, request DnsRequest, response DnsResponse) bool {
+	if formErrNoEDNS(request, response) {
+		c.learnEDNS(server, ServerEDNS{Disabled: true})
+		return true
+	}
+	if !request.HasEDNS() || response.RCode() != BADVERS {
+		return false
+	}
+	support := ServerEDNS{Disabled: true}
+	if info, ok := response.EDNS(); ok && info.Version < request.EDNSVersion() {
+		support = ServerEDNS{Version: info.Version}
+	}
+	c.learnEDNS(server, support)
+	return true
+}
+
+// isBadVersion reports whether request asks for an EDNS version we don't
+// speak.
+func isBadVersion(request DnsRequest) bool {
+	return request.HasEDNS() && request.EDNSVersion() > EDNSVersion
+}
@@ -0,0 +1,36 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	EUI48 = 108
+	EUI64 = 109
+)
+
+// FormatEUI writes EUI48 and EUI64 rdata as hyphen separated hex pairs
+// (RFC 7043 section 3.2 and 4.2), e.g. 00-00-5e-00-53-2a.
+func FormatEUI(rdata []byte) string {
+	pairs := make([]string, len(rdata))
+	for i, b := range rdata {
+		pairs[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(pairs, "-")
+}
+
+func init() {
+	eui := func(size int) RRType {
+		return RRType{
+			Format: func(rdata []byte) string {
+				if len(rdata) != size {
+					return FormatUnknownRData(rdata)
+				}
+				return FormatEUI(rdata)
+			},
+		}
+	}
+	RegisterType(EUI48, eui(6))
+	RegisterType(EUI64, eui(8))
+}
@@ -0,0 +1,25 @@
+package dnsclient
+
+// Exit codes, so scripts can branch on the outcome of a query.
+const (
+	ExitOK       = 0 // NOERROR with answers
+	ExitNXDomain = 1 // name does not exist
+	ExitUsage    = 2 // bad flags or arguments, same code the flag package uses
+	ExitNoData   = 3 // name exists but has no records of the requested type
+	ExitFailure  = 4 // SERVFAIL, REFUSED, timeouts and broken responses
+)
+
+// ResponseExitCode maps the outcome of a query to an exit code.
+func ResponseExitCode(response DnsResponse) int {
+	switch response.Header.Flags.RCode() {
+	case 0:
+		if len(response.Answers) == 0 {
+			return ExitNoData
+		}
+		return ExitOK
+	case 3:
+		return ExitNXDomain
+	default:
+		return ExitFailure
+	}
+}
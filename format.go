@@ -0,0 +1,41 @@
+package dnsclient
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// formatFuncs are available to -format templates on top of the fields of
+// DnsResponse, so records can be printed the way they are elsewhere.
+var formatFuncs = template.FuncMap{
+	"name":  ToUnicode,
+	"type":  TypeToString,
+	"class": ClassToString,
+	"rdata": DnsResourceRecord.RDataString,
+	"rcode": func(r DnsResponse) string { return RCodeToString(r.Header.Flags.RCode()) },
+	"join":  strings.Join,
+}
+
+// ParseFormat parses a -format template. \n and \t are unescaped first since
+// they are awkward to type in a shell.
+func ParseFormat(text string) (*template.Template, error) {
+	text = strings.NewReplacer(`\n`, "\n", `\t`, "\t").Replace(text)
+	return template.New("format").Funcs(formatFuncs).Parse(text)
+}
+
+// ExecuteFormat writes response through tmpl, ending with a newline if the
+// template didn't.
+func ExecuteFormat(w io.Writer, tmpl *template.Template, response DnsResponse) error {
+	var b strings.Builder
+	err := tmpl.Execute(&b, response)
+	if err != nil {
+		return err
+	}
+	out := b.String()
+	if out != "" && !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	_, err = io.WriteString(w, out)
+	return err
+}
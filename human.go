@@ -0,0 +1,49 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatTTL renders a TTL as a duration with days, e.g. 1d2h or 1h12m5s.
+func FormatTTL(ttl int32) string {
+	if ttl <= 0 {
+		return "0s"
+	}
+	var b strings.Builder
+	for _, unit := range []struct {
+		suffix  string
+		seconds int32
+	}{{"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}} {
+		if n := ttl / unit.seconds; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			ttl -= n * unit.seconds
+		}
+	}
+	return b.String()
+}
+
+// HumanString is String with the TTL as a duration and the time the record
+// expires, counting from received. The TTL field of OPT records holds flags
+// and is left alone.
+func (r DnsResourceRecord) HumanString(received time.Time) string {
+	if r.Type == OPT {
+		return r.String()
+	}
+	expires := received.Add(time.Duration(r.TTL) * time.Second)
+	return fmt.Sprintf("Name: %s, Type: %s, Class: %s, TTL: %s (expires %s), RDLength: %d, RData: %s", ToUnicode(r.Name), TypeToString(r.Type), ClassToString(r.Class),
+		FormatTTL(r.TTL), expires.Format(time.RFC3339), r.RDLength, r.RDataString())
+}
+
+// HumanString is String with readable TTLs and expiry times, headed by when
+// the response arrived.
+func (r DnsResponse) HumanString() string {
+	received := r.Time
+	if received.IsZero() {
+		received = time.Now()
+	}
+	return fmt.Sprintf("Received: %s\n%s", received.Format(time.RFC3339), r.format(func(rr DnsResourceRecord) string {
+		return rr.HumanString(received)
+	}))
+}
@@ -0,0 +1,213 @@
+package dnsclient
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters from RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	// The decoder works in 32 bit integers, like RFC 3492's sample code
+	punyMaxInt = 1<<31 - 1
+	acePrefix  = "xn--"
+)
+
+var errPunyOverflow = errors.New("invalid punycode: overflow")
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punyThreshold(k, bias int) int {
+	t := k - bias
+	if t < punyTMin {
+		return punyTMin
+	}
+	if t > punyTMax {
+		return punyTMax
+	}
+	return t
+}
+
+// PunycodeEncode encodes a single label per RFC 3492, without the xn-- prefix.
+func PunycodeEncode(label string) string {
+	var out []byte
+	runes := []rune(label)
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	b := len(out)
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h < len(runes) {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) == n {
+				q := delta
+				for k := punyBase; ; k += punyBase {
+					t := punyThreshold(k, bias)
+					if q < t {
+						break
+					}
+					out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+					q = (q - t) / (punyBase - t)
+				}
+				out = append(out, punyDigit(q))
+				bias = punyAdapt(delta, h+1, h == b)
+				delta = 0
+				h++
+			}
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+// PunycodeDecode decodes a single label per RFC 3492, without the xn-- prefix.
+func PunycodeDecode(label string) (string, error) {
+	var out []rune
+	pos := strings.LastIndexByte(label, '-')
+	if pos >= 0 {
+		for _, c := range label[:pos] {
+			if c >= 0x80 {
+				return "", errors.New("invalid punycode: non-basic code point before delimiter")
+			}
+			out = append(out, c)
+		}
+		label = label[pos+1:]
+	}
+
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for len(label) > 0 {
+		oldi, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if len(label) == 0 {
+				return "", errors.New("invalid punycode: truncated input")
+			}
+			c := label[0]
+			label = label[1:]
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", errors.New("invalid punycode: bad digit")
+			}
+			// Overflow checks from RFC 3492 section 6.2
+			if digit > (punyMaxInt-i)/w {
+				return "", errPunyOverflow
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			if w > punyMaxInt/(punyBase-t) {
+				return "", errPunyOverflow
+			}
+			w *= punyBase - t
+		}
+		bias = punyAdapt(i-oldi, len(out)+1, oldi == 0)
+		if i/(len(out)+1) > punyMaxInt-n {
+			return "", errPunyOverflow
+		}
+		n += i / (len(out) + 1)
+		i %= len(out) + 1
+		if n < punyInitialN || n > utf8.MaxRune || n >= 0xd800 && n <= 0xdfff {
+			return "", errors.New("invalid punycode: code point out of range")
+		}
+		if i < 0 || i > len(out) {
+			return "", errPunyOverflow
+		}
+		out = append(out, 0)
+		copy(out[i+1:], out[i:])
+		out[i] = rune(n)
+		i++
+	}
+	return string(out), nil
+}
+
+// ToASCII converts every non-ASCII label of name to its xn-- A-label.
+func ToASCII(name string) string {
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		labels[i] = acePrefix + PunycodeEncode(strings.ToLower(label))
+	}
+	return strings.Join(labels, ".")
+}
+
+// ToUnicode converts every xn-- label of name back to its U-label. Labels that
+// fail to decode are left alone.
+func ToUnicode(name string) string {
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		if len(label) <= len(acePrefix) || !strings.EqualFold(label[:len(acePrefix)], acePrefix) {
+			continue
+		}
+		decoded, err := PunycodeDecode(label[len(acePrefix):])
+		if err != nil {
+			continue
+		}
+		labels[i] = decoded
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
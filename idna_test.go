@@ -0,0 +1,95 @@
+package dnsclient
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPunycode(t *testing.T) {
+	tests := []struct {
+		unicode, ascii string
+	}{
+		{"bücher", "bcher-kva"},
+		{"münchen", "mnchen-3ya"},
+		// RFC 3492 section 7.1 (L) and (S)
+		{"3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+		{"-> $1.00 <-", "-> $1.00 <--"},
+	}
+	for _, test := range tests {
+		if got := PunycodeEncode(test.unicode); got != test.ascii {
+			t.Errorf("PunycodeEncode(%q) = %q, want %q", test.unicode, got, test.ascii)
+		}
+		got, err := PunycodeDecode(test.ascii)
+		if err != nil || got != test.unicode {
+			t.Errorf("PunycodeDecode(%q) = %q, %v, want %q", test.ascii, got, err, test.unicode)
+		}
+	}
+}
+
+func TestPunycodeDecodeInvalid(t *testing.T) {
+	for _, label := range []string{
+		// Overflows i into a negative insert position
+		"AA000000000000000000700A",
+		"99999999999999999999",
+		"abc-9",
+		"ü-abc",
+		"a!",
+	} {
+		if got, err := PunycodeDecode(label); err == nil {
+			t.Errorf("PunycodeDecode(%q) = %q, want an error", label, got)
+		}
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"xn--bcher-kva.example", "bücher.example"},
+		{"XN--BCHER-KVA.example.", "BüCHER.example."},
+		// Labels that don't decode stay as they are
+		{"xn--AA000000000000000000700A.example", "xn--AA000000000000000000700A.example"},
+		{"xn--.example", "xn--.example"},
+	}
+	for _, test := range tests {
+		if got := ToUnicode(test.name); got != test.want {
+			t.Errorf("ToUnicode(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+	if got := ToASCII("bücher.example"); got != "xn--bcher-kva.example" {
+		t.Errorf("ToASCII() = %q", got)
+	}
+}
+
+func FuzzPunycodeDecode(f *testing.F) {
+	f.Add("bcher-kva")
+	f.Add("AA000000000000000000700A")
+	f.Add("3B-ww4c5e180e575a65lsy2b")
+	f.Fuzz(func(t *testing.T, label string) {
+		decoded, err := PunycodeDecode(label)
+		if err != nil {
+			return
+		}
+		if !utf8.ValidString(decoded) {
+			t.Fatalf("PunycodeDecode(%q) = %q, not valid UTF-8", label, decoded)
+		}
+		again, err := PunycodeDecode(PunycodeEncode(decoded))
+		if err != nil || again != decoded {
+			t.Fatalf("%q decodes to %q, which round trips to %q, %v", label, decoded, again, err)
+		}
+	})
+}
+
+func FuzzPunycodeEncode(f *testing.F) {
+	f.Add("bücher")
+	f.Add("3年B組金八先生")
+	f.Fuzz(func(t *testing.T, s string) {
+		if !utf8.ValidString(s) {
+			return
+		}
+		got, err := PunycodeDecode(PunycodeEncode(s))
+		if err != nil || got != s {
+			t.Fatalf("%q round trips to %q, %v", s, got, err)
+		}
+	})
+}
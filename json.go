@@ -0,0 +1,48 @@
+package dnsclient
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+)
+
+// JSONResult is the outcome of one query as printed by -json, one object per
+// line (NDJSON) so a stream of results can be read line by line.
+type JSONResult struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	RCode   string       `json:"rcode,omitempty"`
+	Answers []JSONRecord `json:"answers,omitempty"`
+	Server  string       `json:"server,omitempty"`
+	RTT     float64      `json:"rtt_ms,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+type JSONRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	TTL   int32  `json:"ttl"`
+	RData string `json:"rdata"`
+}
+
+// NewJSONResult describes the response to question, or err if there was no
+// response.
+func NewJSONResult(question DnsQuestion, response *DnsResponse, err error) JSONResult {
+	result := JSONResult{Name: ToUnicode(question.QName), Type: TypeToString(question.QType)}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.RCode = RCodeToString(response.Header.Flags.RCode())
+	result.Server = response.Server
+	result.RTT = math.Round(response.RTT.Seconds()*1e6) / 1000
+	for _, a := range response.Answers {
+		result.Answers = append(result.Answers, JSONRecord{ToUnicode(a.Name), TypeToString(a.Type), a.TTL, a.RDataString()})
+	}
+	return result
+}
+
+// WriteJSON writes the result as a single line.
+func WriteJSON(w io.Writer, question DnsQuestion, response *DnsResponse, err error) error {
+	return json.NewEncoder(w).Encode(NewJSONResult(question, response, err))
+}
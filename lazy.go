@@ -0,0 +1,81 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// LazyRecord is a record whose RData has not been decoded yet. It points
+// into the message it was read from, so skipping it costs nothing beyond
+// reading the owner name and fixed fields. The message must not be changed
+// while the record is in use.
+type LazyRecord struct {
+	Name     string
+	Type     uint16
+	Class    uint16
+	TTL      int32
+	RDLength uint16
+
+	msg    []byte
+	offset int
+}
+
+// RawRData returns the rdata as it is in the message. Compressed names in it
+// still point into the message, use Decode to get them resolved.
+func (l LazyRecord) RawRData() []byte {
+	return l.msg[l.offset : l.offset+int(l.RDLength)]
+}
+
+// Decode parses the rdata the way ReadResourceRecord does.
+func (l LazyRecord) Decode() (DnsResourceRecord, error) {
+	r := bytes.NewReader(l.msg)
+	r.Seek(int64(l.offset), io.SeekStart)
+	rdata, err := ParseRData(r, l.Type, l.RDLength)
+	if err != nil {
+		return DnsResourceRecord{}, err
+	}
+	return DnsResourceRecord{
+		Name:     l.Name,
+		Type:     l.Type,
+		Class:    l.Class,
+		TTL:      l.TTL,
+		RDLength: l.RDLength,
+		RData:    rdata,
+	}, nil
+}
+
+// NextLazy is Next without decoding the rdata.
+func (m *MessageReader) NextLazy() (LazyRecord, int, error) {
+	for m.section < len(m.left) && m.left[m.section] == 0 {
+		m.section++
+	}
+	if m.section == len(m.left) {
+		return LazyRecord{}, 0, io.EOF
+	}
+	m.left[m.section]--
+
+	l := LazyRecord{msg: m.msg}
+	name, err := ReadName(m.r)
+	if err != nil {
+		return l, m.section, err
+	}
+	l.Name = name
+	var fixed struct {
+		Type, Class uint16
+		TTL         int32
+		RDLength    uint16
+	}
+	err = binary.Read(m.r, binary.BigEndian, &fixed)
+	if err != nil {
+		return l, m.section, io.ErrUnexpectedEOF
+	}
+	l.Type, l.Class, l.TTL, l.RDLength = fixed.Type, fixed.Class, fixed.TTL, fixed.RDLength
+	l.offset = len(m.msg) - m.r.Len()
+	if l.offset+int(l.RDLength) > len(m.msg) {
+		return l, m.section, errors.New("rdata runs past the end of the message")
+	}
+	m.r.Seek(int64(l.RDLength), io.SeekCurrent)
+	return l, m.section, nil
+}
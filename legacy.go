@@ -0,0 +1,145 @@
+package dnsclient
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const RP = 17
+
+// parseTwoNames stores the two domain names of MINFO and RP uncompressed,
+// one after the other.
+func parseTwoNames(r *bytes.Reader, length uint16) ([]byte, error) {
+	var rdata []byte
+	for i := 0; i < 2; i++ {
+		name, err := ReadName(r)
+		if err != nil {
+			return nil, err
+		}
+		rdata = append(rdata, SerializeName(name)...)
+	}
+	return rdata, nil
+}
+
+func readTwoNames(rdata []byte) (string, string, error) {
+	r := bytes.NewReader(rdata)
+	first, err := ReadName(r)
+	if err != nil {
+		return "", "", err
+	}
+	second, err := ReadName(r)
+	if err != nil {
+		return "", "", err
+	}
+	return first, second, nil
+}
+
+// ParseHINFO returns the CPU and OS strings of HINFO rdata.
+func ParseHINFO(rdata []byte) (string, string, error) {
+	strs := ReadCharacterStrings(rdata)
+	if len(strs) != 2 {
+		return "", "", errors.New("hinfo rdata must have two strings")
+	}
+	return strs[0], strs[1], nil
+}
+
+// ParseMINFO returns the responsible and error mailboxes of MINFO rdata.
+func ParseMINFO(rdata []byte) (string, string, error) {
+	return readTwoNames(rdata)
+}
+
+// ParseRP returns the mailbox and TXT record name of RP rdata (RFC 1183).
+func ParseRP(rdata []byte) (string, string, error) {
+	return readTwoNames(rdata)
+}
+
+// WKSRecord is the RData of a WKS record (RFC 1035 section 3.4.2).
+type WKSRecord struct {
+	Address  net.IP
+	Protocol uint8
+	Ports    []uint16
+}
+
+func (w WKSRecord) String() string {
+	strs := []string{w.Address.String(), strconv.Itoa(int(w.Protocol))}
+	for _, p := range w.Ports {
+		strs = append(strs, strconv.Itoa(int(p)))
+	}
+	return strings.Join(strs, " ")
+}
+
+// ParseWKS decodes WKS rdata. Bit n of the bitmap, counting from the most
+// significant bit of the first byte, is set if port n is served.
+func ParseWKS(rdata []byte) (WKSRecord, error) {
+	var w WKSRecord
+	if len(rdata) < 5 {
+		return w, errors.New("wks rdata too short")
+	}
+	w.Address = net.IP(rdata[:4])
+	w.Protocol = rdata[4]
+	for i, b := range rdata[5:] {
+		for bit := 0; bit < 8; bit++ {
+			if b&(0x80>>bit) != 0 {
+				w.Ports = append(w.Ports, uint16(i*8+bit))
+			}
+		}
+	}
+	return w, nil
+}
+
+// FormatName formats name for display, in Unicode. Empty names are the
+// root, which is written as "."
+func FormatName(name string) string {
+	if name == "" {
+		return "."
+	}
+	return ToUnicode(name)
+}
+
+func init() {
+	RegisterType(HINFO, RRType{
+		Format: func(rdata []byte) string {
+			cpu, os, err := ParseHINFO(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return strconv.Quote(cpu) + " " + strconv.Quote(os)
+		},
+	})
+
+	RegisterType(WKS, RRType{
+		Format: func(rdata []byte) string {
+			w, err := ParseWKS(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return w.String()
+		},
+	})
+
+	twoNames := RRType{
+		Parse: parseTwoNames,
+		Format: func(rdata []byte) string {
+			first, second, err := readTwoNames(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return fmt.Sprintf("%s %s", FormatName(first), FormatName(second))
+		},
+	}
+	RegisterType(MINFO, twoNames)
+	RegisterType(RP, twoNames)
+}
+
+// canonicalTwoNames lowercases both names of MINFO and RP rdata.
+func canonicalTwoNames(rdata []byte) []byte {
+	first, second, err := readTwoNames(rdata)
+	if err != nil {
+		return rdata
+	}
+	return append(SerializeName(strings.ToLower(first)), SerializeName(strings.ToLower(second))...)
+}
@@ -0,0 +1,204 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+)
+
+// The Lookup methods mirror net.Resolver so a *Client can be swapped in for it.
+// Errors are returned as *net.DNSError and names as fully qualified (trailing dot).
+
+func (c *Client) lookup(ctx context.Context, name string, qtype uint16) ([]DnsResourceRecord, error) {
+	response, err := c.exchange(ctx, name, qtype)
+	if err != nil {
+		var timeout *TimeoutError
+		isTimeout := ctx.Err() == context.DeadlineExceeded || errors.As(err, &timeout)
+		return nil, &net.DNSError{Err: err.Error(), Name: name, IsTimeout: isTimeout}
+	}
+	server := response.Server
+
+	switch response.Header.Flags.RCode() {
+	case 0:
+	case 3:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	case 2:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: server, IsTemporary: true}
+	default:
+		return nil, &net.DNSError{Err: "server misbehaving", Name: name, Server: server}
+	}
+
+	var records []DnsResourceRecord
+	for _, a := range response.Answers {
+		if a.Type == qtype {
+			records = append(records, a)
+		}
+	}
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// LookupHost looks up the given host and returns a slice of its addresses.
+func (c *Client) LookupHost(ctx context.Context, host string) ([]string, error) {
+	ips, err := c.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = ip.String()
+	}
+	return addrs, nil
+}
+
+// LookupIP looks up host for the given network: "ip", "ip4" or "ip6".
+func (c *Client) LookupIP(ctx context.Context, network, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	var qtypes []uint16
+	switch network {
+	case "ip":
+		qtypes = []uint16{A, AAAA}
+	case "ip4":
+		qtypes = []uint16{A}
+	case "ip6":
+		qtypes = []uint16{AAAA}
+	default:
+		return nil, net.UnknownNetworkError(network)
+	}
+
+	type result struct {
+		records []DnsResourceRecord
+		err     error
+	}
+	results := make(chan result, len(qtypes))
+	for _, qtype := range qtypes {
+		go func(qtype uint16) {
+			records, err := c.lookup(ctx, host, qtype)
+			results <- result{records, err}
+		}(qtype)
+	}
+
+	var ips []net.IP
+	var err error
+	for range qtypes {
+		res := <-results
+		if res.err != nil {
+			err = res.err
+			continue
+		}
+		for _, r := range res.records {
+			ips = append(ips, net.IP(r.RData))
+		}
+	}
+	if len(ips) == 0 {
+		return nil, err
+	}
+	return ips, nil
+}
+
+// LookupCNAME returns the canonical name for host, following any CNAME and
+// DNAME chain in the answer. A host without a CNAME is its own canonical name.
+func (c *Client) LookupCNAME(ctx context.Context, host string) (string, error) {
+	response, err := c.exchange(ctx, host, A)
+	if err != nil {
+		return "", &net.DNSError{Err: err.Error(), Name: host}
+	}
+	if response.Header.Flags.RCode() == 3 {
+		return "", &net.DNSError{Err: "no such host", Name: host, Server: response.Server, IsNotFound: true}
+	}
+
+	cname, _ := FollowAliases(response.Answers, ToASCII(host))
+	return fqdn(cname), nil
+}
+
+// LookupAddr performs a reverse lookup for the given address.
+func (c *Client) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	name, err := ReverseName(addr)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: addr}
+	}
+	records, err := c.lookup(ctx, name, PTR)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range records {
+		names = append(names, fqdn(string(r.RData)))
+	}
+	return names, nil
+}
+
+// LookupMX returns the MX records for name sorted by preference.
+func (c *Client) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	records, err := c.lookup(ctx, name, MX)
+	if err != nil {
+		return nil, err
+	}
+	var mxs []*net.MX
+	for _, r := range records {
+		pref, host, err := ParseMX(r.RData)
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: name}
+		}
+		mxs = append(mxs, &net.MX{Host: fqdn(host), Pref: pref})
+	}
+	sort.SliceStable(mxs, func(i, j int) bool { return mxs[i].Pref < mxs[j].Pref })
+	return mxs, nil
+}
+
+// LookupTXT returns the TXT records for name. The strings of a record are
+// concatenated, as net.Resolver does.
+func (c *Client) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, err := c.lookup(ctx, name, TXT)
+	if err != nil {
+		return nil, err
+	}
+	var txts []string
+	for _, r := range records {
+		txts = append(txts, strings.Join(ReadCharacterStrings(r.RData), ""))
+	}
+	return txts, nil
+}
+
+// LookupSRV queries _service._proto.name, or name directly if service and
+// proto are empty. Records are sorted by priority, then by weight.
+func (c *Client) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	target := name
+	if service != "" || proto != "" {
+		target = "_" + service + "._" + proto + "." + name
+	}
+	records, err := c.lookup(ctx, target, SRV)
+	if err != nil {
+		return "", nil, err
+	}
+	var srvs []*net.SRV
+	for _, r := range records {
+		srv, err := ParseSRV(r.RData)
+		if err != nil {
+			return "", nil, &net.DNSError{Err: err.Error(), Name: target}
+		}
+		srv.Target = fqdn(srv.Target)
+		srvs = append(srvs, &srv)
+	}
+	sort.SliceStable(srvs, func(i, j int) bool {
+		if srvs[i].Priority != srvs[j].Priority {
+			return srvs[i].Priority < srvs[j].Priority
+		}
+		return srvs[i].Weight > srvs[j].Weight
+	})
+	return fqdn(records[0].Name), srvs, nil
+}
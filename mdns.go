@@ -0,0 +1,515 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MDNSGroup is where mDNS (RFC 6762) queries and announcements are sent.
+var MDNSGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const (
+	// Top bit of the class: cache flush in records (RFC 6762 10.2), asking for
+	// a unicast response in questions (5.4)
+	mdnsCacheFlush = 0x8000
+	mdnsQU         = 0x8000
+
+	// Section 10: TTLs of records with host names and of the others
+	mdnsHostTTL  = 120
+	mdnsOtherTTL = 4500
+
+	// Section 8.1: three probes 250 milliseconds apart
+	mdnsProbes        = 3
+	mdnsProbeInterval = 250 * time.Millisecond
+
+	// Renames before giving up, section 8.1 asks for a pause after 15
+	mdnsMaxConflicts = 15
+)
+
+// MDNSService is a DNS-SD (RFC 6763) service instance.
+type MDNSService struct {
+	// Instance name shown to users, e.g. "Living Room Printer"
+	Instance string
+	// Service type and protocol, e.g. "_ipp._tcp"
+	Service string
+	Port    uint16
+	// key=value pairs
+	TXT []string
+}
+
+// MDNSResponder announces a host name (under .local) and services on the
+// local network and answers the multicast queries for them. The names are
+// probed first and renamed ("host-2", "Instance (2)") if someone else
+// already has them, so Host and the instance names of Services may have
+// changed once Start returns.
+type MDNSResponder struct {
+	Host      string
+	Addrs     []net.IP
+	Services  []MDNSService
+	Interface *net.Interface
+
+	conn    *net.UDPConn
+	mu      sync.Mutex
+	records []DnsResourceRecord
+	// Messages go here while probing, nil otherwise
+	probe chan DnsResponse
+}
+
+// Start probes the names, announces them and starts answering queries. The
+// addresses of the interface (or all of them) are announced if Addrs is
+// empty.
+func (m *MDNSResponder) Start(ctx context.Context) error {
+	for _, s := range m.Services {
+		if strings.Contains(s.Instance, ".") || len(s.Instance) > 63 {
+			return fmt.Errorf("instance name %q can't have dots or be longer than 63 bytes", s.Instance)
+		}
+	}
+	if len(m.Addrs) == 0 {
+		addrs, err := interfaceIPs(m.Interface)
+		if err != nil {
+			return err
+		}
+		m.Addrs = addrs
+	}
+	if len(m.Addrs) == 0 {
+		return errors.New("no addresses to announce")
+	}
+	conn, err := net.ListenMulticastUDP("udp4", m.Interface, MDNSGroup)
+	if err != nil {
+		return err
+	}
+	// Go turns multicast loopback off, but other responders on this host
+	// need to see the probes and answers
+	if raw, err := conn.SyscallConn(); err == nil {
+		raw.Control(func(fd uintptr) {
+			syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MULTICAST_LOOP, 1)
+		})
+	}
+	m.conn = conn
+	go m.read()
+	err = m.establish(ctx)
+	if err != nil {
+		conn.Close()
+	}
+	return err
+}
+
+func interfaceIPs(ifi *net.Interface) ([]net.IP, error) {
+	var addrs []net.Addr
+	var err error
+	if ifi != nil {
+		addrs, err = ifi.Addrs()
+	} else {
+		addrs, err = net.InterfaceAddrs()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, a := range addrs {
+		if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+			ips = append(ips, ipnet.IP)
+		}
+	}
+	return ips, nil
+}
+
+// establish probes until the names are free, renaming on conflicts, then
+// announces them (sections 8.1 and 8.3).
+func (m *MDNSResponder) establish(ctx context.Context) error {
+	probe := make(chan DnsResponse, 16)
+	m.mu.Lock()
+	m.probe = probe
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.probe = nil
+		m.mu.Unlock()
+	}()
+
+	// Section 8.1: a random delay first, so hosts powered on together don't probe in step
+	if err := sleep(ctx, time.Duration(rand.Int63n(int64(mdnsProbeInterval)))); err != nil {
+		return err
+	}
+	for conflicts := 0; ; {
+		records := m.buildRecords()
+		conflict, lost, err := m.probeNames(ctx, records, probe)
+		if err != nil {
+			return err
+		}
+		if conflict != "" {
+			conflicts++
+			if conflicts > mdnsMaxConflicts {
+				return fmt.Errorf("giving up after %d name conflicts", conflicts-1)
+			}
+			m.rename(conflict)
+			continue
+		}
+		if lost {
+			// Section 8.2: someone probing for the same name at the same
+			// time won the tie, see whether they go ahead with it
+			if err := sleep(ctx, time.Second); err != nil {
+				return err
+			}
+			continue
+		}
+		m.mu.Lock()
+		m.records = records
+		m.mu.Unlock()
+		break
+	}
+
+	// Section 8.3: announce twice, a second apart
+	records := m.records
+	m.announce(records)
+	go func() {
+		time.Sleep(time.Second)
+		m.announce(records)
+	}()
+	return nil
+}
+
+// probeNames sends the probes for the unique names of records and returns
+// the name that turned out to be taken, or whether a simultaneous probe
+// for one of them won the tie-break.
+func (m *MDNSResponder) probeNames(ctx context.Context, records []DnsResourceRecord, probe chan DnsResponse) (conflict string, lost bool, err error) {
+	proposed := make(map[string][]DnsResourceRecord)
+	var query DnsResponse
+	for _, r := range records {
+		if r.Class&mdnsCacheFlush == 0 {
+			continue
+		}
+		key := string(Name(r.Name).Canonical())
+		if _, ok := proposed[key]; !ok {
+			query.Questions = append(query.Questions, DnsQuestion{QName: r.Name, QType: ANY, QClass: IN | mdnsQU})
+		}
+		r.Class &^= mdnsCacheFlush
+		proposed[key] = append(proposed[key], r)
+		query.Authorities = append(query.Authorities, r)
+	}
+	msg := SerializeResponse(query)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for sent := 0; ; {
+		select {
+		case <-ctx.Done():
+			return "", false, ctx.Err()
+		case <-timer.C:
+			if sent == mdnsProbes {
+				return "", false, nil
+			}
+			if _, err := m.conn.WriteToUDP(msg, MDNSGroup); err != nil {
+				return "", false, err
+			}
+			sent++
+			timer.Reset(mdnsProbeInterval)
+		case in := <-probe:
+			if in.Header.Flags&0x8000 != 0 {
+				for _, section := range [][]DnsResourceRecord{in.Answers, in.Additionals} {
+					for _, r := range section {
+						ours, ok := proposed[string(Name(r.Name).Canonical())]
+						if ok && !containsRecord(ours, r) {
+							return r.Name, false, nil
+						}
+					}
+				}
+				continue
+			}
+			// Another probe: the lexicographically later records win (section 8.2)
+			theirs := make(map[string][]DnsResourceRecord)
+			for _, r := range in.Authorities {
+				key := string(Name(r.Name).Canonical())
+				if _, ok := proposed[key]; ok {
+					theirs[key] = append(theirs[key], r)
+				}
+			}
+			for key, records := range theirs {
+				if compareRecordSets(proposed[key], records) < 0 {
+					return "", true, nil
+				}
+			}
+		}
+	}
+}
+
+func containsRecord(records []DnsResourceRecord, r DnsResourceRecord) bool {
+	for _, o := range records {
+		if o.Type == r.Type && o.Class&^mdnsCacheFlush == r.Class&^mdnsCacheFlush && bytes.Equal(o.RData, r.RData) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareRecordSets compares two sets of records for the same name the way
+// section 8.2 breaks ties: sorted, then record by record on class, type and
+// rdata, and a set that runs out first is the earlier.
+func compareRecordSets(a, b []DnsResourceRecord) int {
+	sortRecords := func(records []DnsResourceRecord) [][]byte {
+		var keys [][]byte
+		for _, r := range records {
+			class := r.Class &^ mdnsCacheFlush
+			key := []byte{byte(class >> 8), byte(class), byte(r.Type >> 8), byte(r.Type)}
+			keys = append(keys, append(key, SerializeRData(r.Type, r.RData)...))
+		}
+		sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+		return keys
+	}
+	ka, kb := sortRecords(a), sortRecords(b)
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if c := bytes.Compare(ka[i], kb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(ka) - len(kb)
+}
+
+// rename picks the next name for the host or the service instance whose
+// name is taken.
+func (m *MDNSResponder) rename(taken string) {
+	if Name(taken).Equal(Name(m.Host + ".local")) {
+		old := m.Host
+		m.Host = nextName(m.Host, "-%d")
+		log.Printf("mdns: %s.local is taken, using %s.local", old, m.Host)
+		return
+	}
+	for i, s := range m.Services {
+		if Name(taken).Equal(Name(s.Instance + "." + s.Service + ".local")) {
+			m.Services[i].Instance = nextName(s.Instance, " (%d)")
+			log.Printf("mdns: %q is taken, using %q", s.Instance, m.Services[i].Instance)
+		}
+	}
+}
+
+// nextName turns "name" into "name-2" and "name-2" into "name-3", with
+// suffix as the format of the number.
+func nextName(name, suffix string) string {
+	n := 2
+	for i := 3; i <= 16; i++ {
+		if strings.HasSuffix(name, fmt.Sprintf(suffix, i-1)) {
+			name, n = strings.TrimSuffix(name, fmt.Sprintf(suffix, i-1)), i
+			break
+		}
+	}
+	return name + fmt.Sprintf(suffix, n)
+}
+
+// buildRecords returns the records for the host and the services, the
+// unique ones with the cache flush bit set.
+func (m *MDNSResponder) buildRecords() []DnsResourceRecord {
+	host := m.Host + ".local"
+	var records []DnsResourceRecord
+	add := func(name string, t uint16, unique bool, ttl int32, rdata []byte) {
+		class := uint16(IN)
+		if unique {
+			class |= mdnsCacheFlush
+		}
+		records = append(records, DnsResourceRecord{Name: name, Type: t, Class: class, TTL: ttl,
+			RDLength: uint16(len(SerializeRData(t, rdata))), RData: rdata})
+	}
+	for _, ip := range m.Addrs {
+		if ip4 := ip.To4(); ip4 != nil {
+			add(host, A, true, mdnsHostTTL, ip4)
+		} else {
+			add(host, AAAA, true, mdnsHostTTL, ip.To16())
+		}
+	}
+	for _, s := range m.Services {
+		service := s.Service + ".local"
+		instance := s.Instance + "." + service
+		add("_services._dns-sd._udp.local", PTR, false, mdnsOtherTTL, []byte(service))
+		add(service, PTR, false, mdnsOtherTTL, []byte(instance))
+		srv := []byte{0, 0, 0, 0, byte(s.Port >> 8), byte(s.Port)}
+		add(instance, SRV, true, mdnsHostTTL, append(srv, SerializeName(host)...))
+		// RFC 6763 6.1: no pairs is a single empty string
+		txt := []byte{}
+		for _, kv := range s.TXT {
+			txt = append(append(txt, byte(len(kv))), kv...)
+		}
+		if len(txt) == 0 {
+			txt = []byte{0}
+		}
+		add(instance, TXT, true, mdnsOtherTTL, txt)
+	}
+	return records
+}
+
+func (m *MDNSResponder) announce(records []DnsResourceRecord) {
+	response := DnsResponse{Answers: records}
+	response.Header.Flags = 0x8000 | FlagAA
+	_, err := m.conn.WriteToUDP(SerializeResponse(response), MDNSGroup)
+	if err != nil {
+		log.Printf("mdns: %v", err)
+	}
+}
+
+func (m *MDNSResponder) read() {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		msg, err := ReadResponse(append([]byte{}, buf[:n]...))
+		if err != nil {
+			continue
+		}
+		m.mu.Lock()
+		probe := m.probe
+		records := m.records
+		m.mu.Unlock()
+		if probe != nil {
+			select {
+			case probe <- msg:
+			default:
+			}
+			continue
+		}
+		if msg.Header.Flags&0x8000 != 0 {
+			m.checkConflict(msg, records)
+			continue
+		}
+		m.answer(msg, from, records)
+	}
+}
+
+// checkConflict starts over with probing if a response has different
+// records for one of the unique names (section 9).
+func (m *MDNSResponder) checkConflict(msg DnsResponse, records []DnsResourceRecord) {
+	for _, r := range msg.Answers {
+		var ours []DnsResourceRecord
+		for _, o := range records {
+			if o.Class&mdnsCacheFlush != 0 && o.Type == r.Type && Name(o.Name).Equal(Name(r.Name)) {
+				ours = append(ours, o)
+			}
+		}
+		if len(ours) > 0 && r.TTL > 0 && !containsRecord(ours, r) {
+			log.Printf("mdns: conflicting %s record for %s from another host, probing again", TypeToString(r.Type), r.Name)
+			// Stop answering right away, establish sets up its own channel
+			m.mu.Lock()
+			m.probe = make(chan DnsResponse, 16)
+			m.mu.Unlock()
+			go func() {
+				if err := m.establish(context.Background()); err != nil {
+					log.Printf("mdns: %v", err)
+				}
+			}()
+			return
+		}
+	}
+}
+
+// answer responds to a query for any of the records, leaving out those the
+// querier says it already knows (section 7.1).
+func (m *MDNSResponder) answer(query DnsResponse, from *net.UDPAddr, records []DnsResourceRecord) {
+	var answers, additionals []DnsResourceRecord
+	unicast := from.Port != MDNSGroup.Port
+	for _, q := range query.Questions {
+		// Probes (queries with authority records) get multicast answers so
+		// every prober sees the conflict, even one sharing the port on this host
+		if q.QClass&mdnsQU != 0 && len(query.Authorities) == 0 {
+			unicast = true
+		}
+		for _, r := range records {
+			if !Name(r.Name).Equal(Name(q.QName)) || (q.QType != r.Type && q.QType != ANY) || containsRecord(answers, r) {
+				continue
+			}
+			if knownAnswer(query.Answers, r) {
+				continue
+			}
+			answers = append(answers, r)
+		}
+	}
+	if len(answers) == 0 {
+		return
+	}
+	// Section 12 of RFC 6763: what the querier will ask for next
+	for _, a := range answers {
+		var target string
+		switch a.Type {
+		case PTR:
+			target = string(a.RData)
+		case SRV:
+			target, _ = ReadName(bytes.NewReader(a.RData[6:]))
+		default:
+			continue
+		}
+		for _, r := range records {
+			if Name(r.Name).Equal(Name(target)) && !containsRecord(answers, r) && !containsRecord(additionals, r) {
+				additionals = append(additionals, r)
+			}
+		}
+	}
+
+	response := DnsResponse{Answers: answers, Additionals: additionals}
+	response.Header.Flags = 0x8000 | FlagAA
+	to := MDNSGroup
+	if from.Port != MDNSGroup.Port {
+		// Section 6.7: a legacy resolver, answer it like a unicast DNS server
+		response.Header.Id = query.Header.Id
+		response.Questions = query.Questions
+		for _, section := range [][]DnsResourceRecord{response.Answers, response.Additionals} {
+			for i := range section {
+				section[i].Class &^= mdnsCacheFlush
+				if section[i].TTL > 10 {
+					section[i].TTL = 10
+				}
+			}
+		}
+	}
+	if unicast {
+		to = from
+	}
+	_, err := m.conn.WriteToUDP(SerializeResponse(response), to)
+	if err != nil {
+		log.Printf("mdns: %v", err)
+	}
+}
+
+// knownAnswer reports whether the querier listed r among the answers it
+// has, with at least half its TTL left.
+func knownAnswer(known []DnsResourceRecord, r DnsResourceRecord) bool {
+	for _, k := range known {
+		if k.Type == r.Type && Name(k.Name).Equal(Name(r.Name)) && bytes.Equal(k.RData, r.RData) && k.TTL >= r.TTL/2 {
+			return true
+		}
+	}
+	return false
+}
+
+// Close says goodbye, sending the records with a TTL of 0 so caches drop
+// them (section 10.1), and stops answering.
+func (m *MDNSResponder) Close() error {
+	m.mu.Lock()
+	records := append([]DnsResourceRecord{}, m.records...)
+	m.mu.Unlock()
+	for i := range records {
+		records[i].TTL = 0
+	}
+	if len(records) > 0 {
+		m.announce(records)
+	}
+	return m.conn.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,136 @@
+package dnsclient
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMDNSNextName(t *testing.T) {
+	tests := []struct {
+		name, suffix, want string
+	}{
+		{"host", "-%d", "host-2"},
+		{"host-2", "-%d", "host-3"},
+		{"host-15", "-%d", "host-16"},
+		{"host-16", "-%d", "host-16-2"},
+		{"Printer", " (%d)", "Printer (2)"},
+		{"Printer (2)", " (%d)", "Printer (3)"},
+	}
+	for _, test := range tests {
+		if got := nextName(test.name, test.suffix); got != test.want {
+			t.Errorf("nextName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMDNSCompareRecordSets(t *testing.T) {
+	a := func(ip ...byte) DnsResourceRecord {
+		return DnsResourceRecord{Name: "host.local", Type: A, Class: IN | mdnsCacheFlush, RData: ip}
+	}
+	tests := []struct {
+		name string
+		a, b []DnsResourceRecord
+		want int
+	}{
+		{"same", []DnsResourceRecord{a(10, 0, 0, 1), a(10, 0, 0, 2)}, []DnsResourceRecord{a(10, 0, 0, 2), a(10, 0, 0, 1)}, 0},
+		{"lower rdata", []DnsResourceRecord{a(10, 0, 0, 1)}, []DnsResourceRecord{a(10, 0, 0, 2)}, -1},
+		{"runs out first", []DnsResourceRecord{a(10, 0, 0, 1)}, []DnsResourceRecord{a(10, 0, 0, 1), a(10, 0, 0, 2)}, -1},
+		{"higher type", []DnsResourceRecord{{Name: "host.local", Type: AAAA, Class: IN, RData: make([]byte, 16)}}, []DnsResourceRecord{a(10, 0, 0, 1)}, 1},
+	}
+	for _, test := range tests {
+		got := compareRecordSets(test.a, test.b)
+		if got < 0 {
+			got = -1
+		} else if got > 0 {
+			got = 1
+		}
+		if got != test.want {
+			t.Errorf("%s: compareRecordSets() = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+// legacyQuery sends query to m as a legacy resolver would, from a port
+// other than 5353, and returns the reply, or false if none comes.
+func legacyQuery(t *testing.T, m *MDNSResponder, query DnsResponse) (DnsResponse, bool) {
+	t.Helper()
+	client, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	m.answer(query, client.LocalAddr().(*net.UDPAddr), m.buildRecords())
+
+	client.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	buf := make([]byte, 9000)
+	n, err := client.Read(buf)
+	if err != nil {
+		return DnsResponse{}, false
+	}
+	response, err := ReadResponse(buf[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response, true
+}
+
+func TestMDNSAnswer(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	m := &MDNSResponder{
+		Host:     "nas",
+		Addrs:    []net.IP{net.IPv4(192, 0, 2, 5), net.ParseIP("2001:db8::5")},
+		Services: []MDNSService{{Instance: "Printer", Service: "_ipp._tcp", Port: 631, TXT: []string{"rp=ipp/print"}}},
+		conn:     conn,
+	}
+
+	query := DnsResponse{
+		Header:    DnsHeader{Id: 0x1234, QdCount: 1},
+		Questions: []DnsQuestion{{QName: "_ipp._tcp.local", QType: PTR, QClass: IN}},
+	}
+	response, ok := legacyQuery(t, m, query)
+	if !ok {
+		t.Fatal("no answer to the PTR query")
+	}
+	if response.Header.Id != 0x1234 || len(response.Questions) != 1 {
+		t.Errorf("legacy answer has id %#x and %d questions, want the query's", response.Header.Id, len(response.Questions))
+	}
+	if len(response.Answers) != 1 || string(response.Answers[0].RData) != "Printer._ipp._tcp.local" {
+		t.Fatalf("answers %+v, want the PTR to the instance", response.Answers)
+	}
+	// The SRV and TXT of the instance come along
+	types := make(map[uint16]int)
+	for _, r := range append(response.Answers, response.Additionals...) {
+		types[r.Type]++
+		if r.TTL > 10 || r.Class != IN {
+			t.Errorf("%s record with ttl %d and class %#x, want at most 10 and IN for a legacy resolver", TypeToString(r.Type), r.TTL, r.Class)
+		}
+	}
+	if types[SRV] != 1 || types[TXT] != 1 {
+		t.Errorf("additionals %+v, want the SRV and TXT records", response.Additionals)
+	}
+
+	host := DnsResponse{Questions: []DnsQuestion{{QName: "NAS.local", QType: A, QClass: IN}}}
+	if response, ok = legacyQuery(t, m, host); !ok || len(response.Answers) != 1 || !net.IP(response.Answers[0].RData).Equal(net.IPv4(192, 0, 2, 5)) {
+		t.Errorf("A query got %+v, want 192.0.2.5", response.Answers)
+	}
+
+	// Nothing is sent for records the querier already has
+	query.Answers = []DnsResourceRecord{{Name: "_ipp._tcp.local", Type: PTR, Class: IN, TTL: mdnsOtherTTL, RData: []byte("Printer._ipp._tcp.local")}}
+	if response, ok = legacyQuery(t, m, query); ok {
+		t.Errorf("answered a known answer with %+v", response.Answers)
+	}
+	// Unless it is about to expire
+	query.Answers[0].TTL = 60
+	if _, ok = legacyQuery(t, m, query); !ok {
+		t.Error("no answer when the known answer is about to expire")
+	}
+	other := DnsResponse{Questions: []DnsQuestion{{QName: "other.local", QType: A, QClass: IN}}}
+	if response, ok = legacyQuery(t, m, other); ok {
+		t.Errorf("answered a query for another host with %+v", response.Answers)
+	}
+}
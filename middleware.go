@@ -0,0 +1,60 @@
+package dnsclient
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ExchangeFunc answers a request, like Client.Exchange.
+type ExchangeFunc func(ctx context.Context, request *DnsRequest) (*DnsResponse, error)
+
+// Middleware wraps the resolve path of a client the way HTTP middleware
+// wraps a handler: it can look at or change the request, answer it itself,
+// or call next and look at or change what comes back.
+type Middleware func(next ExchangeFunc) ExchangeFunc
+
+// chain wraps exchange in middlewares, the first one outermost.
+func chain(exchange ExchangeFunc, middlewares []Middleware) ExchangeFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		exchange = middlewares[i](exchange)
+	}
+	return exchange
+}
+
+// LoggingMiddleware logs every query with its outcome and how long it took.
+func LoggingMiddleware(logger *log.Logger) Middleware {
+	return func(next ExchangeFunc) ExchangeFunc {
+		return func(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+			start := time.Now()
+			response, err := next(ctx, request)
+			q := request.Questions[0]
+			if err != nil {
+				logger.Printf("%s %s: %v (%s)", q.QName, TypeToString(q.QType), err, time.Since(start))
+			} else {
+				logger.Printf("%s %s: %s, %d answers from %s (%s)", q.QName, TypeToString(q.QType),
+					RCodeToString(response.Header.Flags.RCode()), len(response.Answers), response.Server, time.Since(start))
+			}
+			return response, err
+		}
+	}
+}
+
+// BlockingMiddleware answers queries for names on b with NXDOMAIN without
+// sending them anywhere.
+func BlockingMiddleware(b *Blocklist) Middleware {
+	return func(next ExchangeFunc) ExchangeFunc {
+		return func(ctx context.Context, request *DnsRequest) (*DnsResponse, error) {
+			reply, ok := b.Block(*request)
+			if !ok {
+				return next(ctx, request)
+			}
+			response, err := ReadResponse(reply)
+			if err != nil {
+				return nil, err
+			}
+			response.Server = "blocklist"
+			return &response, nil
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// UDPMux sends UDP queries to any server over a few shared sockets rather
+// than a socket per query, for clients sending many queries at once. Each
+// query gets a message id that is free for its server and question, and
+// replies are routed back to the query waiting for them by server, id and
+// question. A UDPMux can be shared by several clients, see WithUDPMux.
+type UDPMux struct {
+	conns []*muxConn
+	next  uint32
+}
+
+type muxKey struct {
+	addr     string
+	id       uint16
+	question DnsQuestion
+}
+
+type muxConn struct {
+	conn *net.UDPConn
+
+	mu      sync.Mutex
+	waiting map[muxKey]chan []byte
+	err     error
+}
+
+// ErrMuxClosed is returned for queries still waiting when a UDPMux closes.
+var ErrMuxClosed = errors.New("udp mux closed")
+
+// NewUDPMux opens sockets UDP sockets, at least one, to share.
+func NewUDPMux(sockets int) (*UDPMux, error) {
+	if sockets < 1 {
+		sockets = 1
+	}
+	m := &UDPMux{}
+	for i := 0; i < sockets; i++ {
+		conn, err := net.ListenUDP("udp", nil)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		c := &muxConn{conn: conn, waiting: make(map[muxKey]chan []byte)}
+		m.conns = append(m.conns, c)
+		go c.read()
+	}
+	return m, nil
+}
+
+// Close closes the sockets, failing the queries waiting on them.
+func (m *UDPMux) Close() error {
+	for _, c := range m.conns {
+		c.conn.Close()
+	}
+	return nil
+}
+
+// Exchange sends the query msg to server and waits for the reply until ctx
+// is done. The reply has the id msg was sent with.
+func (m *UDPMux) Exchange(ctx context.Context, server string, msg []byte) ([]byte, error) {
+	addr, err := udpAddr(server)
+	if err != nil {
+		return nil, err
+	}
+	request, err := ReadRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.Questions) != 1 {
+		return nil, ErrQuestionCount
+	}
+
+	c := m.conns[atomic.AddUint32(&m.next, 1)%uint32(len(m.conns))]
+	key, reply, err := c.wait(addr.String(), request.Questions[0])
+	if err != nil {
+		return nil, err
+	}
+	defer c.forget(key)
+
+	msg = append([]byte{}, msg...)
+	binary.BigEndian.PutUint16(msg, key.id)
+	if _, err := c.conn.WriteToUDP(msg, addr); err != nil {
+		return nil, err
+	}
+	select {
+	case r, ok := <-reply:
+		if !ok {
+			return nil, c.closedErr()
+		}
+		binary.BigEndian.PutUint16(r, request.Header.Id)
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// wait registers a query for question to addr under an id no other query
+// for them is using.
+func (c *muxConn) wait(addr string, question DnsQuestion) (muxKey, chan []byte, error) {
+	question.QName = strings.ToLower(strings.TrimSuffix(question.QName, "."))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return muxKey{}, nil, c.err
+	}
+	for i := 0; i < 100; i++ {
+		key := muxKey{addr, RandomID(), question}
+		if _, taken := c.waiting[key]; taken {
+			continue
+		}
+		ch := make(chan []byte, 1)
+		c.waiting[key] = ch
+		return key, ch, nil
+	}
+	return muxKey{}, nil, errors.New("no free message id for the query")
+}
+
+func (c *muxConn) forget(key muxKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.waiting, key)
+}
+
+func (c *muxConn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// read hands the datagrams arriving on the socket to the queries waiting
+// for them, dropping the ones nobody is waiting for.
+func (c *muxConn) read() {
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			c.mu.Lock()
+			c.err = ErrMuxClosed
+			for key, ch := range c.waiting {
+				close(ch)
+				delete(c.waiting, key)
+			}
+			c.mu.Unlock()
+			return
+		}
+		c.deliver(from.String(), append([]byte{}, buf[:n]...))
+	}
+}
+
+func (c *muxConn) deliver(addr string, msg []byte) {
+	r := bytes.NewReader(msg)
+	var header DnsHeader
+	if binary.Read(r, binary.BigEndian, &header) != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if header.QdCount == 1 {
+		question, err := ReadQuestion(r)
+		if err != nil {
+			return
+		}
+		question.QName = strings.ToLower(strings.TrimSuffix(question.QName, "."))
+		key := muxKey{addr, header.Id, question}
+		if ch, ok := c.waiting[key]; ok {
+			ch <- msg
+			delete(c.waiting, key)
+		}
+		return
+	}
+	// Servers that can't parse a query often leave the question out of the
+	// error, so the id is all there is to go by
+	if header.QdCount == 0 && header.Flags.RCode() != 0 {
+		for key, ch := range c.waiting {
+			if key.addr == addr && key.id == header.Id {
+				ch <- msg
+				delete(c.waiting, key)
+				return
+			}
+		}
+	}
+}
+
+// sendMux sends request over the client's UDPMux, waiting for the reply
+// for the read timeout or until ctx is done.
+func (c *Client) sendMux(ctx context.Context, server string, request DnsRequest) (DnsResponse, error) {
+	readCtx := ctx
+	if c.Timeouts.Read > 0 {
+		var cancel context.CancelFunc
+		readCtx, cancel = context.WithTimeout(ctx, c.Timeouts.Read)
+		defer cancel()
+	}
+	start := time.Now()
+	reply, err := c.UDPMux.Exchange(readCtx, server, SerializeRequest(request))
+	if ctx.Err() != nil {
+		return DnsResponse{}, ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return DnsResponse{}, &TimeoutError{Phase: "read", Server: server, After: c.Timeouts.Read}
+	}
+	if err != nil {
+		return DnsResponse{}, err
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		return response, err
+	}
+	if !MatchesRequest(response, request) {
+		return response, errors.New("response does not match the request")
+	}
+	response.Server = server
+	response.RTT = time.Since(start)
+	response.Transport = "udp"
+	return response, nil
+}
@@ -0,0 +1,130 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+// reverseServer collects n queries and answers them last first, each with
+// an A record holding the index the query arrived at. Before the answers it
+// sends a stray one nobody asked for and one with a wrong question.
+func reverseServer(t *testing.T, n int) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		type query struct {
+			request DnsRequest
+			addr    net.Addr
+		}
+		var queries []query
+		buf := make([]byte, 512)
+		for len(queries) < n {
+			size, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			request, err := ReadRequest(buf[:size])
+			if err != nil {
+				return
+			}
+			queries = append(queries, query{request, addr})
+		}
+		first := queries[0]
+		stray := answerA("stray.example", 60)
+		stray.Header.Id = first.request.Header.Id + 1
+		conn.WriteTo(SerializeResponse(stray), first.addr)
+		wrong := answerA("wrong.example", 60)
+		wrong.Header.Id = first.request.Header.Id
+		conn.WriteTo(SerializeResponse(wrong), first.addr)
+
+		for i := len(queries) - 1; i >= 0; i-- {
+			q := queries[i]
+			reply := answerA(q.request.Questions[0].QName, 60)
+			reply.Header.Id = q.request.Header.Id
+			reply.Answers[0].RData = []byte{192, 0, 2, byte(i)}
+			conn.WriteTo(SerializeResponse(reply), q.addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUDPMuxRouting(t *testing.T) {
+	const n = 8
+	server := reverseServer(t, n)
+	mux, err := NewUDPMux(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mux.Close()
+
+	// Every query has the same id and question, only the mux's own ids
+	// tell them apart
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	seen := make(map[byte]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			query := NewQuery("example.com", A).SetId(0x4242)
+			reply, err := mux.Exchange(ctx, server, SerializeRequest(*query))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			response, err := ReadResponse(reply)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !MatchesRequest(response, *query) || len(response.Answers) != 1 {
+				t.Errorf("reply %+v does not answer the query", response)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			index := response.Answers[0].RData[3]
+			if seen[index] {
+				t.Errorf("answer %d delivered twice", index)
+			}
+			seen[index] = true
+		}()
+	}
+	wg.Wait()
+	if len(seen) != n {
+		t.Errorf("%d distinct answers for %d queries", len(seen), n)
+	}
+}
+
+func TestUDPMuxClose(t *testing.T) {
+	mux, err := NewUDPMux(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := dnstest.DeadServer(t)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := mux.Exchange(context.Background(), server, SerializeRequest(*NewQuery("example.com", A)))
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mux.Close()
+	select {
+	case err := <-errs:
+		if err != ErrMuxClosed {
+			t.Errorf("Exchange() = %v, want %v", err, ErrMuxClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exchange() still waiting after Close")
+	}
+}
@@ -0,0 +1,68 @@
+package dnsclient
+
+import (
+	"strings"
+)
+
+// Name is a domain name in presentation form, with or without the trailing
+// dot. The methods ignore case and the trailing dot, so "Example.COM." and
+// "example.com" are the same name. The root is "" or ".".
+type Name string
+
+// FQDN returns the name with a trailing dot, "." for the root.
+func (n Name) FQDN() string {
+	return strings.TrimSuffix(string(n), ".") + "."
+}
+
+// Canonical returns the name lowercased and without the trailing dot, the
+// way names read from messages are stored.
+func (n Name) Canonical() Name {
+	return Name(strings.TrimSuffix(strings.ToLower(string(n)), "."))
+}
+
+func (n Name) IsRoot() bool {
+	return n == "" || n == "."
+}
+
+// Labels splits the name into its labels, keeping their case. The root has
+// none.
+func (n Name) Labels() []string {
+	if n.IsRoot() {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(n), "."), ".")
+}
+
+func (n Name) CountLabels() int {
+	return len(n.Labels())
+}
+
+// Parent returns the name with its first label removed. The parent of the
+// root is the root.
+func (n Name) Parent() Name {
+	labels := n.Labels()
+	if len(labels) <= 1 {
+		return ""
+	}
+	return Name(strings.Join(labels[1:], "."))
+}
+
+// Equal compares names case-insensitively.
+func (n Name) Equal(other Name) bool {
+	return CompareNames(string(n), string(other)) == 0
+}
+
+// IsSubdomainOf reports whether n is zone or a name below it. Every name is
+// a subdomain of the root.
+func (n Name) IsSubdomainOf(zone Name) bool {
+	labels, zoneLabels := nameLabels(string(n)), nameLabels(string(zone))
+	if len(labels) < len(zoneLabels) {
+		return false
+	}
+	for i := range zoneLabels {
+		if labels[len(labels)-1-i] != zoneLabels[len(zoneLabels)-1-i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,418 @@
+package dnsclient
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	NSEC       = 47
+	NSEC3      = 50
+	NSEC3PARAM = 51
+)
+
+// RFC 9276 recommends treating NSEC3 records with more iterations than this
+// as insecure; it also bounds the work an attacker can make us do.
+const MaxNSEC3Iterations = 150
+
+// NSEC3 opt-out flag (RFC 5155 section 3.1.2.1)
+const NSEC3OptOut = 0x01
+
+type NSECRecord struct {
+	NextDomain string
+	Types      []uint16
+}
+
+type NSEC3Record struct {
+	HashAlgorithm uint8
+	Flags         uint8
+	Iterations    uint16
+	Salt          []byte
+	NextHashed    []byte
+	Types         []uint16
+}
+
+var base32Hex = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+func ParseNSEC(rdata []byte) (NSECRecord, error) {
+	var nsec NSECRecord
+	r := bytes.NewReader(rdata)
+	next, err := ReadName(r)
+	if err != nil {
+		return nsec, err
+	}
+	nsec.NextDomain = next
+	nsec.Types, err = ParseTypeBitmap(rdata[len(rdata)-r.Len():])
+	return nsec, err
+}
+
+func ParseNSEC3(rdata []byte) (NSEC3Record, error) {
+	var nsec3 NSEC3Record
+	if len(rdata) < 5 {
+		return nsec3, errors.New("nsec3 rdata too short")
+	}
+	nsec3.HashAlgorithm = rdata[0]
+	nsec3.Flags = rdata[1]
+	nsec3.Iterations = binary.BigEndian.Uint16(rdata[2:])
+	saltLen := int(rdata[4])
+	rdata = rdata[5:]
+	if len(rdata) < saltLen+1 {
+		return nsec3, errors.New("nsec3 rdata too short")
+	}
+	nsec3.Salt = rdata[:saltLen]
+	rdata = rdata[saltLen:]
+	hashLen := int(rdata[0])
+	rdata = rdata[1:]
+	if len(rdata) < hashLen {
+		return nsec3, errors.New("nsec3 rdata too short")
+	}
+	nsec3.NextHashed = rdata[:hashLen]
+	var err error
+	nsec3.Types, err = ParseTypeBitmap(rdata[hashLen:])
+	return nsec3, err
+}
+
+// ParseTypeBitmap decodes the window/bitmap type list used by NSEC, NSEC3
+// and CSYNC (RFC 4034 section 4.1.2).
+func ParseTypeBitmap(data []byte) ([]uint16, error) {
+	var types []uint16
+	for len(data) > 0 {
+		if len(data) < 2 {
+			return types, errors.New("truncated type bitmap")
+		}
+		window, length := int(data[0]), int(data[1])
+		data = data[2:]
+		if length == 0 || length > 32 || len(data) < length {
+			return types, errors.New("invalid type bitmap")
+		}
+		for i, b := range data[:length] {
+			for bit := 0; bit < 8; bit++ {
+				if b&(0x80>>bit) != 0 {
+					types = append(types, uint16(window<<8|i*8+bit))
+				}
+			}
+		}
+		data = data[length:]
+	}
+	return types, nil
+}
+
+func formatTypes(types []uint16) string {
+	var names []string
+	for _, t := range types {
+		names = append(names, TypeToString(t))
+	}
+	return strings.Join(names, " ")
+}
+
+func hasType(types []uint16, t uint16) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NSEC3Hash computes the hashed owner name for name (RFC 5155 section 5).
+func NSEC3Hash(name string, salt []byte, iterations uint16) []byte {
+	wire := SerializeName(strings.ToLower(name))
+	h := sha1.Sum(append(wire, salt...))
+	for i := 0; i < int(iterations); i++ {
+		h = sha1.Sum(append(h[:], salt...))
+	}
+	return h[:]
+}
+
+func init() {
+	RegisterType(NSEC, RRType{
+		Format: func(rdata []byte) string {
+			nsec, err := ParseNSEC(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return strings.TrimSpace(nsec.NextDomain + ". " + formatTypes(nsec.Types))
+		},
+	})
+	RegisterType(NSEC3, RRType{
+		Format: func(rdata []byte) string {
+			n, err := ParseNSEC3(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			salt := "-"
+			if len(n.Salt) > 0 {
+				salt = fmt.Sprintf("%X", n.Salt)
+			}
+			return strings.TrimSpace(fmt.Sprintf("%d %d %d %s %s %s", n.HashAlgorithm, n.Flags, n.Iterations, salt, strings.ToLower(base32Hex.EncodeToString(n.NextHashed)), formatTypes(n.Types)))
+		},
+	})
+}
+
+// DenialResult is what a denial of existence proof established.
+type DenialResult int
+
+const (
+	DenialNXDomain DenialResult = iota + 1
+	DenialNoData
+	// The name may be an unsigned delegation covered by an NSEC3 opt-out span
+	DenialOptOut
+)
+
+func (d DenialResult) String() string {
+	switch d {
+	case DenialNXDomain:
+		return "NXDOMAIN proven"
+	case DenialNoData:
+		return "NODATA proven"
+	case DenialOptOut:
+		return "insecure delegation (NSEC3 opt-out)"
+	}
+	return "unknown"
+}
+
+// VerifyDenial checks that the NSEC or NSEC3 records in the authority section
+// of a negative response prove it: that the name does not exist for
+// NXDOMAIN, or that it has no records of the queried type for NODATA. This
+// only checks the proof itself; the signatures over the NSEC/NSEC3 records
+// must be validated separately.
+func VerifyDenial(response DnsResponse) (DenialResult, error) {
+	if len(response.Questions) != 1 {
+		return 0, errors.New("denial proofs need exactly one question")
+	}
+	q := response.Questions[0]
+	nxdomain := response.Header.Flags.RCode() == 3
+	if !nxdomain && (response.Header.Flags.RCode() != 0 || len(response.Answers) > 0) {
+		return 0, errors.New("response is not a negative answer")
+	}
+
+	var nsecs []DnsResourceRecord
+	var nsec3s []DnsResourceRecord
+	for _, a := range response.Authorities {
+		switch a.Type {
+		case NSEC:
+			nsecs = append(nsecs, a)
+		case NSEC3:
+			nsec3s = append(nsec3s, a)
+		}
+	}
+	switch {
+	case len(nsecs) > 0:
+		return verifyNSEC(q, nxdomain, nsecs)
+	case len(nsec3s) > 0:
+		return verifyNSEC3(q, nxdomain, nsec3s)
+	}
+	return 0, errors.New("no NSEC or NSEC3 records in the authority section")
+}
+
+// nsecCovers reports whether name falls strictly between the owner and next
+// name of an NSEC record. The last NSEC of a zone points back at the apex.
+func nsecCovers(owner string, next string, name string) bool {
+	if CompareNames(owner, name) >= 0 {
+		return false
+	}
+	return CompareNames(name, next) < 0 || CompareNames(owner, next) >= 0
+}
+
+// commonAncestor returns the longest name that both a and b are equal to or below.
+func commonAncestor(a, b string) string {
+	ancestor := Name(a).Canonical()
+	for !Name(b).IsSubdomainOf(ancestor) {
+		ancestor = ancestor.Parent()
+	}
+	return string(ancestor)
+}
+
+func wildcardOf(name string) string {
+	if name == "" {
+		return "*"
+	}
+	return "*." + name
+}
+
+func verifyNSEC(q DnsQuestion, nxdomain bool, records []DnsResourceRecord) (DenialResult, error) {
+	type nsec struct {
+		owner string
+		NSECRecord
+	}
+	var nsecs []nsec
+	for _, r := range records {
+		n, err := ParseNSEC(r.RData)
+		if err != nil {
+			return 0, err
+		}
+		nsecs = append(nsecs, nsec{r.Name, n})
+	}
+
+	// NODATA: an NSEC at the name itself without the type (RFC 4035 3.1.3.1)
+	for _, n := range nsecs {
+		if CompareNames(n.owner, q.QName) == 0 {
+			if nxdomain {
+				return 0, fmt.Errorf("NSEC shows %s exists", q.QName)
+			}
+			if hasType(n.Types, q.QType) || hasType(n.Types, CNAME) {
+				return 0, fmt.Errorf("NSEC shows %s has type %s", q.QName, TypeToString(q.QType))
+			}
+			return DenialNoData, nil
+		}
+	}
+
+	// Otherwise the name must be covered, and so must the wildcard at its
+	// closest encloser (RFC 4035 3.1.3.2), or for a wildcard NODATA the
+	// wildcard must exist without the type (RFC 4035 3.1.3.4)
+	var closestEncloser string
+	covered := false
+	for _, n := range nsecs {
+		if nsecCovers(n.owner, n.NextDomain, q.QName) {
+			covered = true
+			closestEncloser = commonAncestor(q.QName, n.owner)
+			if ce := commonAncestor(q.QName, n.NextDomain); len(ce) > len(closestEncloser) {
+				closestEncloser = ce
+			}
+		}
+	}
+	if !covered {
+		return 0, fmt.Errorf("no NSEC covers %s", q.QName)
+	}
+
+	wildcard := wildcardOf(closestEncloser)
+	for _, n := range nsecs {
+		if CompareNames(n.owner, wildcard) == 0 {
+			if nxdomain {
+				return 0, fmt.Errorf("NSEC shows wildcard %s exists", wildcard)
+			}
+			if hasType(n.Types, q.QType) || hasType(n.Types, CNAME) {
+				return 0, fmt.Errorf("NSEC shows wildcard %s has type %s", wildcard, TypeToString(q.QType))
+			}
+			return DenialNoData, nil
+		}
+		if nsecCovers(n.owner, n.NextDomain, wildcard) {
+			if !nxdomain {
+				return 0, fmt.Errorf("NSEC shows %s does not exist, expected NXDOMAIN", q.QName)
+			}
+			return DenialNXDomain, nil
+		}
+	}
+	return 0, fmt.Errorf("no NSEC proves wildcard %s does not exist", wildcard)
+}
+
+type nsec3 struct {
+	hash []byte
+	NSEC3Record
+}
+
+func (n nsec3) matches(hash []byte) bool {
+	return bytes.Equal(n.hash, hash)
+}
+
+func (n nsec3) covers(hash []byte) bool {
+	if bytes.Compare(n.hash, n.NextHashed) < 0 {
+		return bytes.Compare(n.hash, hash) < 0 && bytes.Compare(hash, n.NextHashed) < 0
+	}
+	// The last NSEC3 in the hash order wraps around to the first
+	return bytes.Compare(n.hash, hash) < 0 || bytes.Compare(hash, n.NextHashed) < 0
+}
+
+func verifyNSEC3(q DnsQuestion, nxdomain bool, records []DnsResourceRecord) (DenialResult, error) {
+	var nsec3s []nsec3
+	for _, r := range records {
+		n, err := ParseNSEC3(r.RData)
+		if err != nil {
+			return 0, err
+		}
+		if n.HashAlgorithm != 1 {
+			return 0, fmt.Errorf("unsupported NSEC3 hash algorithm %d", n.HashAlgorithm)
+		}
+		if n.Iterations > MaxNSEC3Iterations {
+			return 0, fmt.Errorf("NSEC3 uses %d iterations, more than the %d allowed", n.Iterations, MaxNSEC3Iterations)
+		}
+		label := strings.SplitN(r.Name, ".", 2)[0]
+		hash, err := base32Hex.DecodeString(strings.ToUpper(label))
+		if err != nil {
+			return 0, fmt.Errorf("NSEC3 owner %s: %v", r.Name, err)
+		}
+		nsec3s = append(nsec3s, nsec3{hash, n})
+	}
+	// All NSEC3 records of a zone share the same parameters
+	params := nsec3s[0]
+	hashOf := func(name string) []byte {
+		return NSEC3Hash(name, params.Salt, params.Iterations)
+	}
+	find := func(f func(nsec3) bool) *nsec3 {
+		for i := range nsec3s {
+			if f(nsec3s[i]) {
+				return &nsec3s[i]
+			}
+		}
+		return nil
+	}
+
+	// NODATA: a matching NSEC3 without the type (RFC 5155 8.5 and 8.6)
+	qhash := hashOf(q.QName)
+	if m := find(func(n nsec3) bool { return n.matches(qhash) }); m != nil {
+		if nxdomain {
+			return 0, fmt.Errorf("NSEC3 shows %s exists", q.QName)
+		}
+		if hasType(m.Types, q.QType) || hasType(m.Types, CNAME) {
+			return 0, fmt.Errorf("NSEC3 shows %s has type %s", q.QName, TypeToString(q.QType))
+		}
+		return DenialNoData, nil
+	}
+
+	// Closest encloser proof (RFC 5155 8.3): the closest ancestor with a
+	// matching NSEC3, and an NSEC3 covering the next closer name below it
+	labels := nameLabels(q.QName)
+	closestEncloser, nextCloser := "", ""
+	found := false
+	for i := 1; i <= len(labels); i++ {
+		candidate := strings.Join(labels[i:], ".")
+		h := hashOf(candidate)
+		if find(func(n nsec3) bool { return n.matches(h) }) != nil {
+			closestEncloser, nextCloser = candidate, strings.Join(labels[i-1:], ".")
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("no NSEC3 proves a closest encloser for %s", q.QName)
+	}
+	nextHash := hashOf(nextCloser)
+	cover := find(func(n nsec3) bool { return n.covers(nextHash) })
+	if cover == nil {
+		return 0, fmt.Errorf("no NSEC3 covers next closer name %s", nextCloser)
+	}
+
+	wildcard := wildcardOf(closestEncloser)
+	whash := hashOf(wildcard)
+	if m := find(func(n nsec3) bool { return n.matches(whash) }); m != nil {
+		// Wildcard NODATA (RFC 5155 8.7)
+		if nxdomain {
+			return 0, fmt.Errorf("NSEC3 shows wildcard %s exists", wildcard)
+		}
+		if hasType(m.Types, q.QType) || hasType(m.Types, CNAME) {
+			return 0, fmt.Errorf("NSEC3 shows wildcard %s has type %s", wildcard, TypeToString(q.QType))
+		}
+		return DenialNoData, nil
+	}
+
+	if !nxdomain {
+		// NODATA for DS at an unsigned delegation inside an opt-out span (RFC 5155 8.6)
+		if q.QType == DS && cover.Flags&NSEC3OptOut != 0 {
+			return DenialOptOut, nil
+		}
+		return 0, fmt.Errorf("no NSEC3 matches %s", q.QName)
+	}
+
+	if find(func(n nsec3) bool { return n.covers(whash) }) == nil {
+		return 0, fmt.Errorf("no NSEC3 proves wildcard %s does not exist", wildcard)
+	}
+	if cover.Flags&NSEC3OptOut != 0 {
+		return DenialOptOut, nil
+	}
+	return DenialNXDomain, nil
+}
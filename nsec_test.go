@@ -0,0 +1,149 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNSEC3Hash(t *testing.T) {
+	// RFC 5155 appendix A
+	salt, _ := hex.DecodeString("aabbccdd")
+	tests := []struct {
+		name, want string
+	}{
+		{"example", "0p9mhaveqvm6t7vbl5lop2u3t2rp3tom"},
+		{"a.example", "35mthgpgcu1qg68fab165klnsnk3dpvl"},
+		{"ai.example", "gjeqe526plbf1g8mklp59enfd789njgi"},
+		{"ns1.example", "2t7b4g4vsa5smi47k61mv5bv1a22bojr"},
+		{"*.w.example", "r53bq7cc2uvmubfu5ocmm6pers9tk9en"},
+		{"x.w.example", "b4um86eghhds6nea196smvmlo4ors995"},
+		{"X.W.Example.", "b4um86eghhds6nea196smvmlo4ors995"},
+		{"x.y.w.example", "2vptu5timamqttgl4luu9kg21e0aor3s"},
+	}
+	for _, test := range tests {
+		got := strings.ToLower(base32Hex.EncodeToString(NSEC3Hash(test.name, salt, 12)))
+		if got != test.want {
+			t.Errorf("NSEC3Hash(%q) = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func negative(name string, qtype uint16, rcode uint16, authorities ...DnsResourceRecord) DnsResponse {
+	return DnsResponse{
+		Header:      DnsHeader{Flags: DnsFlags(0x8400 | rcode)},
+		Questions:   []DnsQuestion{{QName: name, QType: qtype, QClass: IN}},
+		Authorities: authorities,
+	}
+}
+
+func TestVerifyDenialNSEC(t *testing.T) {
+	nsec := func(owner, next string, types ...uint16) DnsResourceRecord {
+		return DnsResourceRecord{Name: owner, Type: NSEC, Class: IN, RData: nsecRData(next, types...)}
+	}
+	apex := nsec("example.com", "a.example.com", NS, SOA, RRSIG, NSEC)
+	a := nsec("a.example.com", "d.example.com", A, RRSIG, NSEC)
+	tests := []struct {
+		name     string
+		response DnsResponse
+		want     DenialResult
+		err      string
+	}{
+		{"nxdomain", negative("b.example.com", A, 3, apex, a), DenialNXDomain, ""},
+		{"nxdomain without the wildcard", negative("b.example.com", A, 3, a), 0, "wildcard"},
+		{"nodata", negative("a.example.com", MX, 0, a), DenialNoData, ""},
+		{"nodata with the type", negative("a.example.com", A, 0, a), 0, "has type"},
+		{"nxdomain for a name that exists", negative("a.example.com", A, 3, a), 0, "exists"},
+		{"not covered", negative("e.example.com", A, 3, apex, a), 0, "no NSEC covers"},
+		{"no records", negative("b.example.com", A, 3), 0, "no NSEC"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := VerifyDenial(test.response)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("VerifyDenial() = %v, %v, want error %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Fatalf("VerifyDenial() = %v, %v, want %v", got, err, test.want)
+			}
+		})
+	}
+}
+
+// nsec3Chain builds the NSEC3 chain of a zone with the given names and
+// their types.
+func nsec3Chain(zone string, names map[string][]uint16, flags uint8) []DnsResourceRecord {
+	salt := []byte{0xaa, 0xbb}
+	type entry struct {
+		hash  []byte
+		types []uint16
+	}
+	var entries []entry
+	for name, types := range names {
+		entries = append(entries, entry{NSEC3Hash(name, salt, 1), types})
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].hash, entries[j].hash) < 0 })
+	var records []DnsResourceRecord
+	for i, e := range entries {
+		next := entries[(i+1)%len(entries)].hash
+		rdata := []byte{1, flags, 0, 1, byte(len(salt))}
+		rdata = append(rdata, salt...)
+		rdata = append(rdata, byte(len(next)))
+		rdata = append(rdata, next...)
+		rdata = append(rdata, nsecRData("", e.types...)[1:]...)
+		owner := strings.ToLower(base32Hex.EncodeToString(e.hash)) + "." + zone
+		records = append(records, DnsResourceRecord{Name: owner, Type: NSEC3, Class: IN, RData: rdata})
+	}
+	return records
+}
+
+func TestVerifyDenialNSEC3(t *testing.T) {
+	chain := nsec3Chain("example", map[string][]uint16{
+		"example":     {NS, SOA, RRSIG, NSEC3PARAM},
+		"a.example":   {A, RRSIG},
+		"ns1.example": {A, RRSIG},
+	}, 0)
+	withWildcard := nsec3Chain("example", map[string][]uint16{
+		"example":   {NS, SOA, RRSIG, NSEC3PARAM},
+		"*.example": {TXT, RRSIG},
+	}, 0)
+	optOut := nsec3Chain("example", map[string][]uint16{
+		"example":   {NS, SOA, RRSIG, NSEC3PARAM},
+		"a.example": {A, RRSIG},
+	}, NSEC3OptOut)
+	tests := []struct {
+		name     string
+		response DnsResponse
+		want     DenialResult
+		err      string
+	}{
+		{"nxdomain", negative("nx.example", A, 3, chain...), DenialNXDomain, ""},
+		{"nxdomain below a missing name", negative("x.nx.example", A, 3, chain...), DenialNXDomain, ""},
+		{"nodata", negative("a.example", MX, 0, chain...), DenialNoData, ""},
+		{"nodata with the type", negative("a.example", A, 0, chain...), 0, "has type"},
+		{"nxdomain for a name that exists", negative("a.example", A, 3, chain...), 0, "exists"},
+		{"wildcard nodata", negative("nx.example", A, 0, withWildcard...), DenialNoData, ""},
+		{"nxdomain under a wildcard", negative("nx.example", A, 3, withWildcard...), 0, "wildcard"},
+		{"opt-out", negative("nx.example", A, 3, optOut...), DenialOptOut, ""},
+		{"no closest encloser", negative("nx.example", A, 3, chain[:0]...), 0, "no NSEC"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := VerifyDenial(test.response)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("VerifyDenial() = %v, %v, want error %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Fatalf("VerifyDenial() = %v, %v, want %v", got, err, test.want)
+			}
+		})
+	}
+}
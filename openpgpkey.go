@@ -0,0 +1,56 @@
+package dnsclient
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const OPENPGPKEY = 61
+
+func init() {
+	RegisterType(OPENPGPKEY, RRType{
+		Format: func(rdata []byte) string {
+			return base64.StdEncoding.EncodeToString(rdata)
+		},
+	})
+}
+
+// emailOwnerName builds the owner name used by OPENPGPKEY and SMIMEA for an
+// email address: the SHA-256 of the local part truncated to 28 octets, the
+// service label and the domain (RFC 7929 section 3, RFC 8162 section 3).
+// The local part is hashed as is, without changing case.
+func emailOwnerName(email, service string) (string, error) {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return "", fmt.Errorf("invalid email address %q", email)
+	}
+	h := sha256.Sum256([]byte(email[:at]))
+	return hex.EncodeToString(h[:28]) + "." + service + "." + ToASCII(email[at+1:]), nil
+}
+
+// OpenPGPKeyName returns the name of the OPENPGPKEY records for email.
+func OpenPGPKeyName(email string) (string, error) {
+	return emailOwnerName(email, "_openpgpkey")
+}
+
+// LookupOpenPGPKey returns the OpenPGP transferable public keys published
+// for email.
+func (c *Client) LookupOpenPGPKey(ctx context.Context, email string) ([][]byte, error) {
+	name, err := OpenPGPKeyName(email)
+	if err != nil {
+		return nil, err
+	}
+	records, err := c.lookup(ctx, name, OPENPGPKEY)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	for _, r := range records {
+		keys = append(keys, r.RData)
+	}
+	return keys, nil
+}
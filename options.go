@@ -0,0 +1,181 @@
+package dnsclient
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// Transports for WithTransport. DoT and DoH are chosen per server with the
+// "tls:" and "https://" prefixes.
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+)
+
+// Option changes a setting of a Client made with NewClient.
+type Option func(*Client)
+
+// NewClient returns a client for the default server with EDNS, 5 second
+// timeouts and 3 attempts, changed by opts:
+//
+//	client := NewClient(WithServers("1.1.1.1", "8.8.8.8"), WithTimeout(2*time.Second), WithDNSSEC())
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		Servers:  DefaultConfig().Servers,
+		UDPSize:  DefaultUDPSize,
+		Attempts: 3,
+	}
+	WithTimeout(5 * time.Second)(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func WithServers(servers ...string) Option {
+	return func(c *Client) { c.Servers = servers }
+}
+
+// withServers returns opts with WithServers(servers...) added, in a new
+// slice so goroutines can each add their own servers to the same opts.
+func withServers(opts []Option, servers ...string) []Option {
+	return append(append([]Option{}, opts...), WithServers(servers...))
+}
+
+// WithTransport sends queries to plain DNS servers over TransportUDP or
+// TransportTCP.
+func WithTransport(transport string) Option {
+	return func(c *Client) { c.TCP = transport == TransportTCP }
+}
+
+// WithServerTransport sends the queries for server through t, which is how
+// a custom Transport is plugged in. server is added to the servers if it
+// isn't one of them.
+func WithServerTransport(server string, t Transport) Option {
+	return func(c *Client) {
+		if c.Transports == nil {
+			c.Transports = make(map[string]Transport)
+		}
+		c.Transports[server] = t
+		for _, s := range c.Servers {
+			if s == server {
+				return
+			}
+		}
+		c.Servers = append(c.Servers[:len(c.Servers):len(c.Servers)], server)
+	}
+}
+
+// WithUDPMux sends plain UDP queries over the shared sockets of m, which
+// the client doesn't close.
+func WithUDPMux(m *UDPMux) Option {
+	return func(c *Client) { c.UDPMux = m }
+}
+
+// WithMiddleware adds middlewares around the client's resolve path, after
+// any added before. The first one added sees queries first and responses
+// last:
+//
+//	client := NewClient(WithMiddleware(LoggingMiddleware(log.Default()), BlockingMiddleware(blocklist)))
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(c *Client) { c.Middleware = append(c.Middleware, middlewares...) }
+}
+
+// WithOnSend calls f with every query the client sends, see Client.OnSend.
+func WithOnSend(f func(server string, msg []byte, request *DnsRequest)) Option {
+	return func(c *Client) { c.OnSend = f }
+}
+
+// WithOnReceive calls f with every response the client receives, see
+// Client.OnReceive.
+func WithOnReceive(f func(server string, msg []byte, response *DnsResponse)) Option {
+	return func(c *Client) { c.OnReceive = f }
+}
+
+// WithTimeout limits every phase of a query to d, see Timeouts.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.Timeouts = Timeouts{Dial: d, Handshake: d, Write: d, Read: d} }
+}
+
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(c *Client) { c.Timeouts = timeouts }
+}
+
+// WithEDNS sets the EDNS UDP payload size to advertise, 0 turns EDNS off.
+func WithEDNS(udpSize uint16) Option {
+	return func(c *Client) { c.UDPSize = udpSize }
+}
+
+// WithEDNSOptions adds opts to the OPT record of every query sent with
+// EDNS, unless the query has an option with the same code already.
+func WithEDNSOptions(opts ...EDNSOption) Option {
+	return func(c *Client) { c.EDNSOptions = opts }
+}
+
+// WithDNSSEC sets the DO bit on queries.
+func WithDNSSEC() Option {
+	return func(c *Client) { c.DNSSEC = true }
+}
+
+func WithAttempts(attempts int) Option {
+	return func(c *Client) { c.Attempts = attempts }
+}
+
+// WithRateLimit limits the queries sent to each server, e.g. to 10 a second
+// with WithRateLimit(NewRateLimiter(10)). Clients can share a limiter.
+func WithRateLimit(limiter *RateLimiter) Option {
+	return func(c *Client) { c.RateLimit = limiter }
+}
+
+// WithBudget limits each lookup to d in all, see Client.Budget.
+func WithBudget(d time.Duration) Option {
+	return func(c *Client) { c.Budget = d }
+}
+
+// WithRace sends queries to all servers at once, cancelling the rest when
+// one answers.
+func WithRace() Option {
+	return func(c *Client) { c.Race = true }
+}
+
+// WithFastestFirst tries the servers with the best RTT first.
+func WithFastestFirst() Option {
+	return func(c *Client) { c.SelectByRTT = true }
+}
+
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) { c.TLSConfig = config }
+}
+
+func WithSPKIPins(pins ...string) Option {
+	return func(c *Client) { c.SPKIPins = pins }
+}
+
+func WithHTTPVersion(version string) Option {
+	return func(c *Client) { c.HTTPVersion = version }
+}
+
+// WithDoHGet sends DoH queries with GET.
+func WithDoHGet() Option {
+	return func(c *Client) { c.DoHGet = true }
+}
+
+// WithBootstrap sets how the addresses of DoT and DoH servers given by name
+// are found, see Client.BootstrapHosts.
+func WithBootstrap(hosts map[string][]net.IP, servers ...string) Option {
+	return func(c *Client) {
+		c.BootstrapHosts = hosts
+		c.BootstrapServers = servers
+	}
+}
+
+// WithPrivacy sets the privacy profile, PrivacyStrict or PrivacyOpportunistic.
+func WithPrivacy(profile string) Option {
+	return func(c *Client) { c.Privacy = profile }
+}
+
+// WithCache keeps answers in cache, which may be shared between clients.
+func WithCache(cache Cache) Option {
+	return func(c *Client) { c.Cache = cache }
+}
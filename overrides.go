@@ -0,0 +1,133 @@
+package dnsclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultOverrideTTL is the TTL of override records that don't give one,
+// such as hosts file entries.
+const DefaultOverrideTTL = 300
+
+// Overrides are local records a forwarder answers with authoritatively,
+// before looking at its cache or asking upstream. A name with overrides
+// only has those: other types get an empty answer.
+type Overrides struct {
+	records map[string][]DnsResourceRecord
+}
+
+func NewOverrides() *Overrides {
+	return &Overrides{records: make(map[string][]DnsResourceRecord)}
+}
+
+// Add adds a record.
+func (o *Overrides) Add(r DnsResourceRecord) {
+	key := string(Name(r.Name).Canonical())
+	o.records[key] = append(o.records[key], r)
+}
+
+// Load reads hosts file lines ("10.0.0.5 nas nas.home") and zone file
+// records ("printer.home. 60 IN A 10.0.0.9") from r, in any mix, with
+// $TTL setting the TTL of the zone records after it. Hosts entries also
+// get the PTR record of their address, for the first name.
+func (o *Overrides) Load(r io.Reader) error {
+	ttl := uint32(DefaultOverrideTTL)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+		if ip := net.ParseIP(fields[0]); ip != nil {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				fields = strings.Fields(line[:i])
+			}
+			err := o.addHost(ip, fields[1:])
+			if err != nil {
+				return fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			continue
+		}
+		if strings.EqualFold(fields[0], "$TTL") {
+			var n uint64
+			var err error
+			if len(fields) > 1 {
+				n, err = strconv.ParseUint(fields[1], 10, 31)
+			}
+			if len(fields) < 2 || err != nil {
+				return fmt.Errorf("line %d: invalid $TTL", lineNo)
+			}
+			ttl = uint32(n)
+			continue
+		}
+		r, err := ParseRR(line, ttl)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		o.Add(r)
+	}
+	return scanner.Err()
+}
+
+func (o *Overrides) addHost(ip net.IP, names []string) error {
+	if len(names) == 0 {
+		return fmt.Errorf("no names for %s", ip)
+	}
+	t, rdata := uint16(A), []byte(ip.To4())
+	if rdata == nil {
+		t, rdata = AAAA, ip.To16()
+	}
+	for _, name := range names {
+		o.Add(DnsResourceRecord{Name: ZoneName(name), Type: t, Class: IN, TTL: DefaultOverrideTTL, RDLength: uint16(len(rdata)), RData: rdata})
+	}
+	reverse, err := ReverseName(ip.String())
+	if err != nil {
+		return err
+	}
+	target := ZoneName(names[0])
+	o.Add(DnsResourceRecord{Name: reverse, Type: PTR, Class: IN, TTL: DefaultOverrideTTL, RDLength: uint16(len(SerializeName(target))), RData: []byte(target)})
+	return nil
+}
+
+// LoadFile loads the overrides in the file at path.
+func (o *Overrides) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = o.Load(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// Answer returns the reply to request if its name has overrides: the
+// records of the type asked for, or the CNAME of the name, with AA set.
+func (o *Overrides) Answer(request DnsRequest) ([]byte, bool) {
+	if len(request.Questions) != 1 {
+		return nil, false
+	}
+	q := request.Questions[0]
+	records, ok := o.records[string(Name(q.QName).Canonical())]
+	if !ok {
+		return nil, false
+	}
+	response := DnsResponse{Header: request.Header, Questions: request.Questions}
+	response.Header.Flags = request.Header.Flags&0x7910 | 0x8080 | FlagAA
+	for _, r := range records {
+		if r.Class == q.QClass && (r.Type == q.QType || q.QType == ANY || r.Type == CNAME) {
+			// The owner name is written as asked
+			r.Name = q.QName
+			response.Answers = append(response.Answers, r)
+		}
+	}
+	return SerializeResponse(response), true
+}
@@ -0,0 +1,87 @@
+package dnsclient
+
+import (
+	"context"
+	"time"
+)
+
+// PingProbe is the outcome of one query sent by Ping.
+type PingProbe struct {
+	Seq      int
+	RTT      time.Duration
+	Response *DnsResponse
+	Err      error
+}
+
+// PingStats sums up the probes of a Ping. Jitter is the mean difference
+// between the RTTs of consecutive answers, as RFC 3550 measures it.
+type PingStats struct {
+	Sent, Received int
+	Min, Avg, Max  time.Duration
+	Jitter         time.Duration
+}
+
+// Loss is the percentage of probes that got no answer.
+func (s PingStats) Loss() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+	return 100 * float64(s.Sent-s.Received) / float64(s.Sent)
+}
+
+// Ping sends the query for name and qtype through client count times, or
+// until ctx is done if count is 0, one every interval, calling probe with
+// the outcome of each. Whatever the client does to get an answer counts as
+// one probe, so it should have one server and not retry.
+func Ping(ctx context.Context, client *Client, name string, qtype uint16, count int, interval time.Duration, probe func(PingProbe)) PingStats {
+	var stats PingStats
+	var total, deviation time.Duration
+	var last time.Duration
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for seq := 0; count == 0 || seq < count; seq++ {
+		if seq > 0 {
+			select {
+			case <-ctx.Done():
+				return stats
+			case <-ticker.C:
+			}
+		}
+		request := NewQuery(name, qtype).SetEDNS(client.UDPSize)
+		start := time.Now()
+		response, err := client.Exchange(ctx, request)
+		if ctx.Err() != nil {
+			// Interrupted, not lost
+			return stats
+		}
+		p := PingProbe{Seq: seq, RTT: time.Since(start), Response: response, Err: err}
+		stats.Sent++
+		if err == nil {
+			if response.RTT > 0 {
+				p.RTT = response.RTT
+			}
+			if stats.Received == 0 || p.RTT < stats.Min {
+				stats.Min = p.RTT
+			}
+			if p.RTT > stats.Max {
+				stats.Max = p.RTT
+			}
+			if stats.Received > 0 {
+				d := p.RTT - last
+				if d < 0 {
+					d = -d
+				}
+				deviation += d
+				stats.Jitter = deviation / time.Duration(stats.Received)
+			}
+			last = p.RTT
+			stats.Received++
+			total += p.RTT
+			stats.Avg = total / time.Duration(stats.Received)
+		}
+		if probe != nil {
+			probe(p)
+		}
+	}
+	return stats
+}
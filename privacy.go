@@ -0,0 +1,59 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Usage profiles for DoT and DoH (RFC 8310 section 5), see Client.Privacy.
+const (
+	PrivacyNone          = ""
+	PrivacyStrict        = "strict"
+	PrivacyOpportunistic = "opportunistic"
+)
+
+// sendOpportunistic tries server with an authenticated TLS connection, then
+// an unauthenticated one, and then falls back to plain DNS over UDP on port
+// 53 of the same host. Response.Server and Response.Authenticated tell which
+// of them answered.
+func (c *Client) sendOpportunistic(ctx context.Context, server string, request DnsRequest) (DnsResponse, error) {
+	response, err := c.sendEncrypted(ctx, server, request, true)
+	if err == nil || ctx.Err() != nil {
+		return response, err
+	}
+	response, err = c.sendEncrypted(ctx, server, request, false)
+	if err == nil || ctx.Err() != nil {
+		return response, err
+	}
+	return sendRequest(ctx, cleartextAddr(server), request, c.Timeouts)
+}
+
+// cleartextAddr is the plain DNS address on the same host as a DoT or DoH
+// server.
+func cleartextAddr(server string) string {
+	host := server
+	if strings.HasPrefix(server, "https://") {
+		if u, err := url.Parse(server); err == nil {
+			host = u.Hostname()
+		}
+	} else {
+		addr, _ := DoTAddr(server)
+		host, _, _ = net.SplitHostPort(addr)
+	}
+	return net.JoinHostPort(host, "53")
+}
+
+// PrivacyStatus describes how a response was protected on the way: which
+// server answered and whether it was over authenticated TLS, unauthenticated
+// TLS or in cleartext.
+func PrivacyStatus(response DnsResponse) string {
+	switch {
+	case !strings.HasPrefix(response.Server, "tls:") && !strings.HasPrefix(response.Server, "https://"):
+		return response.Server + ", cleartext"
+	case response.Authenticated:
+		return response.Server + ", encrypted and authenticated"
+	}
+	return response.Server + ", encrypted but not authenticated"
+}
@@ -0,0 +1,100 @@
+package dnsclient
+
+import (
+	"context"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket per key (a server or a client address):
+// Rate tokens a second, up to Burst saved up.
+type RateLimiter struct {
+	Rate  float64
+	Burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// maxBuckets is how many keys are tracked before buckets that have filled up
+// again are forgotten.
+const maxBuckets = 10000
+
+// NewRateLimiter allows rate queries a second per key, with bursts of as many
+// as one second's worth.
+func NewRateLimiter(rate float64) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: math.Max(1, math.Ceil(rate)), buckets: make(map[string]*bucket)}
+}
+
+// take takes a token for key and returns how long to wait until it may be
+// used, 0 if one is available now. Unless wait is set no token is taken if
+// none is available, and ok is false.
+func (l *RateLimiter) take(key string, now time.Time, wait bool) (d time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.Burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.Burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens < 1 && !wait {
+		return 0, false
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-b.tokens / l.Rate * float64(time.Second)), true
+}
+
+func (l *RateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.Rate >= l.Burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Allow takes a token for key if one is available now.
+func (l *RateLimiter) Allow(key string) bool {
+	_, ok := l.take(key, time.Now(), false)
+	return ok
+}
+
+// Wait blocks until key may send, or ctx is done.
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	d, _ := l.take(key, time.Now(), true)
+	if d == 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addrKey is the host part of a client address, so all of a client's
+// connections share one bucket.
+func addrKey(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,136 @@
+package dnsclient
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRedisPrefix is put in front of the keys RedisCache uses.
+const DefaultRedisPrefix = "dns-client:"
+
+// RedisCache keeps responses in a Redis server, so several clients or
+// forwarders can share them. Entries expire in Redis after the TTL CacheTTL
+// gives, each holds the time it was stored and the response in wire format.
+// It speaks just enough RESP for GET and SET over a single connection,
+// reconnecting after errors.
+type RedisCache struct {
+	Addr    string
+	Prefix  string        // DefaultRedisPrefix if empty
+	Timeout time.Duration // per command, 1 second if 0
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *RedisCache) key(request DnsRequest) string {
+	k := newCacheKey(request)
+	prefix := c.Prefix
+	if prefix == "" {
+		prefix = DefaultRedisPrefix
+	}
+	return fmt.Sprintf("%s%s/%d/%d/%t/%t/%t", prefix, k.name, k.qtype, k.qclass, k.do, k.cd, k.rd)
+}
+
+func (c *RedisCache) Get(request DnsRequest) (*DnsResponse, bool) {
+	value, err := c.command("GET", c.key(request))
+	if err != nil || len(value) < 8 {
+		return nil, false
+	}
+	response, err := ReadResponse(value[8:])
+	if err != nil {
+		return nil, false
+	}
+	age := time.Since(time.Unix(0, int64(binary.BigEndian.Uint64(value))))
+	e := cacheEntry{response: response}
+	response = e.aged(age)
+	response.Header.Id = request.Header.Id
+	return &response, true
+}
+
+func (c *RedisCache) Put(request DnsRequest, response DnsResponse) {
+	ttl, ok := CacheTTL(response)
+	if !ok {
+		return
+	}
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, uint64(time.Now().UnixNano()))
+	value = append(value, SerializeResponse(response)...)
+	c.command("SET", c.key(request), string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+}
+
+// command sends a command and returns the reply if it is a string, nil for
+// a nil reply.
+func (c *RedisCache) command(args ...string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.roundTrip(args)
+	if err != nil && c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	return reply, err
+}
+
+func (c *RedisCache) roundTrip(args []string) ([]byte, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+	if c.conn == nil {
+		conn, err := net.DialTimeout("tcp", c.Addr, timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn, c.r = conn, bufio.NewReader(conn)
+	}
+	c.conn.SetDeadline(time.Now().Add(timeout))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(c.conn, b.String())
+	if err != nil {
+		return nil, err
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty redis reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return []byte(line[1:]), nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(c.r, buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+	return nil, fmt.Errorf("unexpected redis reply %q", line)
+}
@@ -0,0 +1,31 @@
+package dnsclient
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name used for PTR lookups of ip.
+func ReverseName(ip string) (string, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return "", fmt.Errorf("invalid ip address %q", ip)
+	}
+
+	if v4 := addr.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", v4[3], v4[2], v4[1], v4[0]), nil
+	}
+
+	// ip6.arpa names are the 32 nibbles of the address, least significant first
+	const hexDigits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(addr) - 1; i >= 0; i-- {
+		b.WriteByte(hexDigits[addr[i]&0xf])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[addr[i]>>4])
+		b.WriteByte('.')
+	}
+	b.WriteString("ip6.arpa")
+	return b.String(), nil
+}
@@ -0,0 +1,175 @@
+package dnsclient
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rewrite rule actions
+const (
+	RewriteAddress   = "address"    // address name ip... replaces the addresses name has in answers
+	RewriteStripAAAA = "strip-aaaa" // strip-aaaa zone drops AAAA answers for names in zone
+	RewriteTTL       = "ttl"        // ttl zone min max clamps the TTLs of records in zone
+)
+
+// RewriteRule changes the answers of responses for names in Zone (or for
+// the name Zone itself with RewriteAddress).
+type RewriteRule struct {
+	Action string
+	Zone   string
+	Addrs  []net.IP
+	MinTTL int32
+	MaxTTL int32
+}
+
+// RewriteRules are applied in order to the responses a forwarder sends.
+type RewriteRules []RewriteRule
+
+// ParseRewriteRules reads one rule per line:
+//
+//	address public.example.com 10.0.0.5
+//	strip-aaaa v4only.example
+//	ttl . 60 86400
+//
+// Blank lines and lines starting with # are skipped.
+func ParseRewriteRules(r io.Reader) (RewriteRules, error) {
+	var rules RewriteRules
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule, err := parseRewriteRule(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func parseRewriteRule(fields []string) (RewriteRule, error) {
+	rule := RewriteRule{Action: fields[0]}
+	if len(fields) < 2 {
+		return rule, fmt.Errorf("%s needs a name", rule.Action)
+	}
+	rule.Zone = string(Name(ToASCII(fields[1])).Canonical())
+	args := fields[2:]
+	switch rule.Action {
+	case RewriteAddress:
+		if len(args) == 0 {
+			return rule, fmt.Errorf("address needs at least one ip")
+		}
+		for _, a := range args {
+			ip := net.ParseIP(a)
+			if ip == nil {
+				return rule, fmt.Errorf("invalid ip %q", a)
+			}
+			rule.Addrs = append(rule.Addrs, ip)
+		}
+	case RewriteStripAAAA:
+		if len(args) != 0 {
+			return rule, fmt.Errorf("strip-aaaa takes only a zone")
+		}
+	case RewriteTTL:
+		if len(args) != 2 {
+			return rule, fmt.Errorf("ttl needs a zone, a minimum and a maximum")
+		}
+		min, err1 := strconv.ParseInt(args[0], 10, 32)
+		max, err2 := strconv.ParseInt(args[1], 10, 32)
+		if err1 != nil || err2 != nil || min < 0 || max < min {
+			return rule, fmt.Errorf("invalid ttl range %s %s", args[0], args[1])
+		}
+		rule.MinTTL, rule.MaxTTL = int32(min), int32(max)
+	default:
+		return rule, fmt.Errorf("unknown action %q", rule.Action)
+	}
+	return rule, nil
+}
+
+// LoadRewriteRules reads the rules in the file at path.
+func LoadRewriteRules(path string) (RewriteRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rules, err := ParseRewriteRules(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return rules, nil
+}
+
+// Apply rewrites response in place.
+func (rules RewriteRules) Apply(response *DnsResponse) {
+	for _, rule := range rules {
+		switch rule.Action {
+		case RewriteAddress:
+			response.Answers = rule.replaceAddresses(response.Answers)
+		case RewriteStripAAAA:
+			var kept []DnsResourceRecord
+			for _, a := range response.Answers {
+				if a.Type != AAAA || !Name(a.Name).IsSubdomainOf(Name(rule.Zone)) {
+					kept = append(kept, a)
+				}
+			}
+			response.Answers = kept
+		case RewriteTTL:
+			for _, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, response.Additionals} {
+				for i, r := range section {
+					if r.Type == OPT || !Name(r.Name).IsSubdomainOf(Name(rule.Zone)) {
+						continue
+					}
+					if r.TTL < rule.MinTTL {
+						section[i].TTL = rule.MinTTL
+					} else if r.TTL > rule.MaxTTL {
+						section[i].TTL = rule.MaxTTL
+					}
+				}
+			}
+		}
+	}
+}
+
+// replaceAddresses swaps the A and AAAA records of the rule's name for its
+// addresses of the same family, keeping the TTL.
+func (rule RewriteRule) replaceAddresses(answers []DnsResourceRecord) []DnsResourceRecord {
+	var kept []DnsResourceRecord
+	replaced := make(map[uint16]DnsResourceRecord)
+	for _, a := range answers {
+		if (a.Type == A || a.Type == AAAA) && Name(a.Name).Equal(Name(rule.Zone)) {
+			if _, ok := replaced[a.Type]; !ok {
+				replaced[a.Type] = a
+			}
+			continue
+		}
+		kept = append(kept, a)
+	}
+	for _, t := range []uint16{A, AAAA} {
+		old, ok := replaced[t]
+		if !ok {
+			continue
+		}
+		for _, ip := range rule.Addrs {
+			rdata := ip.To4()
+			if t == AAAA {
+				if rdata != nil {
+					continue
+				}
+				rdata = ip.To16()
+			} else if rdata == nil {
+				continue
+			}
+			old.RData, old.RDLength = rdata, uint16(len(rdata))
+			kept = append(kept, old)
+		}
+	}
+	return kept
+}
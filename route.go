@@ -0,0 +1,51 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route sends queries for names in Zone and below to the servers of Client,
+// for split DNS: internal zones go to the VPN resolver, everything else
+// elsewhere.
+type Route struct {
+	Zone   string
+	Client *Client
+}
+
+// ParseRoutes parses comma separated zone=server rules such as
+// "corp.example=10.0.0.2,.=tls:1.1.1.1" into routes with clients made with
+// opts. A zone given more than once gets all its servers, in order.
+func ParseRoutes(s string, opts ...Option) ([]Route, error) {
+	servers := make(map[string][]string)
+	var zones []string
+	for _, rule := range strings.Split(s, ",") {
+		i := strings.IndexByte(rule, '=')
+		if i <= 0 || i == len(rule)-1 {
+			return nil, fmt.Errorf("invalid route %q, want zone=server", rule)
+		}
+		zone := string(Name(ToASCII(strings.TrimSpace(rule[:i]))).Canonical())
+		if _, ok := servers[zone]; !ok {
+			zones = append(zones, zone)
+		}
+		servers[zone] = append(servers[zone], strings.TrimSpace(rule[i+1:]))
+	}
+
+	var routes []Route
+	for _, zone := range zones {
+		routes = append(routes, Route{zone, NewClient(append(opts, WithServers(servers[zone]...))...)})
+	}
+	return routes, nil
+}
+
+// route returns the client of the most specific route covering name, or
+// the forwarder's own Client if none does.
+func (f *Forwarder) route(name string) *Client {
+	client, labels := f.Client, -1
+	for _, r := range f.Routes {
+		if n := Name(r.Zone).CountLabels(); n > labels && Name(name).IsSubdomainOf(Name(r.Zone)) {
+			client, labels = r.Client, n
+		}
+	}
+	return client
+}
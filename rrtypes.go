@@ -0,0 +1,190 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RRType describes how to handle the RData of one record type. Nil functions
+// fall back to treating the RData as opaque bytes.
+type RRType struct {
+	// Parse reads length bytes of RData from r, which is positioned inside the
+	// full message so compressed names can be followed, and returns what is
+	// stored in DnsResourceRecord.RData.
+	Parse func(r *bytes.Reader, length uint16) ([]byte, error)
+	// Format returns the stored RData in presentation format.
+	Format func(rdata []byte) string
+	// Serialize returns the stored RData in (uncompressed) wire format.
+	Serialize func(rdata []byte) []byte
+}
+
+var rrTypes = map[uint16]RRType{}
+
+// RegisterType sets the RData handling for record type t, replacing any
+// previous registration. Library users can register their own and private
+// use types this way.
+func RegisterType(t uint16, rr RRType) {
+	rrTypes[t] = rr
+}
+
+func ParseRData(r *bytes.Reader, t uint16, length uint16) ([]byte, error) {
+	if rr, ok := rrTypes[t]; ok && rr.Parse != nil {
+		return rr.Parse(r, length)
+	}
+	return parseOpaque(r, length)
+}
+
+func FormatRData(t uint16, rdata []byte) string {
+	if rr, ok := rrTypes[t]; ok && rr.Format != nil {
+		return rr.Format(rdata)
+	}
+	return FormatUnknownRData(rdata)
+}
+
+// FormatUnknownRData uses the generic \# length hex format from RFC 3597
+// section 5, used for types we have no decoder for.
+func FormatUnknownRData(rdata []byte) string {
+	if len(rdata) == 0 {
+		return `\# 0`
+	}
+	return fmt.Sprintf(`\# %d %x`, len(rdata), rdata)
+}
+
+// ParseUnknownRData parses the \# length hex format back into RData.
+func ParseUnknownRData(s string) ([]byte, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 2 || fields[0] != `\#` {
+		return nil, fmt.Errorf("rdata %q is not in \\# length hex format", s)
+	}
+	length, err := strconv.Atoi(fields[1])
+	if err != nil || length < 0 || length > 65535 {
+		return nil, fmt.Errorf("invalid rdata length %q", fields[1])
+	}
+	rdata, err := hex.DecodeString(strings.Join(fields[2:], ""))
+	if err != nil {
+		return nil, err
+	}
+	if len(rdata) != length {
+		return nil, fmt.Errorf("rdata length is %d, expected %d", len(rdata), length)
+	}
+	return rdata, nil
+}
+
+func SerializeRData(t uint16, rdata []byte) []byte {
+	if rr, ok := rrTypes[t]; ok && rr.Serialize != nil {
+		return rr.Serialize(rdata)
+	}
+	return rdata
+}
+
+func parseOpaque(r *bytes.Reader, length uint16) ([]byte, error) {
+	rdata := make([]byte, length)
+	_, err := io.ReadFull(r, rdata)
+	return rdata, err
+}
+
+// Names are stored as text, as they were before the registry existed.
+func parseNameRData(r *bytes.Reader, length uint16) ([]byte, error) {
+	name, err := ReadName(r)
+	return []byte(name), err
+}
+
+// parseFixedThenName keeps the fixed size fields and stores the name
+// uncompressed, so the RData can be decoded without the rest of the message.
+func parseFixedThenName(fixed int) func(r *bytes.Reader, length uint16) ([]byte, error) {
+	return func(r *bytes.Reader, length uint16) ([]byte, error) {
+		rdata := make([]byte, fixed)
+		_, err := io.ReadFull(r, rdata)
+		if err != nil {
+			return nil, err
+		}
+		name, err := ReadName(r)
+		if err != nil {
+			return nil, err
+		}
+		return append(rdata, SerializeName(name)...), nil
+	}
+}
+
+func init() {
+	name := RRType{
+		Parse:     parseNameRData,
+		Format:    func(rdata []byte) string { return ToUnicode(string(rdata)) },
+		Serialize: func(rdata []byte) []byte { return SerializeName(string(rdata)) },
+	}
+	RegisterType(NS, name)
+	RegisterType(CNAME, name)
+	RegisterType(PTR, name)
+	RegisterType(DNAME, name)
+
+	address := RRType{
+		Format: func(rdata []byte) string { return net.IP(rdata).String() },
+	}
+	RegisterType(A, address)
+	RegisterType(AAAA, address)
+
+	RegisterType(MX, RRType{
+		Parse: parseFixedThenName(2),
+		Format: func(rdata []byte) string {
+			pref, host, err := ParseMX(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return fmt.Sprintf("%d %s", pref, ToUnicode(host))
+		},
+	})
+	RegisterType(SRV, RRType{
+		Parse: parseFixedThenName(6),
+		Format: func(rdata []byte) string {
+			srv, err := ParseSRV(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, ToUnicode(srv.Target))
+		},
+	})
+	RegisterType(TXT, RRType{
+		Format: func(rdata []byte) string {
+			var strs []string
+			for _, s := range ReadCharacterStrings(rdata) {
+				strs = append(strs, strconv.Quote(s))
+			}
+			return strings.Join(strs, " ")
+		},
+	})
+}
+
+// ParseMX decodes MX rdata as stored by ReadResourceRecord.
+func ParseMX(rdata []byte) (uint16, string, error) {
+	if len(rdata) < 3 {
+		return 0, "", fmt.Errorf("mx rdata too short")
+	}
+	host, err := ReadName(bytes.NewReader(rdata[2:]))
+	if err != nil {
+		return 0, "", err
+	}
+	return binary.BigEndian.Uint16(rdata), host, nil
+}
+
+// ParseSRV decodes SRV rdata as stored by ReadResourceRecord.
+func ParseSRV(rdata []byte) (net.SRV, error) {
+	var srv net.SRV
+	if len(rdata) < 7 {
+		return srv, fmt.Errorf("srv rdata too short")
+	}
+	target, err := ReadName(bytes.NewReader(rdata[6:]))
+	if err != nil {
+		return srv, err
+	}
+	srv.Priority = binary.BigEndian.Uint16(rdata)
+	srv.Weight = binary.BigEndian.Uint16(rdata[2:])
+	srv.Port = binary.BigEndian.Uint16(rdata[4:])
+	srv.Target = target
+	return srv, nil
+}
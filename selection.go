@@ -0,0 +1,83 @@
+package dnsclient
+
+import (
+	"sort"
+	"time"
+)
+
+// ServerStats is what the client has learned about a server from the
+// queries sent to it.
+type ServerStats struct {
+	// Smoothed round trip time
+	SRTT time.Duration
+	// Failures since the last answer
+	Failures int
+	Queries  int
+}
+
+// A failed attempt counts as at least this slow.
+const failurePenalty = 500 * time.Millisecond
+
+// record updates the stats for server after an attempt, smoothing the RTT
+// the way TCP does (RFC 6298) with a weight of 1/8 for the new sample.
+func (c *Client) record(server string, rtt time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats == nil {
+		c.stats = make(map[string]*ServerStats)
+	}
+	s := c.stats[server]
+	if s == nil {
+		s = &ServerStats{}
+		c.stats[server] = s
+	}
+	s.Queries++
+	if err != nil {
+		s.Failures++
+		if s.SRTT < failurePenalty {
+			s.SRTT = failurePenalty
+		}
+		s.SRTT *= 2
+		return
+	}
+	s.Failures = 0
+	if s.SRTT == 0 {
+		s.SRTT = rtt
+	} else {
+		s.SRTT += (rtt - s.SRTT) / 8
+	}
+}
+
+// rankServers orders the servers fastest first. Servers never queried come
+// first so they get measured. Every ranking also makes the servers that were
+// not picked look a bit faster, so slow or failed ones get probed again now
+// and then, as BIND does with its SRTT decay.
+func (c *Client) rankServers() []string {
+	servers := append([]string{}, c.Servers...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srtt := func(server string) time.Duration {
+		if s := c.stats[server]; s != nil {
+			return s.SRTT
+		}
+		return 0
+	}
+	sort.SliceStable(servers, func(i, j int) bool { return srtt(servers[i]) < srtt(servers[j]) })
+	for _, server := range servers[1:] {
+		if s := c.stats[server]; s != nil {
+			s.SRTT -= s.SRTT / 50
+		}
+	}
+	return servers
+}
+
+// ServerStats returns a copy of the stats for each server queried so far.
+func (c *Client) ServerStats() map[string]ServerStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]ServerStats, len(c.stats))
+	for server, s := range c.stats {
+		stats[server] = *s
+	}
+	return stats
+}
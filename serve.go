@@ -0,0 +1,219 @@
+package dnsclient
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+)
+
+// Forwarder relays queries it receives to the upstream servers, in order,
+// and sends back the first reply. Replies are served from Cache if set.
+type Forwarder struct {
+	Upstreams []string
+	Cache     Cache
+
+	// Client sends the queries instead of going through Upstreams over UDP if
+	// set, so upstreams can be DoT, DoH or TCP servers and get its retries.
+	Client *Client
+	// Queries for names under the zone of a route go to its client instead,
+	// the most specific route wins
+	Routes []Route
+	// Names with local overrides are answered from them, ahead of everything
+	Overrides *Overrides
+	// Names on the blocklist are answered without asking anyone
+	Blocklist *Blocklist
+	// Applied to every reply on its way out
+	Rewrite RewriteRules
+	// Limits the queries each client address may send, the rest are dropped
+	ClientLimit *RateLimiter
+}
+
+// Forward returns the reply to the serialized query msg. If no upstream
+// answers the reply is a SERVFAIL built from the query.
+func (f *Forwarder) Forward(msg []byte) ([]byte, error) {
+	request, err := ReadRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	reply := f.forward(msg, request)
+	if len(f.Rewrite) == 0 {
+		return reply, nil
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		return reply, nil
+	}
+	f.Rewrite.Apply(&response)
+	return SerializeResponse(response), nil
+}
+
+func (f *Forwarder) forward(msg []byte, request DnsRequest) []byte {
+	if isBadVersion(request) {
+		return BadVersion(request)
+	}
+	if f.Overrides != nil {
+		if reply, ok := f.Overrides.Answer(request); ok {
+			return reply
+		}
+	}
+	if f.Blocklist != nil {
+		if reply, ok := f.Blocklist.Block(request); ok {
+			return reply
+		}
+	}
+	cacheable := f.Cache != nil && len(request.Questions) == 1
+	if cacheable {
+		if response, ok := f.Cache.Get(request); ok {
+			return SerializeResponse(*response)
+		}
+	}
+
+	if f.Client != nil || len(f.Routes) > 0 {
+		return f.exchange(request, cacheable)
+	}
+	for _, upstream := range f.Upstreams {
+		// Upstream the query goes with an id of our own, the client's could
+		// be guessed by whoever wants to spoof the answer into the cache
+		query := request
+		query.Header.Id = RandomID()
+		out := append([]byte{}, msg...)
+		binary.BigEndian.PutUint16(out, query.Header.Id)
+		reply, err := SendMessage(upstream, out, int(request.UDPSize()))
+		if err != nil {
+			log.Printf("upstream %s: %v", upstream, err)
+			continue
+		}
+		response, err := ReadResponse(reply)
+		if err != nil {
+			log.Printf("upstream %s: %v", upstream, err)
+			continue
+		}
+		if !MatchesRequest(response, query) {
+			log.Printf("upstream %s: reply does not match query", upstream)
+			continue
+		}
+		if cacheable && len(response.Questions) == 1 {
+			f.Cache.Put(request, response)
+		}
+		binary.BigEndian.PutUint16(reply, request.Header.Id)
+		return reply
+	}
+	return ServFail(request)
+}
+
+func (f *Forwarder) exchange(request DnsRequest, cacheable bool) []byte {
+	if len(request.Questions) != 1 {
+		return ServFail(request)
+	}
+	client := f.route(request.Questions[0].QName)
+	if client == nil {
+		return ServFail(request)
+	}
+	query := request
+	query.Header.Id = RandomID()
+	response, err := client.Exchange(context.Background(), &query)
+	if err != nil {
+		log.Printf("%s: %v", request.Questions[0].QName, err)
+		return ServFail(request)
+	}
+	if !MatchesRequest(*response, query) || len(response.Questions) != 1 {
+		log.Printf("%s: reply does not match query", request.Questions[0].QName)
+		return ServFail(request)
+	}
+	response.Header.Id = request.Header.Id
+	if cacheable {
+		f.Cache.Put(request, *response)
+	}
+	return SerializeResponse(*response)
+}
+
+// ServFail builds a SERVFAIL response echoing the questions of request.
+func ServFail(request DnsRequest) []byte {
+	return emptyReply(request, 2)
+}
+
+// Truncated builds an empty response with TC set, telling the client to ask
+// again over TCP.
+func Truncated(request DnsRequest) []byte {
+	return emptyReply(request, FlagTC)
+}
+
+func emptyReply(request DnsRequest, flags DnsFlags) []byte {
+	request.Header.Flags = request.Header.Flags&0x7910 | 0x8080 | flags
+	request.Header.QdCount = uint16(len(request.Questions))
+	request.Header.AnCount = 0
+	request.Header.NsCount = 0
+	request.Header.ArCount = 0
+	return SerializeRequest(request)
+}
+
+func (f *Forwarder) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if f.ClientLimit != nil && !f.ClientLimit.Allow(addrKey(addr)) {
+			continue
+		}
+		msg := make([]byte, n)
+		copy(msg, buf[:n])
+		go func() {
+			reply, err := f.Forward(msg)
+			if err != nil {
+				log.Printf("%s: %v", addr, err)
+				return
+			}
+			// Answers from TCP, DoT or DoH upstreams may not fit
+			if request, err := ReadRequest(msg); err == nil {
+				reply = fitReply(reply, request, int(request.UDPSize()))
+			}
+			_, err = conn.WriteTo(reply, addr)
+			if err != nil {
+				log.Printf("%s: %v", addr, err)
+			}
+		}()
+	}
+}
+
+func (f *Forwarder) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			for {
+				msg, err := ReadMessageTCP(conn)
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						log.Printf("%s: %v", conn.RemoteAddr(), err)
+					}
+					return
+				}
+				if f.ClientLimit != nil && !f.ClientLimit.Allow(addrKey(conn.RemoteAddr())) {
+					log.Printf("%s: rate limited", conn.RemoteAddr())
+					return
+				}
+				reply, err := f.Forward(msg)
+				if err != nil {
+					log.Printf("%s: %v", conn.RemoteAddr(), err)
+					return
+				}
+				if request, err := ReadRequest(msg); err == nil {
+					reply = fitReply(reply, request, MaxMessageSize)
+				}
+				err = WriteMessageTCP(conn, reply)
+				if err != nil {
+					log.Printf("%s: %v", conn.RemoteAddr(), err)
+					return
+				}
+			}
+		}()
+	}
+}
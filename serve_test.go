@@ -0,0 +1,243 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iechevarria/dns-client/dnstest"
+)
+
+func TestForwarderUpstreamID(t *testing.T) {
+	pipe := dnstest.NewPipe()
+	pipe.HandleA("example.com", net.IPv4(192, 0, 2, 1))
+	client := NewClient(WithServers("pipe"), WithServerTransport("pipe", pipe))
+	f := &Forwarder{Client: client}
+
+	for i := 0; i < 3; i++ {
+		query := NewQuery("example.com", A)
+		query.Header.Id = 0x1234
+		reply, err := f.Forward(SerializeRequest(*query))
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := ReadResponse(reply)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.Header.Id != 0x1234 || len(response.Answers) != 1 {
+			t.Fatalf("reply id %#x with %d answers, want %#x with 1", response.Header.Id, len(response.Answers), 0x1234)
+		}
+	}
+	// The upstream ids are our own, not the one the client chose
+	same := 0
+	for _, msg := range pipe.Messages() {
+		if binary.BigEndian.Uint16(msg) == 0x1234 {
+			same++
+		}
+	}
+	if same == len(pipe.Messages()) {
+		t.Errorf("all %d queries went upstream with the client's id", same)
+	}
+}
+
+func TestForwarderSpoofedReply(t *testing.T) {
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := upstream.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query, err := ReadRequest(buf[:n])
+		if err != nil {
+			return
+		}
+		// Right id, another question
+		spoofed := DnsResponse{
+			Header:    DnsHeader{Id: query.Header.Id, Flags: 0x8180, QdCount: 1, AnCount: 1},
+			Questions: []DnsQuestion{{QName: "bank.example", QType: A, QClass: IN}},
+			Answers:   []DnsResourceRecord{{Name: "bank.example", Type: A, Class: IN, TTL: 3600, RDLength: 4, RData: []byte{203, 0, 113, 1}}},
+		}
+		upstream.WriteTo(SerializeResponse(spoofed), addr)
+	}()
+
+	cache := NewCache()
+	f := &Forwarder{Upstreams: []string{upstream.LocalAddr().String()}, Cache: cache}
+	query := NewQuery("example.com", A)
+	reply, err := f.Forward(SerializeRequest(*query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Header.Flags.RCode() != 2 || len(response.Answers) != 0 {
+		t.Errorf("got rcode %d with %d answers, want SERVFAIL", response.Header.Flags.RCode(), len(response.Answers))
+	}
+	if _, ok := cache.Get(*query); ok {
+		t.Error("the spoofed reply was cached")
+	}
+}
+
+// forward sends a query for name through f and returns the reply.
+func forward(t *testing.T, f *Forwarder, name string, qtype uint16) DnsResponse {
+	t.Helper()
+	query := NewQuery(name, qtype)
+	reply, err := f.Forward(SerializeRequest(*query))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !MatchesRequest(response, *query) {
+		t.Fatalf("reply %+v does not match the query", response)
+	}
+	return response
+}
+
+func TestForwarderCache(t *testing.T) {
+	pipe := dnstest.NewPipe()
+	pipe.HandleA("example.com", net.IPv4(192, 0, 2, 1))
+	pipe.Handle("fail.example", A, dnstest.Response{RCode: 2})
+	client := NewClient(WithServers("pipe"), WithServerTransport("pipe", pipe), WithAttempts(1))
+	cache := NewCache()
+	f := &Forwarder{Client: client, Cache: cache}
+
+	for i := 0; i < 3; i++ {
+		response := forward(t, f, "example.com", A)
+		if len(response.Answers) != 1 {
+			t.Fatalf("query %d: %d answers, want 1", i, len(response.Answers))
+		}
+	}
+	if n := len(pipe.Messages()); n != 1 {
+		t.Errorf("%d queries went upstream, want 1 and the rest from the cache", n)
+	}
+	if stats := cache.Stats(); stats.Hits != 2 {
+		t.Errorf("cache stats = %+v, want 2 hits", stats)
+	}
+
+	// Failures are asked about again every time
+	forward(t, f, "fail.example", A)
+	forward(t, f, "fail.example", A)
+	if n := len(pipe.Messages()); n != 3 {
+		t.Errorf("%d queries went upstream, want 3", n)
+	}
+}
+
+func TestForwarderLocalAnswers(t *testing.T) {
+	pipe := dnstest.NewPipe()
+	pipe.HandleA("www.example.com", net.IPv4(192, 0, 2, 1))
+	pipe.HandleA("nas.home", net.IPv4(192, 0, 2, 2))
+	client := NewClient(WithServers("pipe"), WithServerTransport("pipe", pipe))
+
+	overrides := NewOverrides()
+	if err := overrides.Load(strings.NewReader("10.0.0.5 nas.home\nprinter.home. 60 IN A 10.0.0.9\n")); err != nil {
+		t.Fatal(err)
+	}
+	blocklist := NewBlocklist()
+	if err := blocklist.Load(strings.NewReader("0.0.0.0 ads.example # tracking\nnas.home\n")); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := ParseRewriteRules(strings.NewReader("address www.example.com 10.0.0.1\nttl . 0 60\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &Forwarder{Client: client, Overrides: overrides, Blocklist: blocklist, Rewrite: rules}
+
+	tests := []struct {
+		name   string
+		qtype  uint16
+		rcode  uint16
+		answer net.IP
+		ttl    int32
+	}{
+		// Overrides win over the blocklist and upstream
+		{"nas.home", A, 0, net.IPv4(10, 0, 0, 5), 60},
+		{"Printer.Home.", A, 0, net.IPv4(10, 0, 0, 9), 60},
+		{"nas.home", MX, 0, nil, 0},
+		{"5.0.0.10.in-addr.arpa", PTR, 0, nil, 60},
+		// Blocked names and the names below them
+		{"ads.example", A, 3, nil, 0},
+		{"cdn.ads.example", AAAA, 3, nil, 0},
+		// Upstream answers are rewritten
+		{"www.example.com", A, 0, net.IPv4(10, 0, 0, 1), 60},
+	}
+	for _, test := range tests {
+		t.Run(test.name+"/"+TypeToString(test.qtype), func(t *testing.T) {
+			response := forward(t, f, test.name, test.qtype)
+			if rcode := response.Header.Flags.RCode(); rcode != test.rcode {
+				t.Fatalf("rcode %d, want %d", rcode, test.rcode)
+			}
+			if test.answer != nil {
+				if len(response.Answers) != 1 || !net.IP(response.Answers[0].RData).Equal(test.answer) {
+					t.Fatalf("answers %v, want %s", response.Answers, test.answer)
+				}
+			} else if test.ttl == 0 && len(response.Answers) != 0 {
+				t.Fatalf("answers %v, want none", response.Answers)
+			}
+			if test.ttl != 0 && (len(response.Answers) != 1 || response.Answers[0].TTL != test.ttl) {
+				t.Errorf("answers %v, want one with ttl %d", response.Answers, test.ttl)
+			}
+		})
+	}
+	if n := len(pipe.Messages()); n != 1 {
+		t.Errorf("%d queries went upstream, want only the one for www.example.com", n)
+	}
+	if stats := blocklist.Stats(1); stats.Blocked != 2 {
+		t.Errorf("blocklist stats = %+v, want 2 blocked", stats)
+	}
+}
+
+func TestForwarderRoutes(t *testing.T) {
+	corp, other := dnstest.NewPipe(), dnstest.NewPipe()
+	corp.HandleA("intranet.corp.example", net.IPv4(10, 0, 0, 1))
+	other.HandleA("www.example.com", net.IPv4(192, 0, 2, 1))
+	routes := []Route{
+		{"corp.example", NewClient(WithServers("corp"), WithServerTransport("corp", corp))},
+		{".", NewClient(WithServers("other"), WithServerTransport("other", other))},
+	}
+	f := &Forwarder{Routes: routes}
+
+	if response := forward(t, f, "intranet.corp.example", A); len(response.Answers) != 1 {
+		t.Errorf("intranet.corp.example: %d answers, want 1", len(response.Answers))
+	}
+	if response := forward(t, f, "www.example.com", A); len(response.Answers) != 1 {
+		t.Errorf("www.example.com: %d answers, want 1", len(response.Answers))
+	}
+	if len(corp.Messages()) != 1 || len(other.Messages()) != 1 {
+		t.Errorf("%d queries to corp and %d to the rest, want 1 each", len(corp.Messages()), len(other.Messages()))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(2)
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if _, ok := l.take("192.0.2.1", now, false); ok != want {
+			t.Errorf("query %d allowed: %t, want %t", i, ok, want)
+		}
+	}
+	// Other clients have their own bucket
+	if _, ok := l.take("192.0.2.2", now, false); !ok {
+		t.Error("another client was limited")
+	}
+	// Half a second later one token is back
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := l.take("192.0.2.1", now, false); !ok {
+		t.Error("not allowed again after half a second")
+	}
+	// Waiting takes the token anyway and says how long until it's due
+	if d, _ := l.take("192.0.2.1", now, true); d != 500*time.Millisecond {
+		t.Errorf("wait %v, want 500ms", d)
+	}
+}
@@ -0,0 +1,59 @@
+package dnsclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	TLSA   = 52
+	SMIMEA = 53
+)
+
+// TLSARecord is the RData of TLSA (RFC 6698) and SMIMEA (RFC 8162) records,
+// which share the same format.
+type TLSARecord struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	Data         []byte
+}
+
+func (t TLSARecord) String() string {
+	return fmt.Sprintf("%d %d %d %x", t.Usage, t.Selector, t.MatchingType, t.Data)
+}
+
+func ParseTLSA(rdata []byte) (TLSARecord, error) {
+	var t TLSARecord
+	if len(rdata) < 3 {
+		return t, errors.New("tlsa rdata too short")
+	}
+	t.Usage = rdata[0]
+	t.Selector = rdata[1]
+	t.MatchingType = rdata[2]
+	t.Data = rdata[3:]
+	return t, nil
+}
+
+func SerializeTLSA(t TLSARecord) []byte {
+	return append([]byte{t.Usage, t.Selector, t.MatchingType}, t.Data...)
+}
+
+// SMIMEAName returns the name of the SMIMEA records for email.
+func SMIMEAName(email string) (string, error) {
+	return emailOwnerName(email, "_smimecert")
+}
+
+func init() {
+	tlsa := RRType{
+		Format: func(rdata []byte) string {
+			t, err := ParseTLSA(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return t.String()
+		},
+	}
+	RegisterType(TLSA, tlsa)
+	RegisterType(SMIMEA, tlsa)
+}
@@ -0,0 +1,95 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SOARecord is the RData of an SOA record (RFC 1035 section 3.3.13).
+type SOARecord struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+func (s SOARecord) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", FormatName(s.MName), FormatName(s.RName), s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum)
+}
+
+// parseSOA stores both names uncompressed followed by the five counters.
+func parseSOA(r *bytes.Reader, length uint16) ([]byte, error) {
+	rdata, err := parseTwoNames(r, length)
+	if err != nil {
+		return nil, err
+	}
+	fixed := make([]byte, 20)
+	_, err = io.ReadFull(r, fixed)
+	if err != nil {
+		return nil, err
+	}
+	return append(rdata, fixed...), nil
+}
+
+// ParseSOA decodes SOA rdata as stored by ReadResourceRecord.
+func ParseSOA(rdata []byte) (SOARecord, error) {
+	var s SOARecord
+	r := bytes.NewReader(rdata)
+	var err error
+	s.MName, err = ReadName(r)
+	if err != nil {
+		return s, err
+	}
+	s.RName, err = ReadName(r)
+	if err != nil {
+		return s, err
+	}
+	if r.Len() < 20 {
+		return s, errors.New("soa rdata too short")
+	}
+	binary.Read(r, binary.BigEndian, &s.Serial)
+	binary.Read(r, binary.BigEndian, &s.Refresh)
+	binary.Read(r, binary.BigEndian, &s.Retry)
+	binary.Read(r, binary.BigEndian, &s.Expire)
+	binary.Read(r, binary.BigEndian, &s.Minimum)
+	return s, nil
+}
+
+func SerializeSOA(s SOARecord) []byte {
+	var buf bytes.Buffer
+	buf.Write(SerializeName(s.MName))
+	buf.Write(SerializeName(s.RName))
+	binary.Write(&buf, binary.BigEndian, []uint32{s.Serial, s.Refresh, s.Retry, s.Expire, s.Minimum})
+	return buf.Bytes()
+}
+
+// canonicalSOA lowercases the names of SOA rdata.
+func canonicalSOA(rdata []byte) []byte {
+	s, err := ParseSOA(rdata)
+	if err != nil {
+		return rdata
+	}
+	s.MName = strings.ToLower(s.MName)
+	s.RName = strings.ToLower(s.RName)
+	return SerializeSOA(s)
+}
+
+func init() {
+	RegisterType(SOA, RRType{
+		Parse: parseSOA,
+		Format: func(rdata []byte) string {
+			s, err := ParseSOA(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return s.String()
+		},
+	})
+}
@@ -0,0 +1,196 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const SSHFP = 44
+
+// SSHFP fingerprint types
+const (
+	SSHFPSHA1   = 1
+	SSHFPSHA256 = 2
+)
+
+// SSHFPRecord is the RData of an SSHFP record (RFC 4255).
+type SSHFPRecord struct {
+	Algorithm   uint8
+	FPType      uint8
+	Fingerprint []byte
+}
+
+func (fp SSHFPRecord) String() string {
+	return fmt.Sprintf("%d %d %x", fp.Algorithm, fp.FPType, fp.Fingerprint)
+}
+
+func ParseSSHFP(rdata []byte) (SSHFPRecord, error) {
+	var fp SSHFPRecord
+	if len(rdata) < 2 {
+		return fp, errors.New("sshfp rdata too short")
+	}
+	fp.Algorithm = rdata[0]
+	fp.FPType = rdata[1]
+	fp.Fingerprint = rdata[2:]
+	return fp, nil
+}
+
+func SerializeSSHFP(fp SSHFPRecord) []byte {
+	return append([]byte{fp.Algorithm, fp.FPType}, fp.Fingerprint...)
+}
+
+func init() {
+	RegisterType(SSHFP, RRType{
+		Format: func(rdata []byte) string {
+			fp, err := ParseSSHFP(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return fp.String()
+		},
+	})
+}
+
+// SSHFPAlgorithm returns the SSHFP algorithm number for an SSH key type
+// (RFC 4255, 6594, 7479 and 8709), or 0 if there is none.
+func SSHFPAlgorithm(keyType string) uint8 {
+	switch {
+	case keyType == "ssh-rsa":
+		return 1
+	case keyType == "ssh-dss":
+		return 2
+	case strings.HasPrefix(keyType, "ecdsa-sha2-"):
+		return 3
+	case keyType == "ssh-ed25519":
+		return 4
+	case keyType == "ssh-ed448":
+		return 6
+	}
+	return 0
+}
+
+// sshKeyType reads the key type string at the start of an SSH public key blob.
+func sshKeyType(key []byte) (string, error) {
+	if len(key) < 4 {
+		return "", errors.New("ssh key too short")
+	}
+	n := binary.BigEndian.Uint32(key)
+	if uint32(len(key)-4) < n {
+		return "", errors.New("ssh key too short")
+	}
+	return string(key[4 : 4+n]), nil
+}
+
+// SSHFingerprint returns the SSHFP record for an SSH public key blob (the
+// base64 decoded part of a known_hosts or .pub line).
+func SSHFingerprint(key []byte, fpType uint8) (SSHFPRecord, error) {
+	keyType, err := sshKeyType(key)
+	if err != nil {
+		return SSHFPRecord{}, err
+	}
+	alg := SSHFPAlgorithm(keyType)
+	if alg == 0 {
+		return SSHFPRecord{}, fmt.Errorf("no sshfp algorithm for key type %s", keyType)
+	}
+	fp := SSHFPRecord{Algorithm: alg, FPType: fpType}
+	switch fpType {
+	case SSHFPSHA1:
+		h := sha1.Sum(key)
+		fp.Fingerprint = h[:]
+	case SSHFPSHA256:
+		h := sha256.Sum256(key)
+		fp.Fingerprint = h[:]
+	default:
+		return fp, fmt.Errorf("unsupported sshfp fingerprint type %d", fpType)
+	}
+	return fp, nil
+}
+
+// MatchSSHFP reports whether key matches one of records. Records for other
+// algorithms or unknown fingerprint types are ignored.
+func MatchSSHFP(key []byte, records []SSHFPRecord) (bool, error) {
+	for _, r := range records {
+		fp, err := SSHFingerprint(key, r.FPType)
+		if err != nil {
+			if r.FPType != SSHFPSHA1 && r.FPType != SSHFPSHA256 {
+				continue
+			}
+			return false, err
+		}
+		if fp.Algorithm == r.Algorithm && bytes.Equal(fp.Fingerprint, r.Fingerprint) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// KnownHost is one entry of an OpenSSH known_hosts file.
+type KnownHost struct {
+	Marker  string
+	Hosts   []string
+	KeyType string
+	Key     []byte
+}
+
+// ParseKnownHostsLine parses a known_hosts line of the form
+// "[@marker] hosts keytype base64-key [comment]".
+func ParseKnownHostsLine(line string) (KnownHost, error) {
+	var kh KnownHost
+	fields := strings.Fields(line)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		kh.Marker = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) < 3 {
+		return kh, fmt.Errorf("invalid known_hosts line %q", line)
+	}
+	kh.Hosts = strings.Split(fields[0], ",")
+	kh.KeyType = fields[1]
+	key, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return kh, fmt.Errorf("invalid known_hosts key: %v", err)
+	}
+	keyType, err := sshKeyType(key)
+	if err != nil {
+		return kh, err
+	}
+	if keyType != kh.KeyType {
+		return kh, fmt.Errorf("known_hosts key type %s does not match key %s", kh.KeyType, keyType)
+	}
+	kh.Key = key
+	return kh, nil
+}
+
+// LookupSSHFP returns the SSHFP records published for host.
+func (c *Client) LookupSSHFP(ctx context.Context, host string) ([]SSHFPRecord, error) {
+	records, err := c.lookup(ctx, host, SSHFP)
+	if err != nil {
+		return nil, err
+	}
+	var fps []SSHFPRecord
+	for _, r := range records {
+		fp, err := ParseSSHFP(r.RData)
+		if err != nil {
+			continue
+		}
+		fps = append(fps, fp)
+	}
+	return fps, nil
+}
+
+// VerifyHostKey checks an SSH host key blob against the SSHFP records of host.
+// Without DNSSEC validation the answer is only as trustworthy as the resolver.
+func (c *Client) VerifyHostKey(ctx context.Context, host string, key []byte) (bool, error) {
+	records, err := c.LookupSSHFP(ctx, host)
+	if err != nil {
+		return false, err
+	}
+	return MatchSSHFP(key, records)
+}
@@ -0,0 +1,25 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Stats summarizes how request was answered, like the footer dig prints:
+// the round trip time, the server and transport, the message sizes and
+// whether the answer came from the cache.
+func (r DnsResponse) Stats(request DnsRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Query time: %s\n", r.RTT.Round(time.Microsecond))
+	if r.Server != "" {
+		via := r.Transport
+		if r.Protocol != "" {
+			via += ", " + r.Protocol
+		}
+		fmt.Fprintf(&b, "Server: %s (%s)\n", r.Server, via)
+	}
+	fmt.Fprintf(&b, "Message size: sent %d, received %d bytes\n", len(SerializeRequest(request)), r.Size)
+	fmt.Fprintf(&b, "Cached: %t\n", r.Cached)
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Sections of a message that hold records
+const (
+	SectionAnswer = iota
+	SectionAuthority
+	SectionAdditional
+)
+
+// MessageReader decodes the records of a message one at a time as Next is
+// called, for large responses where most records are skipped or only
+// looked at once.
+type MessageReader struct {
+	Header    DnsHeader
+	Questions []DnsQuestion
+
+	msg     []byte
+	r       *bytes.Reader
+	section int
+	left    [3]int
+}
+
+// NewMessageReader decodes the header and questions of msg and leaves the
+// records for Next.
+func NewMessageReader(msg []byte) (*MessageReader, error) {
+	m := &MessageReader{msg: msg, r: bytes.NewReader(msg)}
+	err := binary.Read(m.r, binary.BigEndian, &m.Header)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(m.Header.QdCount); i++ {
+		question, err := ReadQuestion(m.r)
+		if err != nil {
+			return nil, err
+		}
+		m.Questions = append(m.Questions, question)
+	}
+	m.left = [3]int{int(m.Header.AnCount), int(m.Header.NsCount), int(m.Header.ArCount)}
+	return m, nil
+}
+
+// Next decodes the next record and returns it with the section it is in.
+// After the last one it returns io.EOF.
+func (m *MessageReader) Next() (DnsResourceRecord, int, error) {
+	for m.section < len(m.left) && m.left[m.section] == 0 {
+		m.section++
+	}
+	if m.section == len(m.left) {
+		return DnsResourceRecord{}, 0, io.EOF
+	}
+	m.left[m.section]--
+	rr, err := ReadResourceRecord(m.r)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return rr, m.section, err
+}
@@ -0,0 +1,246 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	SVCB  = 64
+	HTTPS = 65
+)
+
+// SvcParamKeys (RFC 9460 section 14.3.2)
+const (
+	SvcMandatory     = 0
+	SvcALPN          = 1
+	SvcNoDefaultALPN = 2
+	SvcPort          = 3
+	SvcIPv4Hint      = 4
+	SvcECH           = 5
+	SvcIPv6Hint      = 6
+)
+
+var svcParamKeyNames = map[uint16]string{
+	SvcMandatory:     "mandatory",
+	SvcALPN:          "alpn",
+	SvcNoDefaultALPN: "no-default-alpn",
+	SvcPort:          "port",
+	SvcIPv4Hint:      "ipv4hint",
+	SvcECH:           "ech",
+	SvcIPv6Hint:      "ipv6hint",
+}
+
+func svcParamKeyName(key uint16) string {
+	if name, ok := svcParamKeyNames[key]; ok {
+		return name
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+// SvcParam is one raw key=value pair of an SVCB or HTTPS record.
+type SvcParam struct {
+	Key   uint16
+	Value []byte
+}
+
+// SVCBRecord is the RData of SVCB and HTTPS records (RFC 9460). The well
+// known parameters are decoded into their own fields, Params keeps all of
+// them as they were received.
+type SVCBRecord struct {
+	Priority uint16
+	Target   string
+	Params   []SvcParam
+
+	Mandatory     []uint16
+	ALPN          []string
+	NoDefaultALPN bool
+	Port          uint16 // 0 if not set
+	IPv4Hint      []net.IP
+	IPv6Hint      []net.IP
+	ECH           []byte
+}
+
+// AliasMode reports whether the record is an alias (priority 0) rather than
+// a service binding.
+func (s SVCBRecord) AliasMode() bool {
+	return s.Priority == 0
+}
+
+func (s SVCBRecord) String() string {
+	parts := []string{strconv.Itoa(int(s.Priority)), FormatName(s.Target)}
+	for _, p := range s.Params {
+		key := svcParamKeyName(p.Key)
+		switch p.Key {
+		case SvcMandatory:
+			var keys []string
+			for _, k := range s.Mandatory {
+				keys = append(keys, svcParamKeyName(k))
+			}
+			parts = append(parts, key+"="+strings.Join(keys, ","))
+		case SvcALPN:
+			var ids []string
+			for _, id := range s.ALPN {
+				ids = append(ids, strings.ReplaceAll(id, ",", `\,`))
+			}
+			parts = append(parts, key+"="+strings.Join(ids, ","))
+		case SvcNoDefaultALPN:
+			parts = append(parts, key)
+		case SvcPort:
+			parts = append(parts, key+"="+strconv.Itoa(int(s.Port)))
+		case SvcIPv4Hint, SvcIPv6Hint:
+			ips := s.IPv4Hint
+			if p.Key == SvcIPv6Hint {
+				ips = s.IPv6Hint
+			}
+			var addrs []string
+			for _, ip := range ips {
+				addrs = append(addrs, ip.String())
+			}
+			parts = append(parts, key+"="+strings.Join(addrs, ","))
+		case SvcECH:
+			parts = append(parts, key+"="+base64.StdEncoding.EncodeToString(s.ECH))
+		default:
+			if len(p.Value) == 0 {
+				parts = append(parts, key)
+			} else {
+				parts = append(parts, key+"="+strconv.Quote(string(p.Value)))
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseSVCB stores the target name uncompressed, followed by the parameters.
+func parseSVCB(r *bytes.Reader, length uint16) ([]byte, error) {
+	if length < 3 {
+		return nil, errors.New("svcb rdata too short")
+	}
+	end := r.Len() - int(length)
+	rdata := make([]byte, 2)
+	_, err := io.ReadFull(r, rdata)
+	if err != nil {
+		return nil, err
+	}
+	target, err := ReadName(r)
+	if err != nil {
+		return nil, err
+	}
+	rdata = append(rdata, SerializeName(target)...)
+	if r.Len() < end {
+		return nil, errors.New("svcb target runs past the rdata")
+	}
+	params := make([]byte, r.Len()-end)
+	_, err = io.ReadFull(r, params)
+	if err != nil {
+		return nil, err
+	}
+	return append(rdata, params...), nil
+}
+
+// ParseSVCB decodes SVCB or HTTPS rdata as stored by ReadResourceRecord.
+func ParseSVCB(rdata []byte) (SVCBRecord, error) {
+	var s SVCBRecord
+	if len(rdata) < 3 {
+		return s, errors.New("svcb rdata too short")
+	}
+	s.Priority = binary.BigEndian.Uint16(rdata)
+	r := bytes.NewReader(rdata[2:])
+	target, err := ReadName(r)
+	if err != nil {
+		return s, err
+	}
+	s.Target = target
+
+	for r.Len() > 0 {
+		var p SvcParam
+		var length uint16
+		if r.Len() < 4 {
+			return s, errors.New("svcparam truncated")
+		}
+		binary.Read(r, binary.BigEndian, &p.Key)
+		binary.Read(r, binary.BigEndian, &length)
+		p.Value = make([]byte, length)
+		_, err := io.ReadFull(r, p.Value)
+		if err != nil {
+			return s, errors.New("svcparam value truncated")
+		}
+		err = s.decodeParam(p)
+		if err != nil {
+			return s, err
+		}
+		s.Params = append(s.Params, p)
+	}
+	return s, nil
+}
+
+func (s *SVCBRecord) decodeParam(p SvcParam) error {
+	v := p.Value
+	switch p.Key {
+	case SvcMandatory:
+		if len(v)%2 != 0 {
+			return errors.New("invalid mandatory svcparam")
+		}
+		for i := 0; i < len(v); i += 2 {
+			s.Mandatory = append(s.Mandatory, binary.BigEndian.Uint16(v[i:]))
+		}
+	case SvcALPN:
+		for len(v) > 0 {
+			n := int(v[0])
+			if n == 0 || n >= len(v) {
+				return errors.New("invalid alpn svcparam")
+			}
+			s.ALPN = append(s.ALPN, string(v[1:1+n]))
+			v = v[1+n:]
+		}
+	case SvcNoDefaultALPN:
+		if len(v) != 0 {
+			return errors.New("invalid no-default-alpn svcparam")
+		}
+		s.NoDefaultALPN = true
+	case SvcPort:
+		if len(v) != 2 {
+			return errors.New("invalid port svcparam")
+		}
+		s.Port = binary.BigEndian.Uint16(v)
+	case SvcIPv4Hint:
+		if len(v) == 0 || len(v)%net.IPv4len != 0 {
+			return errors.New("invalid ipv4hint svcparam")
+		}
+		for i := 0; i < len(v); i += net.IPv4len {
+			s.IPv4Hint = append(s.IPv4Hint, net.IP(v[i:i+net.IPv4len]))
+		}
+	case SvcIPv6Hint:
+		if len(v) == 0 || len(v)%net.IPv6len != 0 {
+			return errors.New("invalid ipv6hint svcparam")
+		}
+		for i := 0; i < len(v); i += net.IPv6len {
+			s.IPv6Hint = append(s.IPv6Hint, net.IP(v[i:i+net.IPv6len]))
+		}
+	case SvcECH:
+		s.ECH = v
+	}
+	return nil
+}
+
+func init() {
+	svcb := RRType{
+		Parse: parseSVCB,
+		Format: func(rdata []byte) string {
+			s, err := ParseSVCB(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return s.String()
+		},
+	}
+	RegisterType(SVCB, svcb)
+	RegisterType(HTTPS, svcb)
+}
@@ -0,0 +1,82 @@
+package dnsclient
+
+import (
+	"bytes"
+	"testing"
+)
+
+// svcbRData builds SVCB rdata from a priority, a target and raw params.
+func svcbRData(priority uint16, target string, params ...SvcParam) []byte {
+	rdata := append([]byte{byte(priority >> 8), byte(priority)}, SerializeName(target)...)
+	for _, p := range params {
+		rdata = append(rdata, byte(p.Key>>8), byte(p.Key), byte(len(p.Value)>>8), byte(len(p.Value)))
+		rdata = append(rdata, p.Value...)
+	}
+	return rdata
+}
+
+// The examples of RFC 9460 appendix D.2 that don't need escaping
+func TestParseSVCB(t *testing.T) {
+	v6 := []byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}
+	tests := []struct {
+		name  string
+		rdata []byte
+		want  string
+	}{
+		{"alias", svcbRData(0, "foo.example.com"), "0 foo.example.com"},
+		{"service", svcbRData(1, ""), "1 ."},
+		{"port", svcbRData(16, "foo.example.com", SvcParam{SvcPort, []byte{0, 53}}), "16 foo.example.com port=53"},
+		{"generic key", svcbRData(1, "foo.example.com", SvcParam{667, []byte("hello")}), `1 foo.example.com key667="hello"`},
+		{"ipv6hint", svcbRData(1, "foo.example.com", SvcParam{SvcIPv6Hint, append(v6, v6...)}),
+			"1 foo.example.com ipv6hint=2001:db8::1,2001:db8::1"},
+		{"mandatory", svcbRData(16, "foo.example.org",
+			SvcParam{SvcMandatory, []byte{0, SvcALPN, 0, SvcIPv4Hint}},
+			SvcParam{SvcALPN, []byte{2, 'h', '2', 5, 'h', '3', '-', '1', '9'}},
+			SvcParam{SvcIPv4Hint, []byte{192, 0, 2, 1}}),
+			"16 foo.example.org mandatory=alpn,ipv4hint alpn=h2,h3-19 ipv4hint=192.0.2.1"},
+		{"no-default-alpn", svcbRData(1, "foo.example.com", SvcParam{SvcALPN, []byte{2, 'h', '3'}}, SvcParam{SvcNoDefaultALPN, nil}),
+			"1 foo.example.com alpn=h3 no-default-alpn"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := ParseSVCB(test.rdata)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.String(); got != test.want {
+				t.Errorf("String() = %q, want %q", got, test.want)
+			}
+			if s.AliasMode() != (test.name == "alias") {
+				t.Errorf("AliasMode() = %t", s.AliasMode())
+			}
+		})
+	}
+
+	s, err := ParseSVCB(tests[5].rdata)
+	if err != nil || s.Port != 0 || len(s.ALPN) != 2 || s.ALPN[1] != "h3-19" || len(s.IPv4Hint) != 1 || !bytes.Equal(s.IPv4Hint[0], []byte{192, 0, 2, 1}) {
+		t.Errorf("ParseSVCB() = %+v, %v", s, err)
+	}
+}
+
+func TestParseSVCBInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rdata []byte
+	}{
+		{"too short", []byte{0, 1}},
+		{"param truncated", append(svcbRData(1, ""), 0, SvcPort, 0)},
+		{"value truncated", append(svcbRData(1, ""), 0, SvcPort, 0, 2, 0)},
+		{"port length", svcbRData(1, "", SvcParam{SvcPort, []byte{0, 0, 53}})},
+		{"ipv4hint length", svcbRData(1, "", SvcParam{SvcIPv4Hint, []byte{192, 0, 2}})},
+		{"empty ipv6hint", svcbRData(1, "", SvcParam{SvcIPv6Hint, nil})},
+		{"empty alpn id", svcbRData(1, "", SvcParam{SvcALPN, []byte{0}})},
+		{"alpn id past the end", svcbRData(1, "", SvcParam{SvcALPN, []byte{3, 'h', '2'}})},
+		{"no-default-alpn value", svcbRData(1, "", SvcParam{SvcNoDefaultALPN, []byte{1}})},
+		{"odd mandatory", svcbRData(1, "", SvcParam{SvcMandatory, []byte{0}})},
+	}
+	for _, test := range tests {
+		if s, err := ParseSVCB(test.rdata); err == nil {
+			t.Errorf("%s: ParseSVCB() = %+v, want an error", test.name, s)
+		}
+	}
+}
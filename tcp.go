@@ -0,0 +1,260 @@
+package dnsclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// EDNS option code for edns-tcp-keepalive (RFC 7828)
+const EDNSTCPKeepalive = 11
+
+// ServerAddr adds the default port 53 to server if it has none.
+func ServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), "53")
+}
+
+// StreamConn is a DNS connection over TCP, TLS or a unix stream socket that
+// can be reused for several queries for as long as the server allows through
+// edns-tcp-keepalive.
+type StreamConn struct {
+	Server string
+
+	conn          net.Conn
+	idleUntil     time.Time
+	timeouts      Timeouts
+	authenticated bool
+}
+
+// DialStream connects to server over TCP, to the unix stream socket at path if
+// server is "unix:path", or over TLS if server is "tls:host[:port]".
+func DialStream(server string) (*StreamConn, error) {
+	var c Client
+	return c.dialStream(context.Background(), server, true)
+}
+
+// dialStream connects with the client's timeouts, TLS settings and bootstrap
+// addresses, giving up when ctx is done. TLS servers that can't be
+// authenticated are accepted anyway if authenticate is false.
+func (c *Client) dialStream(ctx context.Context, server string, authenticate bool) (*StreamConn, error) {
+	network, addr := "tcp", ServerAddr(server)
+	serverName := ""
+	switch {
+	case strings.HasPrefix(server, "unix:"):
+		network, addr = "unix", strings.TrimPrefix(server, "unix:")
+	case strings.HasPrefix(server, "tls:"):
+		addr, serverName = DoTAddr(server)
+	}
+	conn, err := c.dial(ctx, network, addr)
+	if err != nil {
+		return nil, contextError(ctx, timeoutError(err, "dial", server, c.Timeouts.Dial))
+	}
+
+	if strings.HasPrefix(server, "tls:") {
+		tlsConn := tls.Client(conn, c.tlsConfig(serverName, authenticate))
+		tlsConn.SetDeadline(deadline(c.Timeouts.Handshake))
+		err = tlsConn.HandshakeContext(ctx)
+		if err != nil {
+			conn.Close()
+			return nil, contextError(ctx, timeoutError(err, "tls handshake with", server, c.Timeouts.Handshake))
+		}
+		tlsConn.SetDeadline(time.Time{})
+		return &StreamConn{Server: server, conn: tlsConn, timeouts: c.Timeouts, authenticated: authenticate}, nil
+	}
+	return &StreamConn{Server: server, conn: conn, timeouts: c.Timeouts}, nil
+}
+
+// Exchange sends request on the connection, with an edns-tcp-keepalive option
+// if it has EDNS, and reads the response. The timeout the server returns in its own option
+// decides how long the connection may sit idle before it must be closed.
+func (c *StreamConn) Exchange(request DnsRequest) (DnsResponse, error) {
+	var response DnsResponse
+	if request.HasEDNS() {
+		request.Additionals = append([]DnsResourceRecord{}, request.Additionals...)
+		request.AddEDNSOption(EDNSOption{Code: EDNSTCPKeepalive, Data: []byte{}})
+	}
+
+	start := time.Now()
+	err := c.writeMessage(SerializeRequest(request))
+	if err != nil {
+		return response, err
+	}
+	// A reused connection may still carry the late answer to an earlier query
+	for {
+		reply, err := c.readMessage()
+		if err != nil {
+			return response, err
+		}
+		response, err = ReadResponse(reply)
+		if err != nil {
+			return response, err
+		}
+		if MatchesRequest(response, request) {
+			break
+		}
+	}
+	response.Server = c.Server
+	response.RTT = time.Since(start)
+	response.Authenticated = c.authenticated
+	response.Transport = c.transport()
+
+	// The timeout is in units of 100 milliseconds. Without it the server has
+	// not agreed to keep the connection open.
+	c.idleUntil = time.Now()
+	if timeout, ok := response.EDNSOption(EDNSTCPKeepalive); ok && len(timeout) == 2 {
+		c.idleUntil = c.idleUntil.Add(time.Duration(binary.BigEndian.Uint16(timeout)) * 100 * time.Millisecond)
+	}
+	return response, nil
+}
+
+// ReadMessageTCP reads one message from a stream connection. Messages are
+// prefixed with their length (RFC 1035 section 4.2.2) and may arrive split
+// across segments, so this reads exactly as much as the prefix says. A clean
+// io.EOF means the connection was closed between messages.
+func ReadMessageTCP(r io.Reader) ([]byte, error) {
+	var prefix [2]byte
+	_, err := io.ReadFull(r, prefix[:])
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(prefix[:]))
+	_, err = io.ReadFull(r, msg)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// WriteMessageTCP writes msg with its length prefix in a single write, so
+// the two don't go out as separate segments.
+func WriteMessageTCP(w io.Writer, msg []byte) error {
+	if len(msg) > 65535 {
+		return fmt.Errorf("message of %d bytes is too long for TCP", len(msg))
+	}
+	buf := make([]byte, 2, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	_, err := w.Write(append(buf, msg...))
+	return err
+}
+
+func (c *StreamConn) writeMessage(msg []byte) error {
+	c.conn.SetWriteDeadline(deadline(c.timeouts.Write))
+	err := WriteMessageTCP(c.conn, msg)
+	return timeoutError(err, "write", c.Server, c.timeouts.Write)
+}
+
+func (c *StreamConn) readMessage() ([]byte, error) {
+	c.conn.SetReadDeadline(deadline(c.timeouts.Read))
+	msg, err := ReadMessageTCP(c.conn)
+	if err != nil {
+		return nil, timeoutError(err, "read", c.Server, c.timeouts.Read)
+	}
+	return msg, nil
+}
+
+func (c *StreamConn) transport() string {
+	switch {
+	case strings.HasPrefix(c.Server, "unix:"):
+		return "unix"
+	case strings.HasPrefix(c.Server, "tls:"):
+		return "tls"
+	}
+	return "tcp"
+}
+
+// Reusable reports whether the connection is still within the idle timeout
+// the server asked for.
+func (c *StreamConn) Reusable() bool {
+	return time.Now().Before(c.idleUntil)
+}
+
+func (c *StreamConn) Close() error {
+	return c.conn.Close()
+}
+
+// sendStream sends request over a kept alive connection to server if there is
+// one, or a new connection otherwise. The connection is closed if ctx is done
+// before the answer arrives.
+func (c *Client) sendStream(ctx context.Context, server string, request DnsRequest, authenticate bool) (DnsResponse, error) {
+	c.mu.Lock()
+	conn := c.conns[server]
+	delete(c.conns, server)
+	c.mu.Unlock()
+
+	if conn != nil && (!conn.Reusable() || authenticate && strings.HasPrefix(server, "tls:") && !conn.authenticated) {
+		conn.Close()
+		conn = nil
+	}
+	if conn != nil {
+		response, err := conn.exchange(ctx, request)
+		if err == nil {
+			c.keepStream(conn)
+			return response, nil
+		}
+		// The server may have closed it in the meantime, retry on a new connection
+		conn.Close()
+		if ctx.Err() != nil {
+			return response, ctx.Err()
+		}
+	}
+
+	conn, err := c.dialStream(ctx, server, authenticate)
+	if err != nil {
+		return DnsResponse{}, err
+	}
+	response, err := conn.exchange(ctx, request)
+	if err != nil {
+		conn.Close()
+		return response, err
+	}
+	c.keepStream(conn)
+	return response, nil
+}
+
+// exchange is Exchange, closing the connection if ctx is done first.
+func (c *StreamConn) exchange(ctx context.Context, request DnsRequest) (DnsResponse, error) {
+	stop := closeOnDone(ctx, c.conn)
+	response, err := c.Exchange(request)
+	if stop() {
+		return response, ctx.Err()
+	}
+	return response, err
+}
+
+func (c *Client) keepStream(conn *StreamConn) {
+	if !conn.Reusable() {
+		conn.Close()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conns == nil {
+		c.conns = make(map[string]*StreamConn)
+	}
+	if old := c.conns[conn.Server]; old != nil {
+		old.Close()
+	}
+	c.conns[conn.Server] = conn
+}
+
+// Close closes any connections the client is keeping alive.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for server, conn := range c.conns {
+		conn.Close()
+		delete(c.conns, server)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Timeouts limits how long each phase of a query may take, zero means no
+// limit. Dial only applies to stream connections, there is nothing to set up
+// before sending over UDP, and Handshake only to TLS.
+type Timeouts struct {
+	Dial      time.Duration
+	Handshake time.Duration
+	Write     time.Duration
+	Read      time.Duration
+}
+
+// TimeoutError tells which phase of a query ran out of time, so a server that
+// can't be reached can be told apart from one that is slow to answer.
+type TimeoutError struct {
+	Phase  string // "dial", "tls handshake with", "write" or "read"
+	Server string
+	After  time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s %s: timed out after %s", e.Phase, e.Server, e.After)
+}
+
+// Timeout and Temporary make TimeoutError a net.Error.
+func (e *TimeoutError) Timeout() bool   { return true }
+func (e *TimeoutError) Temporary() bool { return true }
+
+// timeoutError turns err into a *TimeoutError if it is a timeout.
+func timeoutError(err error, phase string, server string, after time.Duration) error {
+	var netErr net.Error
+	if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EWOULDBLOCK) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &TimeoutError{Phase: phase, Server: server, After: after}
+	}
+	return err
+}
+
+// deadline returns when a phase limited to d must be over, or the zero time
+// for no deadline.
+func deadline(d time.Duration) time.Time {
+	if d == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// closeOnDone closes conn once ctx is done, waking up reads and writes
+// blocked on it, so abandoned attempts don't hold on to their sockets until
+// their timeouts. stop ends the watch and reports whether conn was closed.
+func closeOnDone(ctx context.Context, conn io.Closer) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	done := make(chan struct{})
+	closed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			closed <- true
+		case <-done:
+			closed <- false
+		}
+	}()
+	return func() bool {
+		close(done)
+		return <-closed
+	}
+}
+
+// contextError returns the error of ctx if it is done, which is why
+// whatever failed with err failed.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
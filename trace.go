@@ -0,0 +1,162 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// RootHints are the IPv4 addresses of the root servers, where Trace starts.
+var RootHints = []NameServer{
+	{"a.root-servers.net", "198.41.0.4"},
+	{"b.root-servers.net", "170.247.170.2"},
+	{"c.root-servers.net", "192.33.4.12"},
+	{"d.root-servers.net", "199.7.91.13"},
+	{"e.root-servers.net", "192.203.230.10"},
+	{"f.root-servers.net", "192.5.5.241"},
+	{"g.root-servers.net", "192.112.36.4"},
+	{"h.root-servers.net", "198.97.190.53"},
+	{"i.root-servers.net", "192.36.148.17"},
+	{"j.root-servers.net", "192.58.128.30"},
+	{"k.root-servers.net", "193.0.14.129"},
+	{"l.root-servers.net", "199.7.83.42"},
+	{"m.root-servers.net", "202.12.27.33"},
+}
+
+// maxReferrals stops Trace from following delegations forever.
+const maxReferrals = 30
+
+// NameServer is a name server of a zone and one of its addresses.
+type NameServer struct {
+	Name string
+	Addr string
+}
+
+// TraceStep is one query of a trace: the zone whose servers were asked, the
+// servers tried in order and the response of the one that answered.
+type TraceStep struct {
+	Zone     string
+	Servers  []NameServer
+	Tried    []NameServer
+	Answered NameServer
+	Response DnsResponse
+}
+
+// Trace resolves name iteratively like dig +trace: starting at the root
+// servers it sends non-recursive queries and follows the referrals down to
+// the servers that answer authoritatively. Name servers without glue are
+// looked up with c's own servers. The steps taken so far are returned
+// along with any error.
+func Trace(ctx context.Context, c *Client, name string, qtype uint16) ([]TraceStep, error) {
+	var steps []TraceStep
+	zone, servers := "", RootHints
+	for i := 0; i < maxReferrals; i++ {
+		step := TraceStep{Zone: zone, Servers: servers}
+		request := NewQuery(name, qtype).SetRD(false).SetEDNS(c.UDPSize)
+		var err error
+		for _, ns := range servers {
+			if ctx.Err() != nil {
+				return steps, ctx.Err()
+			}
+			step.Tried = append(step.Tried, ns)
+			step.Response, err = c.send(ctx, ServerAddr(ns.Addr), *request)
+			if err == nil {
+				step.Answered = ns
+				break
+			}
+		}
+		if err != nil {
+			return steps, fmt.Errorf("no server for %s answered: %v", FormatName(zone), err)
+		}
+		steps = append(steps, step)
+
+		response := step.Response
+		if response.Header.Flags.AA() == 1 || len(response.Answers) > 0 || response.Header.Flags.RCode() != 0 {
+			return steps, nil
+		}
+		zone, servers = referral(ctx, c, response, zone, name)
+		if len(servers) == 0 {
+			return steps, fmt.Errorf("%s sent no usable referral for %s", step.Answered.Name, name)
+		}
+	}
+	return steps, errors.New("too many referrals")
+}
+
+// referral returns the zone a response delegates to and the addresses of its
+// name servers. Only zones below the current one and above name count, so a
+// lame server can't send the trace back up or sideways.
+func referral(ctx context.Context, c *Client, response DnsResponse, zone string, name string) (string, []NameServer) {
+	var child string
+	var names []string
+	for _, r := range response.Authorities {
+		if r.Type != NS || !Name(name).IsSubdomainOf(Name(r.Name)) || Name(r.Name).Equal(Name(zone)) || !Name(r.Name).IsSubdomainOf(Name(zone)) {
+			continue
+		}
+		if child != "" && !Name(r.Name).Equal(Name(child)) {
+			continue
+		}
+		// Name rdata is kept as the name itself, see parseNameRData
+		child = r.Name
+		names = append(names, string(r.RData))
+	}
+
+	var servers []NameServer
+	for _, ns := range names {
+		found := false
+		for _, r := range response.Additionals {
+			if r.Type == A && Name(r.Name).Equal(Name(ns)) && len(r.RData) == 4 {
+				servers = append(servers, NameServer{ns, FormatRData(A, r.RData)})
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+		// Out of bailiwick name servers come without glue
+		lookup, err := c.Query(ctx, ns, A)
+		if err != nil {
+			continue
+		}
+		for _, r := range lookup.Answers {
+			if r.Type == A {
+				servers = append(servers, NameServer{ns, FormatRData(A, r.RData)})
+			}
+		}
+	}
+	return child, servers
+}
+
+// FormatTrace prints the steps of a trace: which server of each zone
+// answered, how fast, and the referral or answer it gave.
+func FormatTrace(w io.Writer, steps []TraceStep) {
+	for i, step := range steps {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		r := step.Response
+		fmt.Fprintf(w, "---- %s from %s (%s) in %s ----\n", FormatName(step.Zone), step.Answered.Name, step.Answered.Addr, r.RTT.Round(time.Microsecond))
+		records := r.Answers
+		if len(records) == 0 {
+			records = r.Authorities
+		}
+		for _, rr := range records {
+			if rr.Type == RRSIG {
+				continue
+			}
+			fmt.Fprintf(w, "  { %s }\n", rr)
+		}
+		if rcode := r.Header.Flags.RCode(); rcode != 0 {
+			fmt.Fprintf(w, "  RCode: %s\n", RCodeToString(rcode))
+		}
+		if len(step.Tried) > 1 {
+			var failed []string
+			for _, ns := range step.Tried[:len(step.Tried)-1] {
+				failed = append(failed, ns.Name)
+			}
+			fmt.Fprintf(w, "  No answer from %s\n", strings.Join(failed, ", "))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package dnsclient
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Formats for the delegation graph of a trace
+const (
+	TraceGraphDOT  = "dot"
+	TraceGraphTree = "tree"
+)
+
+// serverState says what happened to one of the name servers of a step.
+func (step TraceStep) serverState(ns NameServer) string {
+	for i, tried := range step.Tried {
+		if tried != ns {
+			continue
+		}
+		if i == len(step.Tried)-1 {
+			return "answered"
+		}
+		return "no answer"
+	}
+	return "not asked"
+}
+
+// outcome describes what the server that answered the step said: the
+// referral it gave, or the final answer.
+func (step TraceStep) outcome(next *TraceStep) string {
+	r := step.Response
+	if next != nil {
+		return "referral to " + FormatName(next.Zone)
+	}
+	if r.Header.Flags.AA() == 1 || len(r.Answers) > 0 || r.Header.Flags.RCode() != 0 {
+		return fmt.Sprintf("answer: %s, %d records", RCodeToString(r.Header.Flags.RCode()), len(r.Answers))
+	}
+	return "referral, not followed"
+}
+
+// FormatTraceDOT writes the delegation graph of a trace in Graphviz DOT:
+// the zones, the name servers of each, which of them were asked and which
+// answered, with the referrals from one zone to the next.
+func FormatTraceDOT(w io.Writer, steps []TraceStep) {
+	fmt.Fprintln(w, "digraph delegation {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for i, step := range steps {
+		zone := dotQuote("zone " + FormatName(step.Zone))
+		fmt.Fprintf(w, "\t%s [label=%s, shape=ellipse];\n", zone, dotQuote(FormatName(step.Zone)))
+		for _, ns := range step.Servers {
+			node := dotQuote(fmt.Sprintf("%s %s %s", FormatName(step.Zone), ns.Name, ns.Addr))
+			label := ns.Name + "\\n" + ns.Addr
+			style := "style=dashed, color=gray"
+			switch step.serverState(ns) {
+			case "answered":
+				label += "\\n" + step.Response.RTT.Round(time.Microsecond).String()
+				style = "style=bold, color=darkgreen"
+			case "no answer":
+				label += "\\nno answer"
+				style = "color=red"
+			}
+			fmt.Fprintf(w, "\t%s [label=\"%s\", %s];\n", node, strings.ReplaceAll(label, `"`, `\"`), style)
+			fmt.Fprintf(w, "\t%s -> %s [%s];\n", zone, node, style)
+		}
+
+		from := dotQuote(fmt.Sprintf("%s %s %s", FormatName(step.Zone), step.Answered.Name, step.Answered.Addr))
+		if i+1 < len(steps) {
+			fmt.Fprintf(w, "\t%s -> %s [label=\"referral\"];\n", from, dotQuote("zone "+FormatName(steps[i+1].Zone)))
+			continue
+		}
+		result := dotQuote("result")
+		fmt.Fprintf(w, "\t%s [label=%s, shape=note];\n", result, dotQuote(step.outcome(nil)))
+		fmt.Fprintf(w, "\t%s -> %s;\n", from, result)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// FormatTraceTree writes the delegation path of a trace as an ASCII tree,
+// each zone under the one that referred to it, with its name servers and
+// what became of the query to each.
+func FormatTraceTree(w io.Writer, steps []TraceStep) {
+	indent := ""
+	for i, step := range steps {
+		fmt.Fprintf(w, "%s%s\n", indent, FormatName(step.Zone))
+		var next *TraceStep
+		if i+1 < len(steps) {
+			next = &steps[i+1]
+		}
+		for _, ns := range step.Servers {
+			state := step.serverState(ns)
+			if state == "answered" {
+				state += " in " + step.Response.RTT.Round(time.Microsecond).String()
+			}
+			fmt.Fprintf(w, "%s|-- %s (%s) %s\n", indent, ns.Name, ns.Addr, state)
+		}
+		fmt.Fprintf(w, "%s`-- %s\n", indent, step.outcome(next))
+		indent += "    "
+	}
+}
@@ -0,0 +1,63 @@
+package dnsclient
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Transport sends a serialized query and returns the serialized reply, like
+// http.RoundTripper does for HTTP. The client's own UDP, TCP, DoT and DoH
+// transports are behind Client.Transport, and other ones (test fakes,
+// tunnels) can be plugged in per server with WithServerTransport.
+type Transport interface {
+	Exchange(ctx context.Context, msg []byte) ([]byte, error)
+}
+
+// TransportFunc lets an ordinary function be a Transport.
+type TransportFunc func(ctx context.Context, msg []byte) ([]byte, error)
+
+func (f TransportFunc) Exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	return f(ctx, msg)
+}
+
+// Transport returns the transport the client uses for server: UDP or TCP
+// for plain servers, DoT for "tls:" and DoH for "https://" ones, with the
+// client's settings.
+func (c *Client) Transport(server string) Transport {
+	if t, ok := c.Transports[server]; ok {
+		return t
+	}
+	return TransportFunc(func(ctx context.Context, msg []byte) ([]byte, error) {
+		request, err := ReadRequest(msg)
+		if err != nil {
+			return nil, err
+		}
+		response, err := c.sendBuiltin(ctx, server, request)
+		if err != nil {
+			return nil, err
+		}
+		return SerializeResponse(response), nil
+	})
+}
+
+// exchangeTransport sends request through t and checks that the reply
+// answers it.
+func exchangeTransport(ctx context.Context, t Transport, server string, request DnsRequest) (DnsResponse, error) {
+	start := time.Now()
+	reply, err := t.Exchange(ctx, SerializeRequest(request))
+	if err != nil {
+		return DnsResponse{}, err
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		return response, err
+	}
+	if !MatchesRequest(response, request) {
+		return response, errors.New("response does not match the request")
+	}
+	response.Server = server
+	response.RTT = time.Since(start)
+	response.Transport = "custom"
+	return response, nil
+}
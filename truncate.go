@@ -0,0 +1,116 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// MaxMessageSize is the most a message can be, over TCP, DoT or DoH, with
+// its size in two bytes.
+const MaxMessageSize = 65535
+
+// SerializeTruncated encodes response with name compression in at most
+// size bytes, or MaxMessageSize. Whole RRsets that don't fit are left out,
+// starting from the end, and TC is set if any of them were answers or
+// authorities; only dropping additionals doesn't need the client to retry
+// (RFC 2181 section 9). The OPT record is always kept.
+func SerializeTruncated(response DnsResponse, size int) []byte {
+	if size <= 0 || size > MaxMessageSize {
+		size = MaxMessageSize
+	}
+	var opt []DnsResourceRecord
+	var additionals []DnsResourceRecord
+	for _, a := range response.Additionals {
+		if a.Type == OPT {
+			opt = append(opt, a)
+		} else {
+			additionals = append(additionals, a)
+		}
+	}
+	var optBuf bytes.Buffer
+	for _, r := range opt {
+		SerializeResourceRecord(&optBuf, r)
+	}
+
+	var buf bytes.Buffer
+	header := response.Header
+	header.QdCount = uint16(len(response.Questions))
+	header.AnCount, header.NsCount, header.ArCount = 0, 0, 0
+	binary.Write(&buf, binary.BigEndian, header)
+	c := Compression{}
+	for _, q := range response.Questions {
+		WriteQuestion(&buf, q, c)
+	}
+
+	counts := []*uint16{&header.AnCount, &header.NsCount, &header.ArCount}
+	truncated := false
+sections:
+	for i, section := range [][]DnsResourceRecord{response.Answers, response.Authorities, additionals} {
+		for _, rrset := range splitRRsets(section) {
+			mark := buf.Len()
+			for _, r := range rrset {
+				WriteResourceRecord(&buf, r, c)
+			}
+			if buf.Len()+optBuf.Len() <= size {
+				*counts[i] += uint16(len(rrset))
+				continue
+			}
+			buf.Truncate(mark)
+			for name, offset := range c {
+				if offset >= mark {
+					delete(c, name)
+				}
+			}
+			truncated = i < 2
+			break sections
+		}
+	}
+	if truncated {
+		header.Flags |= FlagTC
+	}
+	if buf.Len()+optBuf.Len() <= size {
+		buf.Write(optBuf.Bytes())
+		header.ArCount += uint16(len(opt))
+	}
+	msg := buf.Bytes()
+	var h bytes.Buffer
+	binary.Write(&h, binary.BigEndian, header)
+	copy(msg, h.Bytes())
+	return msg
+}
+
+// splitRRsets groups records by owner name, type and class, in the order
+// the RRsets first appear.
+func splitRRsets(records []DnsResourceRecord) [][]DnsResourceRecord {
+	type key struct {
+		name         string
+		rtype, class uint16
+	}
+	index := map[key]int{}
+	var rrsets [][]DnsResourceRecord
+	for _, r := range records {
+		k := key{strings.ToLower(strings.TrimSuffix(r.Name, ".")), r.Type, r.Class}
+		i, ok := index[k]
+		if !ok {
+			i = len(rrsets)
+			index[k] = i
+			rrsets = append(rrsets, nil)
+		}
+		rrsets[i] = append(rrsets[i], r)
+	}
+	return rrsets
+}
+
+// fitReply makes reply, the answer to request, fit in size bytes, falling
+// back to an empty truncated reply if it can't be parsed.
+func fitReply(reply []byte, request DnsRequest, size int) []byte {
+	if len(reply) <= size {
+		return reply
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		return Truncated(request)
+	}
+	return SerializeTruncated(response, size)
+}
@@ -0,0 +1,269 @@
+package dnsclient
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+)
+
+const TSIG = 250
+
+// TSIG algorithm names (RFC 8945 section 6)
+const (
+	HmacMD5    = "hmac-md5.sig-alg.reg.int"
+	HmacSHA1   = "hmac-sha1"
+	HmacSHA224 = "hmac-sha224"
+	HmacSHA256 = "hmac-sha256"
+	HmacSHA384 = "hmac-sha384"
+	HmacSHA512 = "hmac-sha512"
+)
+
+// TSIG errors, in the error field of the TSIG record
+var tsigErrors = map[uint16]string{
+	16: "BADSIG",
+	17: "BADKEY",
+	18: "BADTIME",
+	22: "BADTRUNC",
+}
+
+// How far apart the clocks of the signer and the verifier may be
+const tsigFudge = 300
+
+// TSIGKey is a shared secret for signing messages (RFC 8945).
+type TSIGKey struct {
+	Name      string
+	Algorithm string
+	Secret    []byte
+}
+
+// ParseTSIGKey parses a key given as [algorithm:]name:base64-secret, like
+// nsupdate -y. The algorithm defaults to hmac-sha256.
+func ParseTSIGKey(s string) (TSIGKey, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) == 2 {
+		parts = append([]string{HmacSHA256}, parts...)
+	}
+	if len(parts) != 3 {
+		return TSIGKey{}, fmt.Errorf("key %q must be [algorithm:]name:secret", s)
+	}
+	return NewTSIGKey(parts[1], parts[0], parts[2])
+}
+
+// NewTSIGKey returns the key name with the base64 secret, checking that the
+// algorithm is one of the supported ones. hmac-md5 may be given without
+// its .sig-alg.reg.int suffix.
+func NewTSIGKey(name, algorithm, secret string) (TSIGKey, error) {
+	algorithm = strings.TrimSuffix(strings.ToLower(algorithm), ".")
+	if algorithm == "hmac-md5" {
+		algorithm = HmacMD5
+	}
+	k := TSIGKey{Name: strings.TrimSuffix(name, "."), Algorithm: algorithm}
+	if _, err := k.hash(); err != nil {
+		return k, err
+	}
+	var err error
+	k.Secret, err = base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return k, fmt.Errorf("key %s: invalid base64 secret", name)
+	}
+	return k, nil
+}
+
+func (k TSIGKey) hash() (func() hash.Hash, error) {
+	switch k.Algorithm {
+	case HmacMD5:
+		return md5.New, nil
+	case HmacSHA1:
+		return sha1.New, nil
+	case HmacSHA224:
+		return sha256.New224, nil
+	case HmacSHA256:
+		return sha256.New, nil
+	case HmacSHA384:
+		return sha512.New384, nil
+	case HmacSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported tsig algorithm %q", k.Algorithm)
+}
+
+// tsigVariables are the TSIG fields the MAC covers besides the message
+// (RFC 8945 section 4.3.3), with the names in canonical form.
+func (k TSIGKey) tsigVariables(signed uint64, fudge uint16, tsigError uint16, other []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(SerializeName(strings.ToLower(k.Name)))
+	binary.Write(&buf, binary.BigEndian, uint16(ANY))
+	binary.Write(&buf, binary.BigEndian, uint32(0))
+	buf.Write(SerializeName(strings.ToLower(k.Algorithm)))
+	buf.Write([]byte{byte(signed >> 40), byte(signed >> 32), byte(signed >> 24), byte(signed >> 16), byte(signed >> 8), byte(signed)})
+	binary.Write(&buf, binary.BigEndian, fudge)
+	binary.Write(&buf, binary.BigEndian, tsigError)
+	binary.Write(&buf, binary.BigEndian, uint16(len(other)))
+	buf.Write(other)
+	return buf.Bytes()
+}
+
+func (k TSIGKey) mac(requestMAC []byte, msg []byte, variables []byte) ([]byte, error) {
+	h, err := k.hash()
+	if err != nil {
+		return nil, err
+	}
+	m := hmac.New(h, k.Secret)
+	if requestMAC != nil {
+		binary.Write(m, binary.BigEndian, uint16(len(requestMAC)))
+		m.Write(requestMAC)
+	}
+	m.Write(msg)
+	m.Write(variables)
+	return m.Sum(nil), nil
+}
+
+// Sign appends a TSIG record to the serialized message msg, which must not
+// have one yet, and returns the signed message and its MAC, which the
+// response is signed with too.
+func (k TSIGKey) Sign(msg []byte, now time.Time) ([]byte, []byte, error) {
+	return k.sign(msg, nil, now)
+}
+
+// sign is Sign for a response to a request signed with the MAC requestMAC.
+func (k TSIGKey) sign(msg []byte, requestMAC []byte, now time.Time) ([]byte, []byte, error) {
+	if len(msg) < 12 {
+		return nil, nil, errors.New("message too short to sign")
+	}
+	signed := uint64(now.Unix())
+	mac, err := k.mac(requestMAC, msg, k.tsigVariables(signed, tsigFudge, 0, nil))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rdata bytes.Buffer
+	rdata.Write(SerializeName(strings.ToLower(k.Algorithm)))
+	rdata.Write([]byte{byte(signed >> 40), byte(signed >> 32), byte(signed >> 24), byte(signed >> 16), byte(signed >> 8), byte(signed)})
+	binary.Write(&rdata, binary.BigEndian, uint16(tsigFudge))
+	binary.Write(&rdata, binary.BigEndian, uint16(len(mac)))
+	rdata.Write(mac)
+	rdata.Write(msg[:2]) // original id
+	binary.Write(&rdata, binary.BigEndian, uint16(0))
+	binary.Write(&rdata, binary.BigEndian, uint16(0))
+
+	var buf bytes.Buffer
+	buf.Write(msg)
+	SerializeResourceRecord(&buf, DnsResourceRecord{Name: k.Name, Type: TSIG, Class: ANY, RDLength: uint16(rdata.Len()), RData: rdata.Bytes()})
+	signedMsg := buf.Bytes()
+	binary.BigEndian.PutUint16(signedMsg[10:], binary.BigEndian.Uint16(msg[10:])+1)
+	return signedMsg, mac, nil
+}
+
+// Verify checks the TSIG record that ends msg, a response to a request
+// signed with the MAC requestMAC.
+func (k TSIGKey) Verify(msg []byte, requestMAC []byte, now time.Time) error {
+	offset, tsig, err := findTSIG(msg)
+	if err != nil {
+		return err
+	}
+	if !Name(tsig.Name).Equal(Name(k.Name)) {
+		return fmt.Errorf("response signed with key %s, not %s", tsig.Name, k.Name)
+	}
+	r := bytes.NewReader(tsig.RData)
+	algorithm, err := ReadName(r)
+	if err != nil {
+		return err
+	}
+	var fields struct {
+		Signed  [6]byte
+		Fudge   uint16
+		MACSize uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &fields); err != nil {
+		return errors.New("truncated tsig record")
+	}
+	mac := make([]byte, fields.MACSize)
+	var tail struct {
+		OriginalID uint16
+		Error      uint16
+		OtherLen   uint16
+	}
+	if _, err := io.ReadFull(r, mac); err != nil {
+		return errors.New("truncated tsig record")
+	}
+	if err := binary.Read(r, binary.BigEndian, &tail); err != nil {
+		return errors.New("truncated tsig record")
+	}
+	other := make([]byte, tail.OtherLen)
+	if _, err := io.ReadFull(r, other); err != nil {
+		return errors.New("truncated tsig record")
+	}
+	if tail.Error != 0 {
+		name, ok := tsigErrors[tail.Error]
+		if !ok {
+			name = RCodeToString(tail.Error)
+		}
+		return fmt.Errorf("server rejected the signature: %s", name)
+	}
+	if !Name(algorithm).Equal(Name(k.Algorithm)) {
+		return fmt.Errorf("response signed with %s, not %s", algorithm, k.Algorithm)
+	}
+
+	var signed uint64
+	for _, b := range fields.Signed {
+		signed = signed<<8 | uint64(b)
+	}
+	// The MAC covers the message as it was before the TSIG record was added
+	unsigned := append([]byte{}, msg[:offset]...)
+	binary.BigEndian.PutUint16(unsigned, tail.OriginalID)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+	expected, err := k.mac(requestMAC, unsigned, k.tsigVariables(signed, fields.Fudge, tail.Error, other))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(mac, expected) {
+		return errors.New("tsig signature of the response does not verify")
+	}
+	if d := now.Unix() - int64(signed); d > int64(fields.Fudge) || -d > int64(fields.Fudge) {
+		return fmt.Errorf("tsig time of the response is %ds off", d)
+	}
+	return nil
+}
+
+// findTSIG returns the TSIG record msg ends with and where it starts.
+func findTSIG(msg []byte) (int, DnsResourceRecord, error) {
+	var tsig DnsResourceRecord
+	r := bytes.NewReader(msg)
+	var header DnsHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, tsig, err
+	}
+	if header.ArCount == 0 {
+		return 0, tsig, errors.New("response is not signed")
+	}
+	for i := 0; i < int(header.QdCount); i++ {
+		if _, err := ReadQuestion(r); err != nil {
+			return 0, tsig, err
+		}
+	}
+	for i := 0; i < int(header.AnCount)+int(header.NsCount)+int(header.ArCount)-1; i++ {
+		if _, err := ReadResourceRecord(r); err != nil {
+			return 0, tsig, err
+		}
+	}
+	offset := len(msg) - r.Len()
+	tsig, err := ReadResourceRecord(r)
+	if err != nil {
+		return 0, tsig, err
+	}
+	if tsig.Type != TSIG {
+		return 0, tsig, errors.New("response is not signed")
+	}
+	return offset, tsig, nil
+}
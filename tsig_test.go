@@ -0,0 +1,85 @@
+package dnsclient
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTSIGKey(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	tests := []struct {
+		key       string
+		name      string
+		algorithm string
+		err       bool
+	}{
+		{"update-key:" + secret, "update-key", HmacSHA256, false},
+		{"hmac-sha512:update-key.:" + secret, "update-key", HmacSHA512, false},
+		{"HMAC-MD5:k:" + secret, "k", HmacMD5, false},
+		{"hmac-sha3:k:" + secret, "", "", true},
+		{"k:not base64!", "", "", true},
+		{"k", "", "", true},
+	}
+	for _, test := range tests {
+		key, err := ParseTSIGKey(test.key)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseTSIGKey(%q) = %+v, want an error", test.key, key)
+			}
+			continue
+		}
+		if err != nil || key.Name != test.name || key.Algorithm != test.algorithm || string(key.Secret) != "0123456789abcdef" {
+			t.Errorf("ParseTSIGKey(%q) = %+v, %v", test.key, key, err)
+		}
+	}
+}
+
+func TestTSIGSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	msg := SerializeRequest(*NewQuery("example.com", SOA))
+	for _, algorithm := range []string{HmacMD5, HmacSHA1, HmacSHA224, HmacSHA256, HmacSHA384, HmacSHA512} {
+		t.Run(algorithm, func(t *testing.T) {
+			key := TSIGKey{Name: "key.example", Algorithm: algorithm, Secret: []byte("secret")}
+			signed, mac, err := key.Sign(msg, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mac) == 0 {
+				t.Fatal("no MAC")
+			}
+			if request, err := ReadRequest(signed); err != nil || len(request.Additionals) != 1 || request.Additionals[0].Type != TSIG {
+				t.Fatalf("signed message doesn't end with a TSIG record: %v", err)
+			}
+			if err := key.Verify(signed, nil, now.Add(time.Minute)); err != nil {
+				t.Fatalf("Verify() = %v", err)
+			}
+
+			tampered := append([]byte{}, signed...)
+			tampered[3] ^= 0x10
+			wrongSecret := key
+			wrongSecret.Secret = []byte("another secret")
+			wrongName := key
+			wrongName.Name = "other.example"
+			for _, bad := range []struct {
+				name string
+				key  TSIGKey
+				msg  []byte
+				now  time.Time
+				err  string
+			}{
+				{"tampered", key, tampered, now, "does not verify"},
+				{"wrong secret", wrongSecret, signed, now, "does not verify"},
+				{"wrong key name", wrongName, signed, now, "signed with key"},
+				{"late", key, signed, now.Add(time.Hour), "off"},
+				{"unsigned", key, msg, now, ""},
+			} {
+				err := bad.key.Verify(bad.msg, nil, bad.now)
+				if err == nil || !strings.Contains(err.Error(), bad.err) {
+					t.Errorf("%s: Verify() = %v, want error %q", bad.name, err, bad.err)
+				}
+			}
+		})
+	}
+}
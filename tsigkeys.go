@@ -0,0 +1,188 @@
+package dnsclient
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HMAC algorithm numbers of dnssec-keygen's K*.private files
+var privateKeyAlgorithms = map[string]string{
+	"157": HmacMD5,
+	"161": HmacSHA1,
+	"162": HmacSHA224,
+	"163": HmacSHA256,
+	"164": HmacSHA384,
+	"165": HmacSHA512,
+}
+
+// ParseBINDKeys reads the key clauses of a BIND configuration file, such as
+// what tsig-keygen writes:
+//
+//	key "ddns-key" {
+//		algorithm hmac-sha256;
+//		secret "c2VjcmV0c2VjcmV0c2VjcmV0";
+//	};
+//
+// Everything else in the file is skipped.
+func ParseBINDKeys(r io.Reader) ([]TSIGKey, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens := bindTokens(string(data))
+	var keys []TSIGKey
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		case "key":
+			if depth != 0 || i+2 >= len(tokens) || tokens[i+2] != "{" {
+				continue
+			}
+			name := strings.Trim(tokens[i+1], `"`)
+			var algorithm, secret string
+			i += 3
+			for ; i < len(tokens) && tokens[i] != "}"; i++ {
+				if i+1 >= len(tokens) {
+					break
+				}
+				switch tokens[i] {
+				case "algorithm":
+					algorithm = strings.Trim(tokens[i+1], `"`)
+				case "secret":
+					secret = strings.Trim(tokens[i+1], `"`)
+				}
+			}
+			if algorithm == "" || secret == "" {
+				return keys, fmt.Errorf("key %s needs an algorithm and a secret", name)
+			}
+			key, err := NewTSIGKey(name, algorithm, secret)
+			if err != nil {
+				return keys, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// bindTokens splits BIND configuration text into words, quoted strings and
+// braces, dropping semicolons and #, // and /* */ comments.
+func bindTokens(s string) []string {
+	var tokens []string
+	for len(s) > 0 {
+		switch c := s[0]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ';':
+			s = s[1:]
+		case c == '#' || strings.HasPrefix(s, "//"):
+			end := strings.IndexByte(s, '\n')
+			if end < 0 {
+				end = len(s)
+			}
+			s = s[end:]
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s, "*/")
+			if end < 0 {
+				return tokens
+			}
+			s = s[end+2:]
+		case c == '{' || c == '}':
+			tokens = append(tokens, s[:1])
+			s = s[1:]
+		case c == '"':
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				end = len(s) - 2
+			}
+			tokens = append(tokens, s[:end+2])
+			s = s[end+2:]
+		default:
+			end := strings.IndexAny(s, " \t\r\n;{}\"")
+			if end < 0 {
+				end = len(s)
+			}
+			tokens = append(tokens, s[:end])
+			s = s[end:]
+		}
+	}
+	return tokens
+}
+
+// ParsePrivateKeyFile reads an HMAC key in the format of dnssec-keygen's
+// K<name>.+<alg>+<id>.private files. The key name is not in the file, it
+// has to be given.
+func ParsePrivateKeyFile(r io.Reader, name string) (TSIGKey, error) {
+	var algorithm, secret string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		i := strings.IndexByte(scanner.Text(), ':')
+		if i < 0 {
+			continue
+		}
+		field, value := scanner.Text()[:i], strings.TrimSpace(scanner.Text()[i+1:])
+		switch field {
+		case "Algorithm":
+			var ok bool
+			if fields := strings.Fields(value); len(fields) > 0 {
+				algorithm, ok = privateKeyAlgorithms[fields[0]]
+			}
+			if !ok {
+				return TSIGKey{}, fmt.Errorf("algorithm %s is not an HMAC algorithm", value)
+			}
+		case "Key":
+			secret = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TSIGKey{}, err
+	}
+	if algorithm == "" || secret == "" {
+		return TSIGKey{}, errors.New("private key file needs an Algorithm and a Key")
+	}
+	return NewTSIGKey(name, algorithm, secret)
+}
+
+// LoadTSIGKeyFile reads the key in a file given to nsupdate -k: a BIND key
+// clause (the first one if there are several), or a K*.private file (the
+// matching .key file can be given too), which is named after the key.
+func LoadTSIGKeyFile(path string) (TSIGKey, error) {
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, "K") && strings.HasSuffix(base, ".key") {
+		path = strings.TrimSuffix(path, ".key") + ".private"
+		base = filepath.Base(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return TSIGKey{}, err
+	}
+	defer f.Close()
+
+	if strings.HasPrefix(base, "K") && strings.HasSuffix(base, ".private") {
+		// K<name>.+<algorithm>+<key id>.private
+		name := strings.TrimPrefix(base, "K")
+		if i := strings.Index(name, ".+"); i >= 0 {
+			name = name[:i]
+		}
+		key, err := ParsePrivateKeyFile(f, name)
+		if err != nil {
+			return key, fmt.Errorf("%s: %v", path, err)
+		}
+		return key, nil
+	}
+	keys, err := ParseBINDKeys(f)
+	if err != nil {
+		return TSIGKey{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(keys) == 0 {
+		return TSIGKey{}, fmt.Errorf("%s: no key clause", path)
+	}
+	return keys[0], nil
+}
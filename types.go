@@ -0,0 +1,217 @@
+package dnsclient
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resource record (and QTYPE) mnemonics from the IANA DNS parameters registry.
+var typeNames = map[uint16]string{
+	1:     "A",
+	2:     "NS",
+	3:     "MD",
+	4:     "MF",
+	5:     "CNAME",
+	6:     "SOA",
+	7:     "MB",
+	8:     "MG",
+	9:     "MR",
+	10:    "NULL",
+	11:    "WKS",
+	12:    "PTR",
+	13:    "HINFO",
+	14:    "MINFO",
+	15:    "MX",
+	16:    "TXT",
+	17:    "RP",
+	18:    "AFSDB",
+	19:    "X25",
+	20:    "ISDN",
+	21:    "RT",
+	22:    "NSAP",
+	23:    "NSAP-PTR",
+	24:    "SIG",
+	25:    "KEY",
+	26:    "PX",
+	27:    "GPOS",
+	28:    "AAAA",
+	29:    "LOC",
+	30:    "NXT",
+	31:    "EID",
+	32:    "NIMLOC",
+	33:    "SRV",
+	34:    "ATMA",
+	35:    "NAPTR",
+	36:    "KX",
+	37:    "CERT",
+	38:    "A6",
+	39:    "DNAME",
+	40:    "SINK",
+	41:    "OPT",
+	42:    "APL",
+	43:    "DS",
+	44:    "SSHFP",
+	45:    "IPSECKEY",
+	46:    "RRSIG",
+	47:    "NSEC",
+	48:    "DNSKEY",
+	49:    "DHCID",
+	50:    "NSEC3",
+	51:    "NSEC3PARAM",
+	52:    "TLSA",
+	53:    "SMIMEA",
+	55:    "HIP",
+	56:    "NINFO",
+	57:    "RKEY",
+	58:    "TALINK",
+	59:    "CDS",
+	60:    "CDNSKEY",
+	61:    "OPENPGPKEY",
+	62:    "CSYNC",
+	63:    "ZONEMD",
+	64:    "SVCB",
+	65:    "HTTPS",
+	66:    "DSYNC",
+	99:    "SPF",
+	100:   "UINFO",
+	101:   "UID",
+	102:   "GID",
+	103:   "UNSPEC",
+	104:   "NID",
+	105:   "L32",
+	106:   "L64",
+	107:   "LP",
+	108:   "EUI48",
+	109:   "EUI64",
+	128:   "NXNAME",
+	249:   "TKEY",
+	250:   "TSIG",
+	251:   "IXFR",
+	252:   "AXFR",
+	253:   "MAILB",
+	254:   "MAILA",
+	255:   "ANY",
+	256:   "URI",
+	257:   "CAA",
+	258:   "AVC",
+	259:   "DOA",
+	260:   "AMTRELAY",
+	261:   "RESINFO",
+	262:   "WALLET",
+	263:   "CLA",
+	264:   "IPN",
+	32768: "TA",
+	32769: "DLV",
+}
+
+var classNames = map[uint16]string{
+	1:   "IN",
+	2:   "CS",
+	3:   "CH",
+	4:   "HS",
+	254: "NONE",
+	255: "ANY",
+}
+
+// RCODEs that fit in the header (RFC 6895 section 2.3), the extended ones
+// need EDNS
+var rcodeNames = map[uint16]string{
+	0:  "NOERROR",
+	1:  "FORMERR",
+	2:  "SERVFAIL",
+	3:  "NXDOMAIN",
+	4:  "NOTIMP",
+	5:  "REFUSED",
+	6:  "YXDOMAIN",
+	7:  "YXRRSET",
+	8:  "NXRRSET",
+	9:  "NOTAUTH",
+	10: "NOTZONE",
+	11: "DSOTYPENI",
+	16: "BADVERS",
+}
+
+var opcodeNames = map[uint16]string{
+	OpQuery:  "QUERY",
+	1:        "IQUERY",
+	OpStatus: "STATUS",
+	OpNotify: "NOTIFY",
+	OpUpdate: "UPDATE",
+	6:        "DSO",
+}
+
+var typeNumbers = reverseNames(typeNames)
+var classNumbers = reverseNames(classNames)
+
+func reverseNames(names map[uint16]string) map[string]uint16 {
+	numbers := make(map[string]uint16, len(names))
+	for n, name := range names {
+		numbers[name] = n
+	}
+	return numbers
+}
+
+// TypeToString returns the mnemonic for t, or TYPE#### (RFC 3597) if it has none.
+func TypeToString(t uint16) string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+// StringToType accepts a mnemonic (case insensitive, "*" for ANY), TYPE#### or
+// a plain number.
+func StringToType(s string) (uint16, error) {
+	if s == "*" {
+		return ANY, nil
+	}
+	if t, ok := typeNumbers[strings.ToUpper(s)]; ok {
+		return t, nil
+	}
+	return parseGenericNumber(s, "TYPE")
+}
+
+// ClassToString returns the mnemonic for c, or CLASS#### (RFC 3597) if it has none.
+func ClassToString(c uint16) string {
+	if name, ok := classNames[c]; ok {
+		return name
+	}
+	return "CLASS" + strconv.Itoa(int(c))
+}
+
+// StringToClass accepts a mnemonic (case insensitive), CLASS#### or a plain number.
+func StringToClass(s string) (uint16, error) {
+	if c, ok := classNumbers[strings.ToUpper(s)]; ok {
+		return c, nil
+	}
+	return parseGenericNumber(s, "CLASS")
+}
+
+func parseGenericNumber(s string, prefix string) (uint16, error) {
+	n := s
+	if len(s) > len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		n = s[len(prefix):]
+	}
+	v, err := strconv.ParseUint(n, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", strings.ToLower(prefix), s)
+	}
+	return uint16(v), nil
+}
+
+// RCodeToString returns the mnemonic for rcode, or its number if it has none.
+func RCodeToString(rcode uint16) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(int(rcode))
+}
+
+// OpCodeToString returns the mnemonic for opcode, or its number if it has none.
+func OpCodeToString(opcode uint16) string {
+	if name, ok := opcodeNames[opcode]; ok {
+		return name
+	}
+	return strconv.Itoa(int(opcode))
+}
@@ -0,0 +1,56 @@
+package dnsclient
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var unixgramCount uint64
+
+// SendUnixgram sends request to the unix datagram socket at path. Datagram
+// sockets need a bound address of their own for the reply to come back to,
+// so a temporary one is created for each query.
+func SendUnixgram(path string, request DnsRequest) (DnsResponse, error) {
+	return sendUnixgram(context.Background(), path, request, Timeouts{})
+}
+
+func sendUnixgram(ctx context.Context, path string, request DnsRequest, timeouts Timeouts) (DnsResponse, error) {
+	var response DnsResponse
+	n := atomic.AddUint64(&unixgramCount, 1)
+	local := filepath.Join(os.TempDir(), "dns-client-"+strconv.Itoa(os.Getpid())+"-"+strconv.FormatUint(n, 10)+".sock")
+	conn, err := net.DialUnix("unixgram", &net.UnixAddr{Name: local, Net: "unixgram"}, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return response, err
+	}
+	defer os.Remove(local)
+	defer conn.Close()
+	defer closeOnDone(ctx, conn)()
+
+	start := time.Now()
+	conn.SetWriteDeadline(deadline(timeouts.Write))
+	_, err = conn.Write(SerializeRequest(request))
+	if err != nil {
+		return response, contextError(ctx, timeoutError(err, "write", "unixgram:"+path, timeouts.Write))
+	}
+	buf := make([]byte, 65535)
+	conn.SetReadDeadline(deadline(timeouts.Read))
+	for {
+		size, err := conn.Read(buf)
+		if err != nil {
+			return response, contextError(ctx, timeoutError(err, "read", "unixgram:"+path, timeouts.Read))
+		}
+		response, err = ReadResponse(buf[:size])
+		if err == nil && MatchesRequest(response, request) {
+			break
+		}
+	}
+	response.Server = "unixgram:" + path
+	response.RTT = time.Since(start)
+	response.Transport = "unixgram"
+	return response, nil
+}
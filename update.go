@@ -0,0 +1,159 @@
+package dnsclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Classes with a special meaning in updates (RFC 2136 section 2.4 and 2.5)
+const (
+	ClassNONE = 254
+	ClassANY  = 255
+)
+
+// DnsUpdate is a dynamic update (RFC 2136) of the records of Zone: the
+// updates are only made if all the prerequisites hold.
+type DnsUpdate struct {
+	Zone          string
+	Class         uint16
+	Prerequisites []DnsResourceRecord
+	Updates       []DnsResourceRecord
+}
+
+// Add adds r to its RRset.
+func (u *DnsUpdate) Add(r DnsResourceRecord) {
+	u.Updates = append(u.Updates, r)
+}
+
+// Delete deletes the record of type t at name with the given rdata, the
+// whole RRset if rdata is nil, or all the RRsets at name if t is ANY too.
+func (u *DnsUpdate) Delete(name string, t uint16, rdata []byte) {
+	r := DnsResourceRecord{Name: name, Type: t, Class: ClassANY}
+	if rdata != nil {
+		r.Class, r.RData = ClassNONE, rdata
+	}
+	u.Updates = append(u.Updates, r)
+}
+
+// Require adds a prerequisite: that name exists (exists set) or not, for t
+// ANY, or else that it has an RRset of type t or not. With rdata the RRset
+// has to exist with exactly that record; give several for larger RRsets.
+func (u *DnsUpdate) Require(name string, t uint16, exists bool, rdata []byte) {
+	r := DnsResourceRecord{Name: name, Type: t, Class: ClassANY}
+	switch {
+	case !exists:
+		r.Class = ClassNONE
+	case rdata != nil:
+		r.Class, r.RData = u.class(), rdata
+	}
+	u.Prerequisites = append(u.Prerequisites, r)
+}
+
+func (u *DnsUpdate) class() uint16 {
+	if u.Class == 0 {
+		return IN
+	}
+	return u.Class
+}
+
+// SerializeUpdate encodes the update as a message with the given id. Unlike
+// SerializeResponse it keeps empty rdata empty, as deletions and
+// prerequisites need. Updates can't be split or truncated, so one too big
+// for a message is an error.
+func SerializeUpdate(id uint16, u DnsUpdate) ([]byte, error) {
+	var buf bytes.Buffer
+	header := DnsHeader{Id: id, Flags: OpUpdate << 11, QdCount: 1,
+		AnCount: uint16(len(u.Prerequisites)), NsCount: uint16(len(u.Updates))}
+	binary.Write(&buf, binary.BigEndian, header)
+	SerializeQuestion(&buf, DnsQuestion{QName: u.Zone, QType: SOA, QClass: u.class()})
+	for _, section := range [][]DnsResourceRecord{u.Prerequisites, u.Updates} {
+		for _, r := range section {
+			if len(r.RData) > 0 {
+				SerializeResourceRecord(&buf, r)
+				continue
+			}
+			buf.Write(SerializeName(r.Name))
+			binary.Write(&buf, binary.BigEndian, []uint16{r.Type, r.Class})
+			binary.Write(&buf, binary.BigEndian, r.TTL)
+			binary.Write(&buf, binary.BigEndian, uint16(0))
+		}
+	}
+	if buf.Len() > MaxMessageSize {
+		return nil, fmt.Errorf("update is %d bytes, more than fits in a message", buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+// SendUpdate sends u to server, the zone's primary, signed with key if it
+// isn't nil, over UDP unless tcp is set or the update is too big for it.
+// The response must be signed with the key as well.
+func SendUpdate(server string, u DnsUpdate, key *TSIGKey, tcp bool, timeouts Timeouts) (DnsResponse, error) {
+	id := RandomID()
+	msg, err := SerializeUpdate(id, u)
+	if err != nil {
+		return DnsResponse{}, err
+	}
+	var mac []byte
+	if key != nil {
+		msg, mac, err = key.Sign(msg, time.Now())
+		if err != nil {
+			return DnsResponse{}, err
+		}
+	}
+
+	var reply []byte
+	if !tcp && len(msg) <= 512 {
+		reply, err = sendMessage(context.Background(), ServerAddr(server), msg, 65535, timeouts, func(reply []byte) bool {
+			return len(reply) >= 2 && binary.BigEndian.Uint16(reply) == id
+		})
+		if err == nil && len(reply) > 2 && DnsFlags(binary.BigEndian.Uint16(reply[2:])).TC() == 1 {
+			tcp = true
+		}
+	} else {
+		tcp = true
+	}
+	if tcp {
+		reply, err = exchangeTCP(server, msg, timeouts)
+	}
+	if err != nil {
+		return DnsResponse{}, err
+	}
+	response, err := ReadResponse(reply)
+	if err != nil {
+		return response, err
+	}
+	if response.Header.Id != id {
+		return response, errors.New("response id does not match the update")
+	}
+	if key != nil {
+		err = key.Verify(reply, mac, time.Now())
+		if err != nil {
+			return response, err
+		}
+	}
+	return response, nil
+}
+
+func exchangeTCP(server string, msg []byte, timeouts Timeouts) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", ServerAddr(server), timeouts.Dial)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(deadline(timeouts.Write))
+	err = WriteMessageTCP(conn, msg)
+	if err != nil {
+		return nil, timeoutError(err, "write", server, timeouts.Write)
+	}
+	conn.SetReadDeadline(deadline(timeouts.Read))
+	reply, err := ReadMessageTCP(conn)
+	if err != nil {
+		return nil, timeoutError(err, "read", server, timeouts.Read)
+	}
+	return reply, nil
+}
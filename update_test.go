@@ -0,0 +1,126 @@
+package dnsclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// updateServer answers one update over UDP, signing its reply with key if
+// it isn't nil, and sends what it received on the channel.
+func updateServer(t *testing.T, key *TSIGKey) (string, chan []byte) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	received := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 65535)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		msg := append([]byte{}, buf[:n]...)
+		received <- msg
+		update, err := ReadResponse(msg)
+		if err != nil {
+			return
+		}
+		reply := SerializeResponse(DnsResponse{
+			Header:    DnsHeader{Id: update.Header.Id, Flags: FlagQR | OpUpdate<<11, QdCount: 1},
+			Questions: update.Questions,
+		})
+		if key != nil {
+			reply, _, err = key.sign(reply, requestMAC(msg), time.Now())
+			if err != nil {
+				return
+			}
+		}
+		conn.WriteTo(reply, addr)
+	}()
+	return conn.LocalAddr().String(), received
+}
+
+// requestMAC returns the MAC of the TSIG record msg ends with.
+func requestMAC(msg []byte) []byte {
+	_, tsig, err := findTSIG(msg)
+	if err != nil {
+		return nil
+	}
+	r := bytes.NewReader(tsig.RData)
+	if _, err := ReadName(r); err != nil {
+		return nil
+	}
+	fixed := make([]byte, 10)
+	if _, err := r.Read(fixed); err != nil {
+		return nil
+	}
+	mac := make([]byte, binary.BigEndian.Uint16(fixed[8:]))
+	r.Read(mac)
+	return mac
+}
+
+func TestSendUpdate(t *testing.T) {
+	key := TSIGKey{Name: "update-key", Algorithm: HmacSHA256, Secret: []byte("0123456789abcdef")}
+	other := TSIGKey{Name: "update-key", Algorithm: HmacSHA256, Secret: []byte("another secret")}
+	u := DnsUpdate{Zone: "example.com"}
+	u.Require("www.example.com", CNAME, false, nil)
+	u.Delete("www.example.com", A, nil)
+	u.Add(DnsResourceRecord{Name: "www.example.com", Type: A, Class: IN, TTL: 300, RDLength: 4, RData: []byte{192, 0, 2, 1}})
+
+	tests := []struct {
+		name   string
+		key    *TSIGKey
+		signer *TSIGKey
+		err    string
+	}{
+		{"unsigned", nil, nil, ""},
+		{"signed", &key, &key, ""},
+		{"reply not signed", &key, nil, "not signed"},
+		{"reply signed with another secret", &key, &other, "does not verify"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := updateServer(t, test.signer)
+			timeouts := Timeouts{Dial: time.Second, Write: time.Second, Read: 5 * time.Second}
+			response, err := SendUpdate(server, u, test.key, false, timeouts)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("SendUpdate() = %v, want error %q", err, test.err)
+				}
+				return
+			}
+			if err != nil || response.Header.Flags.RCode() != 0 {
+				t.Fatalf("SendUpdate() = %+v, %v", response.Header, err)
+			}
+
+			msg := <-received
+			if test.key != nil {
+				if err := test.key.Verify(msg, nil, time.Now()); err != nil {
+					t.Errorf("update signature: %v", err)
+				}
+			}
+			got, err := ReadResponse(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opcode := got.Header.Flags >> 11 & 0xf; opcode != OpUpdate {
+				t.Errorf("opcode %d, want %d", opcode, OpUpdate)
+			}
+			if len(got.Questions) != 1 || got.Questions[0].QName != "example.com" || got.Questions[0].QType != SOA {
+				t.Errorf("zone section %+v", got.Questions)
+			}
+			if len(got.Answers) != 1 || got.Answers[0].Class != ClassNONE || got.Answers[0].Type != CNAME {
+				t.Errorf("prerequisites %+v, want CNAME not in use", got.Answers)
+			}
+			if len(got.Authorities) != 2 || got.Authorities[0].Class != ClassANY || got.Authorities[0].RDLength != 0 ||
+				got.Authorities[1].Class != IN || !bytes.Equal(got.Authorities[1].RData, []byte{192, 0, 2, 1}) {
+				t.Errorf("updates %+v, want the A RRset deleted and one added", got.Authorities)
+			}
+		})
+	}
+}
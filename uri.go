@@ -0,0 +1,53 @@
+package dnsclient
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const URI = 256
+
+// URIRecord is the RData of a URI record (RFC 7553).
+type URIRecord struct {
+	Priority uint16
+	Weight   uint16
+	Target   string
+}
+
+func (u URIRecord) String() string {
+	return fmt.Sprintf("%d %d %s", u.Priority, u.Weight, strconv.Quote(u.Target))
+}
+
+// ParseURI decodes URI rdata. Unlike TXT the target is not a
+// <character-string>, it takes up the rest of the rdata.
+func ParseURI(rdata []byte) (URIRecord, error) {
+	var u URIRecord
+	if len(rdata) < 5 {
+		return u, errors.New("uri rdata too short")
+	}
+	u.Priority = binary.BigEndian.Uint16(rdata)
+	u.Weight = binary.BigEndian.Uint16(rdata[2:])
+	u.Target = string(rdata[4:])
+	return u, nil
+}
+
+func SerializeURI(u URIRecord) []byte {
+	rdata := make([]byte, 4, 4+len(u.Target))
+	binary.BigEndian.PutUint16(rdata, u.Priority)
+	binary.BigEndian.PutUint16(rdata[2:], u.Weight)
+	return append(rdata, u.Target...)
+}
+
+func init() {
+	RegisterType(URI, RRType{
+		Format: func(rdata []byte) string {
+			u, err := ParseURI(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return u.String()
+		},
+	})
+}
@@ -0,0 +1,108 @@
+package dnsclient
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	// The response is usable but something about it is odd
+	SeverityWarning Severity = iota
+	// The response is not a proper answer to the request
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding is one problem Validate found with a response. Field names the
+// header field or section it is about, e.g. "id" or "questions".
+type Finding struct {
+	Severity Severity
+	Field    string
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Field, f.Message)
+}
+
+// Validate checks response against request and returns everything that is
+// wrong with it. Errors mean the response does not answer the request at all,
+// warnings are unexpected but harmless flags and response codes that point at
+// a server problem. NXDOMAIN, SERVFAIL and REFUSED are outcomes of the query
+// rather than findings (see ResponseExitCode).
+func Validate(request DnsRequest, response DnsResponse) []Finding {
+	var findings []Finding
+	add := func(severity Severity, field string, format string, args ...interface{}) {
+		findings = append(findings, Finding{severity, field, fmt.Sprintf(format, args...)})
+	}
+	h, flags := response.Header, response.Header.Flags
+
+	if h.Id != request.Header.Id {
+		add(SeverityError, "id", "response id %d does not match request id %d", h.Id, request.Header.Id)
+	}
+	if flags.QR() != 1 {
+		add(SeverityError, "qr", "response qr is not 1 (response)")
+	}
+	if flags.OpCode() != request.Header.Flags.OpCode() {
+		add(SeverityError, "opcode", "response opcode %s does not match request opcode %s", OpCodeToString(flags.OpCode()), OpCodeToString(request.Header.Flags.OpCode()))
+	}
+	if qdcount := uint16(len(request.Questions)); h.QdCount != qdcount && !(h.QdCount == 0 && flags.RCode() != 0) {
+		add(SeverityError, "qdcount", "response qdcount %d does not match request qdcount %d", h.QdCount, qdcount)
+	} else if !MatchesRequest(response, request) && h.Id == request.Header.Id {
+		add(SeverityError, "questions", "response questions %s do not match the request", formatQuestions(response.Questions))
+	}
+
+	if flags.TC() != 0 {
+		add(SeverityWarning, "tc", "response is truncated")
+	}
+	if flags.RD() != request.Header.Flags.RD() {
+		add(SeverityWarning, "rd", "response rd %d does not match request rd %d (recursion desired)", flags.RD(), request.Header.Flags.RD())
+	}
+	if flags.RD() == 1 && flags.RA() != 1 {
+		add(SeverityWarning, "ra", "response ra is not 1 (recursion available) but recursion was asked for")
+	}
+	if flags.Z() != 0 {
+		add(SeverityWarning, "z", "response z bit is set")
+	}
+	if flags.CD() != request.Header.Flags.CD() {
+		add(SeverityWarning, "cd", "response cd %d does not match request cd %d (checking disabled)", flags.CD(), request.Header.Flags.CD())
+	}
+	switch rcode := flags.RCode(); rcode {
+	case 0, 2, 3, 5:
+	case 1:
+		add(SeverityWarning, "rcode", "server could not parse the query (FORMERR)")
+	case 4:
+		add(SeverityWarning, "rcode", "server does not implement the query (NOTIMP)")
+	default:
+		add(SeverityWarning, "rcode", "unexpected rcode %s", RCodeToString(rcode))
+	}
+	return findings
+}
+
+func formatQuestions(questions []DnsQuestion) string {
+	strs := make([]string, len(questions))
+	for i, q := range questions {
+		strs[i] = fmt.Sprintf("{ %s }", q)
+	}
+	return "[" + strings.Join(strs, ", ") + "]"
+}
+
+// ValidateResponseHeader checks that response is a well formed answer to
+// request and returns the first error Validate finds. Warnings are ignored.
+func ValidateResponseHeader(response DnsResponse, request DnsRequest) error {
+	for _, f := range Validate(request, response) {
+		if f.Severity == SeverityError {
+			return errors.New(f.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package dnsclient
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+const ZONEMD = 63
+
+// ZONEMD schemes and hash algorithms (RFC 8976 sections 5.2 and 5.3)
+const (
+	ZONEMDSchemeSimple = 1
+	ZONEMDSHA384       = 1
+	ZONEMDSHA512       = 2
+)
+
+// ZONEMDRecord is the RData of a ZONEMD record (RFC 8976).
+type ZONEMDRecord struct {
+	Serial        uint32
+	Scheme        uint8
+	HashAlgorithm uint8
+	Digest        []byte
+}
+
+func (z ZONEMDRecord) String() string {
+	return fmt.Sprintf("%d %d %d %x", z.Serial, z.Scheme, z.HashAlgorithm, z.Digest)
+}
+
+func ParseZONEMD(rdata []byte) (ZONEMDRecord, error) {
+	var z ZONEMDRecord
+	if len(rdata) < 6 {
+		return z, errors.New("zonemd rdata too short")
+	}
+	z.Serial = binary.BigEndian.Uint32(rdata)
+	z.Scheme = rdata[4]
+	z.HashAlgorithm = rdata[5]
+	z.Digest = rdata[6:]
+	return z, nil
+}
+
+func init() {
+	RegisterType(ZONEMD, RRType{
+		Format: func(rdata []byte) string {
+			z, err := ParseZONEMD(rdata)
+			if err != nil {
+				return FormatUnknownRData(rdata)
+			}
+			return z.String()
+		},
+	})
+}
+
+// ZoneDigest computes the SIMPLE scheme digest of a zone (RFC 8976 section
+// 3.3): every record in canonical form and order, leaving out the apex ZONEMD
+// RRset and its signatures and any duplicate records.
+func ZoneDigest(apex string, records []DnsResourceRecord, hashAlgorithm uint8) ([]byte, error) {
+	var h hash.Hash
+	switch hashAlgorithm {
+	case ZONEMDSHA384:
+		h = sha512.New384()
+	case ZONEMDSHA512:
+		h = sha512.New()
+	default:
+		return nil, fmt.Errorf("unsupported zonemd hash algorithm %d", hashAlgorithm)
+	}
+
+	var rrs []DnsResourceRecord
+	for _, r := range records {
+		if CompareNames(r.Name, apex) == 0 {
+			if r.Type == ZONEMD {
+				continue
+			}
+			if sig, err := ParseRRSIG(r.RData); r.Type == RRSIG && err == nil && sig.TypeCovered == ZONEMD {
+				continue
+			}
+		}
+		rrs = append(rrs, r)
+	}
+	SortCanonical(rrs)
+
+	var last []byte
+	for _, rr := range rrs {
+		wire := SerializeCanonical(rr)
+		if bytes.Equal(wire, last) {
+			continue
+		}
+		h.Write(wire)
+		last = wire
+	}
+	return h.Sum(nil), nil
+}
+
+// VerifyZONEMD checks the apex ZONEMD records of a complete zone against the
+// zone contents. It succeeds if any record with the serial of the zone's SOA
+// and a supported scheme and hash algorithm matches (RFC 8976 section 4).
+func VerifyZONEMD(apex string, records []DnsResourceRecord) error {
+	var soa *SOARecord
+	var zonemds []ZONEMDRecord
+	for _, r := range records {
+		if CompareNames(r.Name, apex) != 0 {
+			continue
+		}
+		switch r.Type {
+		case SOA:
+			s, err := ParseSOA(r.RData)
+			if err != nil {
+				return err
+			}
+			soa = &s
+		case ZONEMD:
+			z, err := ParseZONEMD(r.RData)
+			if err != nil {
+				return err
+			}
+			zonemds = append(zonemds, z)
+		}
+	}
+	if soa == nil {
+		return errors.New("zone has no SOA record")
+	}
+	if len(zonemds) == 0 {
+		return errors.New("zone has no ZONEMD record")
+	}
+
+	err := errors.New("no ZONEMD record with a supported scheme and hash algorithm")
+	for _, z := range zonemds {
+		if z.Serial != soa.Serial {
+			err = fmt.Errorf("ZONEMD serial %d does not match SOA serial %d", z.Serial, soa.Serial)
+			continue
+		}
+		if z.Scheme != ZONEMDSchemeSimple {
+			continue
+		}
+		digest, derr := ZoneDigest(apex, records, z.HashAlgorithm)
+		if derr != nil {
+			continue
+		}
+		if bytes.Equal(digest, z.Digest) {
+			return nil
+		}
+		err = fmt.Errorf("zone digest %x does not match ZONEMD digest %x", digest, z.Digest)
+	}
+	return err
+}
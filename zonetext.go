@@ -0,0 +1,171 @@
+package dnsclient
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ZoneFields splits a line of a zone file into fields at white space,
+// keeping quoted strings together (with their quotes) and dropping a
+// ; comment.
+func ZoneFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField, quoted, escaped := false, false, false
+	for _, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case c == ';' && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+			}
+			return fields
+		case (c == ' ' || c == '\t') && !quoted:
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+			continue
+		}
+		field.WriteRune(c)
+		inField = true
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// ParseRR parses a record in zone file format, "name [ttl] [class] type
+// rdata", with defaultTTL for records that don't give one. Names are taken
+// as fully qualified, with or without the trailing dot.
+func ParseRR(line string, defaultTTL uint32) (DnsResourceRecord, error) {
+	var r DnsResourceRecord
+	fields := ZoneFields(line)
+	if len(fields) < 3 {
+		return r, fmt.Errorf("record %q needs a name, a type and rdata", line)
+	}
+	r.Name = ZoneName(fields[0])
+	r.TTL = int32(defaultTTL)
+	r.Class = IN
+	fields = fields[1:]
+	// The TTL and class may come in either order before the type
+	for i := 0; i < 2 && len(fields) > 1; i++ {
+		if ttl, err := strconv.ParseUint(fields[0], 10, 31); err == nil {
+			r.TTL = int32(ttl)
+			fields = fields[1:]
+		} else if c, err := StringToClass(fields[0]); err == nil && !IsTypeName(fields[0]) {
+			r.Class = c
+			fields = fields[1:]
+		}
+	}
+	t, err := StringToType(fields[0])
+	if err != nil {
+		return r, err
+	}
+	r.Type = t
+	r.RData, err = ParseRDataText(t, fields[1:])
+	if err != nil {
+		return r, fmt.Errorf("%s %s: %v", r.Name, TypeToString(t), err)
+	}
+	r.RDLength = uint16(len(SerializeRData(r.Type, r.RData)))
+	return r, nil
+}
+
+// IsTypeName reports whether s is the name of a record type.
+func IsTypeName(s string) bool {
+	_, ok := typeNumbers[strings.ToUpper(s)]
+	return ok
+}
+
+// ZoneName turns a name as written in a zone file or update into the form
+// records keep, in ASCII and without the trailing dot.
+func ZoneName(s string) string {
+	return strings.TrimSuffix(ToASCII(s), ".")
+}
+
+// ParseRDataText parses the presentation format rdata of the common types
+// into the form DnsResourceRecord.RData keeps them in. Any type can be given
+// in the RFC 3597 \# length hex format.
+func ParseRDataText(t uint16, fields []string) ([]byte, error) {
+	if len(fields) > 0 && fields[0] == `\#` {
+		return ParseUnknownRData(strings.Join(fields, " "))
+	}
+	want := func(n int) error {
+		if len(fields) != n {
+			return fmt.Errorf("want %d rdata fields, got %d", n, len(fields))
+		}
+		return nil
+	}
+	switch t {
+	case A, AAAA:
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil || (t == A) != (ip.To4() != nil) {
+			return nil, fmt.Errorf("invalid address %q", fields[0])
+		}
+		if t == A {
+			return ip.To4(), nil
+		}
+		return ip.To16(), nil
+	case NS, CNAME, PTR, DNAME:
+		if err := want(1); err != nil {
+			return nil, err
+		}
+		return []byte(ZoneName(fields[0])), nil
+	case MX:
+		if err := want(2); err != nil {
+			return nil, err
+		}
+		pref, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid preference %q", fields[0])
+		}
+		rdata := []byte{byte(pref >> 8), byte(pref)}
+		return append(rdata, SerializeName(ZoneName(fields[1]))...), nil
+	case SRV:
+		if err := want(4); err != nil {
+			return nil, err
+		}
+		var rdata []byte
+		for _, f := range fields[:3] {
+			n, err := strconv.ParseUint(f, 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q", f)
+			}
+			rdata = append(rdata, byte(n>>8), byte(n))
+		}
+		return append(rdata, SerializeName(ZoneName(fields[3]))...), nil
+	case TXT:
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("txt needs at least one string")
+		}
+		var rdata []byte
+		for _, f := range fields {
+			s := f
+			if strings.HasPrefix(f, `"`) {
+				var err error
+				s, err = strconv.Unquote(f)
+				if err != nil {
+					return nil, fmt.Errorf("invalid string %s", f)
+				}
+			}
+			if len(s) > 255 {
+				return nil, fmt.Errorf("string longer than 255 bytes")
+			}
+			rdata = append(append(rdata, byte(len(s))), s...)
+		}
+		return rdata, nil
+	}
+	return nil, fmt.Errorf("%s rdata can only be given as \\# length hex", TypeToString(t))
+}